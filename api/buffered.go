@@ -0,0 +1,122 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultBufferedCount is the number of value lists buffered by a
+// BufferedWriter if BufferedOptions.Count is not set.
+const DefaultBufferedCount = 100
+
+// BufferedOptions holds options for NewBufferedWriter.
+type BufferedOptions struct {
+	// Count is the number of value lists buffered before they are written
+	// to the downstream Writer. If zero, DefaultBufferedCount is used.
+	Count int
+	// Interval is the maximum time value lists are kept in the buffer. If
+	// zero, value lists are only written when Count is reached or when
+	// Flush or Close is called.
+	Interval time.Duration
+	// WriteError handles errors of periodic flushes, like
+	// Grouper.WriteError.
+	WriteError func(vl *ValueList, err error)
+}
+
+// BufferedWriter is a Writer that buffers value lists and writes them to a
+// downstream Writer in batches, either when the buffer is full or
+// periodically. It is a Grouper that puts all value lists into the same group,
+// with a Close method stopping the periodic flushing.
+type BufferedWriter struct {
+	g        Grouper
+	interval time.Duration
+
+	// mu guards closed. Write holds a read lock so that Close can't
+	// flush while a value list is being added.
+	mu     sync.RWMutex
+	closed bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBufferedWriter returns a new BufferedWriter writing to w. If
+// opts.Interval is set, a goroutine flushing the buffer periodically is
+// started; call Close to stop it.
+func NewBufferedWriter(w Writer, opts BufferedOptions) *BufferedWriter {
+	if opts.Count <= 0 {
+		opts.Count = DefaultBufferedCount
+	}
+
+	b := &BufferedWriter{
+		g: Grouper{
+			Writer:     w,
+			Key:        func(Identifier) string { return "" },
+			MaxSize:    opts.Count,
+			WriteError: opts.WriteError,
+		},
+		interval: opts.Interval,
+		done:     make(chan struct{}),
+	}
+
+	if opts.Interval > 0 {
+		b.wg.Add(1)
+		go b.run()
+	}
+
+	return b
+}
+
+// Write adds a copy of vl to the buffer. If the buffer is full, all buffered
+// value lists are written to the downstream Writer and the errors of doing so
+// are returned.
+func (b *BufferedWriter) Write(ctx context.Context, vl *ValueList) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return errors.New("BufferedWriter is closed")
+	}
+	return b.g.Write(ctx, vl)
+}
+
+// Flush writes all buffered value lists to the downstream Writer. Errors are
+// combined and returned after all value lists have been written.
+func (b *BufferedWriter) Flush(ctx context.Context) error {
+	return b.g.Flush(ctx)
+}
+
+// Close stops the periodic flushing, if any, and writes the remaining value
+// lists to the downstream Writer. Calling Write after Close returns an error.
+func (b *BufferedWriter) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.done)
+	b.wg.Wait()
+
+	return b.Flush(context.Background())
+}
+
+func (b *BufferedWriter) run() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.g.flushAndReport(context.Background())
+		case <-b.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func bufferedTestVL(host string) *api.ValueList {
+	return &api.ValueList{
+		Identifier: api.Identifier{Host: host, Plugin: "cpu", Type: "cpu"},
+		Time:       time.Unix(1588164686, 0),
+		Interval:   10 * time.Second,
+		Values:     []api.Value{api.Derive(42)},
+	}
+}
+
+func TestBufferedWriter_Count(t *testing.T) {
+	ctx := context.Background()
+	w := &recordingWriter{}
+	b := api.NewBufferedWriter(w, api.BufferedOptions{Count: 2})
+
+	for i, host := range []string{"a", "b", "c"} {
+		if err := b.Write(ctx, bufferedTestVL(host)); err != nil {
+			t.Fatalf("Write(#%d) = %v", i, err)
+		}
+	}
+
+	want := []string{"a/cpu/cpu", "b/cpu/cpu"}
+	if diff := cmp.Diff(want, w.got); diff != "" {
+		t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+	}
+
+	if err := b.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	want = append(want, "c/cpu/cpu")
+	if diff := cmp.Diff(want, w.got); diff != "" {
+		t.Errorf("written value lists after Flush differ (+got/-want):\n%s", diff)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+}
+
+func TestBufferedWriter_Interval(t *testing.T) {
+	ctx := context.Background()
+
+	ch := make(chan string, 10)
+	w := api.WriterFunc(func(_ context.Context, vl *api.ValueList) error {
+		ch <- vl.Identifier.String()
+		return nil
+	})
+
+	b := api.NewBufferedWriter(w, api.BufferedOptions{
+		Count:    100,
+		Interval: 10 * time.Millisecond,
+	})
+	defer b.Close()
+
+	if err := b.Write(ctx, bufferedTestVL("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-ch:
+		if want := "a/cpu/cpu"; got != want {
+			t.Errorf("written value list = %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for periodic flush")
+	}
+}
+
+func TestBufferedWriter_Close(t *testing.T) {
+	ctx := context.Background()
+	w := &recordingWriter{}
+	b := api.NewBufferedWriter(w, api.BufferedOptions{
+		Interval: time.Hour,
+	})
+
+	vl := bufferedTestVL("a")
+	if err := b.Write(ctx, vl); err != nil {
+		t.Fatal(err)
+	}
+	// The value list is copied, so modifying it after Write has no effect.
+	vl.Host = "modified"
+
+	if len(w.got) != 0 {
+		t.Errorf("value lists written before Close: %v", w.got)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if diff := cmp.Diff([]string{"a/cpu/cpu"}, w.got); diff != "" {
+		t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+	}
+
+	if err := b.Write(ctx, vl); err == nil {
+		t.Error("Write() after Close() succeeded, want error")
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
+
+func TestBufferedWriter_Error(t *testing.T) {
+	ctx := context.Background()
+	wantErr := errors.New("write failed")
+	w := api.WriterFunc(func(context.Context, *api.ValueList) error {
+		return wantErr
+	})
+
+	b := api.NewBufferedWriter(w, api.BufferedOptions{Count: 2})
+	if err := b.Write(ctx, bufferedTestVL("a")); err != nil {
+		t.Fatalf("Write(#0) = %v", err)
+	}
+	if err := b.Write(ctx, bufferedTestVL("b")); !errors.Is(err, wantErr) {
+		t.Errorf("Write(#1) = %v, want %v", err, wantErr)
+	}
+}
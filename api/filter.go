@@ -0,0 +1,34 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+)
+
+// FilterWriter is a Writer that rewrites and filters value lists before
+// passing them on to the next Writer. Combined with Fanout, it can be used to
+// build simple pipelines.
+type FilterWriter struct {
+	Writer Writer
+	// Rewrite, if not nil, is called with a copy of each value list and
+	// may modify it. The value list passed to Write is not modified.
+	Rewrite func(vl *ValueList)
+	// Keep, if not nil, is called after Rewrite and determines whether the
+	// value list is passed on. If Keep is nil, all value lists are passed
+	// on.
+	Keep func(vl *ValueList) bool
+}
+
+// Write applies Rewrite and Keep to vl and passes the result to f.Writer.
+// Dropped value lists are not considered an error.
+func (f *FilterWriter) Write(ctx context.Context, vl *ValueList) error {
+	if f.Rewrite != nil {
+		vl = vl.Clone()
+		f.Rewrite(vl)
+	}
+
+	if f.Keep != nil && !f.Keep(vl) {
+		return nil
+	}
+
+	return f.Writer.Write(ctx, vl)
+}
@@ -0,0 +1,83 @@
+package api_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilterWriter(t *testing.T) {
+	ids := []api.Identifier{
+		{Host: "A.example.com", Plugin: "cpu", Type: "cpu"},
+		{Host: "b.example.com", Plugin: "memory", Type: "memory"},
+		{Host: "C.example.com", Plugin: "cpu", Type: "cpu"},
+	}
+
+	cases := []struct {
+		title   string
+		rewrite func(*api.ValueList)
+		keep    func(*api.ValueList) bool
+		want    []string
+	}{
+		{
+			title: "pass",
+			want:  []string{"A.example.com/cpu/cpu", "b.example.com/memory/memory", "C.example.com/cpu/cpu"},
+		},
+		{
+			title: "drop",
+			keep: func(vl *api.ValueList) bool {
+				return vl.Plugin == "cpu"
+			},
+			want: []string{"A.example.com/cpu/cpu", "C.example.com/cpu/cpu"},
+		},
+		{
+			title: "rewrite",
+			rewrite: func(vl *api.ValueList) {
+				vl.Host = strings.ToLower(vl.Host)
+			},
+			want: []string{"a.example.com/cpu/cpu", "b.example.com/memory/memory", "c.example.com/cpu/cpu"},
+		},
+		{
+			title: "keep sees rewritten value list",
+			rewrite: func(vl *api.ValueList) {
+				vl.Host = strings.ToLower(vl.Host)
+			},
+			keep: func(vl *api.ValueList) bool {
+				return vl.Host != "c.example.com"
+			},
+			want: []string{"a.example.com/cpu/cpu", "b.example.com/memory/memory"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			w := &recordingWriter{}
+			f := &api.FilterWriter{
+				Writer:  w,
+				Rewrite: tc.rewrite,
+				Keep:    tc.keep,
+			}
+
+			for _, id := range ids {
+				vl := &api.ValueList{
+					Identifier: id,
+					Values:     []api.Value{api.Gauge(42)},
+				}
+				if err := f.Write(context.Background(), vl); err != nil {
+					t.Fatal(err)
+				}
+
+				if vl.Identifier != id {
+					t.Errorf("Write() modified its argument: got %v, want %v", vl.Identifier, id)
+				}
+			}
+
+			if diff := cmp.Diff(tc.want, w.got); diff != "" {
+				t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// DefaultGroupSize is the maximum number of value lists buffered by a Grouper
+// if MaxSize is not set.
+const DefaultGroupSize = 1024
+
+// Grouper is a Writer that buffers value lists and writes them to the
+// underlying Writer grouped by key, e.g. by host. Within a group, value lists
+// retain the order in which they were written. Groups are written in the order
+// in which they were first seen.
+//
+// Value lists are written to the underlying Writer when Flush is called, when
+// the buffer is full, and periodically when Run is used.
+type Grouper struct {
+	Writer Writer
+	// Key returns the key value lists are grouped by. If nil, value lists
+	// are grouped by host.
+	Key func(Identifier) string
+	// MaxSize is the maximum number of buffered value lists. When this
+	// number is reached, the buffer is flushed. If zero,
+	// DefaultGroupSize is used.
+	MaxSize int
+	// WriteError, if not nil, is called for every value list the
+	// underlying Writer failed to write during a periodic flush in Run. If
+	// WriteError is nil, such errors are logged.
+	WriteError func(vl *ValueList, err error)
+
+	mu     sync.Mutex
+	groups map[string][]*ValueList
+	keys   []string
+	size   int
+
+	// flushMu serializes flushes so that value lists are written in order.
+	flushMu sync.Mutex
+}
+
+// Write adds a copy of vl to the buffer. If the buffer is full, it is flushed
+// to the underlying Writer.
+func (g *Grouper) Write(ctx context.Context, vl *ValueList) error {
+	g.mu.Lock()
+	key := g.key(vl.Identifier)
+	if g.groups == nil {
+		g.groups = make(map[string][]*ValueList)
+	}
+	if _, ok := g.groups[key]; !ok {
+		g.keys = append(g.keys, key)
+	}
+	g.groups[key] = append(g.groups[key], vl.Clone())
+	g.size++
+
+	maxSize := g.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultGroupSize
+	}
+	full := g.size >= maxSize
+	g.mu.Unlock()
+
+	if !full {
+		return nil
+	}
+	return g.Flush(ctx)
+}
+
+// Flush writes all buffered value lists to the underlying Writer.
+func (g *Grouper) Flush(ctx context.Context) error {
+	return g.flush(ctx, func(_ *ValueList, err error) error {
+		return err
+	})
+}
+
+// Run flushes the buffer every interval. Errors during these periodic flushes
+// are passed to WriteError, or logged, and don't stop Run. When ctx is
+// cancelled, Run flushes the remaining value lists and returns the context's
+// error, combined with any errors of the final flush.
+func (g *Grouper) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.flushAndReport(ctx)
+		case <-ctx.Done():
+			// ctx is already cancelled; don't pass it to the writers.
+			if err := g.Flush(context.Background()); err != nil {
+				return multierr.Append(ctx.Err(), err)
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+func (g *Grouper) key(id Identifier) string {
+	if g.Key == nil {
+		return id.Host
+	}
+	return g.Key(id)
+}
+
+// flush writes all buffered value lists and calls handle for each error. The
+// non-nil errors returned by handle are combined and returned.
+func (g *Grouper) flush(ctx context.Context, handle func(*ValueList, error) error) error {
+	g.flushMu.Lock()
+	defer g.flushMu.Unlock()
+
+	g.mu.Lock()
+	groups, keys := g.groups, g.keys
+	g.groups, g.keys, g.size = nil, nil, 0
+	g.mu.Unlock()
+
+	var errs error
+	for _, key := range keys {
+		for _, vl := range groups[key] {
+			if err := g.Writer.Write(ctx, vl); err != nil {
+				errs = multierr.Append(errs, handle(vl, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// flushAndReport writes all buffered value lists and passes errors to
+// WriteError, or logs them.
+func (g *Grouper) flushAndReport(ctx context.Context) {
+	g.flush(ctx, func(vl *ValueList, err error) error {
+		g.writeError(vl, err)
+		return nil
+	})
+}
+
+func (g *Grouper) writeError(vl *ValueList, err error) {
+	if g.WriteError == nil {
+		log.Printf("%T.Write(): %v", g.Writer, err)
+		return
+	}
+	g.WriteError(vl, err)
+}
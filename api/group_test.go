@@ -0,0 +1,243 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+type recordingWriter struct {
+	got []string
+}
+
+func (w *recordingWriter) Write(_ context.Context, vl *api.ValueList) error {
+	w.got = append(w.got, vl.Identifier.String())
+	return nil
+}
+
+func TestGrouper(t *testing.T) {
+	ids := []api.Identifier{
+		{Host: "a", Plugin: "cpu", Type: "cpu"},
+		{Host: "b", Plugin: "cpu", Type: "cpu"},
+		{Host: "a", Plugin: "memory", Type: "memory"},
+		{Host: "c", Plugin: "cpu", Type: "cpu"},
+		{Host: "b", Plugin: "memory", Type: "memory"},
+	}
+
+	cases := []struct {
+		title   string
+		key     func(api.Identifier) string
+		maxSize int
+		want    []string
+	}{
+		{
+			title: "by host",
+			want: []string{
+				"a/cpu/cpu", "a/memory/memory",
+				"b/cpu/cpu", "b/memory/memory",
+				"c/cpu/cpu",
+			},
+		},
+		{
+			title: "by plugin",
+			key:   func(id api.Identifier) string { return id.Plugin },
+			want: []string{
+				"a/cpu/cpu", "b/cpu/cpu", "c/cpu/cpu",
+				"a/memory/memory", "b/memory/memory",
+			},
+		},
+		{
+			title:   "max size",
+			maxSize: 3,
+			want: []string{
+				// flushed when the third value list is written
+				"a/cpu/cpu", "a/memory/memory", "b/cpu/cpu",
+				// flushed explicitly
+				"c/cpu/cpu", "b/memory/memory",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ctx := context.Background()
+			w := &recordingWriter{}
+			g := &api.Grouper{
+				Writer:  w,
+				Key:     tc.key,
+				MaxSize: tc.maxSize,
+			}
+
+			for _, id := range ids {
+				vl := &api.ValueList{
+					Identifier: id,
+					Values:     []api.Value{api.Gauge(42)},
+				}
+				if err := g.Write(ctx, vl); err != nil {
+					t.Fatalf("Grouper.Write() = %v", err)
+				}
+			}
+
+			if err := g.Flush(ctx); err != nil {
+				t.Fatalf("Grouper.Flush() = %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want, w.got); diff != "" {
+				t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGrouper_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := &recordingWriter{}
+	g := &api.Grouper{
+		Writer: w,
+	}
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestGrouper_Run",
+			Type:   "gauge",
+		},
+		Values: []api.Value{api.Gauge(42)},
+	}
+	if err := g.Write(ctx, vl); err != nil {
+		t.Fatalf("Grouper.Write() = %v", err)
+	}
+
+	cancel()
+	if err := g.Run(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Grouper.Run() = %v, want %v", err, context.Canceled)
+	}
+
+	if diff := cmp.Diff([]string{vl.Identifier.String()}, w.got); diff != "" {
+		t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(context.Context, *api.ValueList) error {
+	return w.err
+}
+
+func TestGrouper_RunError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wantErr := errors.New("write failed")
+	errs := make(chan string)
+	g := &api.Grouper{
+		Writer: failingWriter{err: wantErr},
+		WriteError: func(vl *api.ValueList, err error) {
+			if !errors.Is(err, wantErr) {
+				t.Errorf("WriteError(%v, %v), want error %v", vl, err, wantErr)
+			}
+			errs <- vl.Plugin
+		},
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- g.Run(ctx, 10*time.Millisecond)
+	}()
+
+	// Run must keep flushing after a failed write.
+	for _, plugin := range []string{"first", "second"} {
+		vl := &api.ValueList{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: plugin,
+				Type:   "gauge",
+			},
+			Values: []api.Value{api.Gauge(42)},
+		}
+		if err := g.Write(ctx, vl); err != nil {
+			t.Fatalf("Grouper.Write() = %v", err)
+		}
+
+		select {
+		case got := <-errs:
+			if got != plugin {
+				t.Errorf("WriteError() called for %q, want %q", got, plugin)
+			}
+		case err := <-done:
+			t.Fatalf("Grouper.Run() = %v, want it to keep running", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for periodic flush")
+		}
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Grouper.Run() = %v, want %v", err, context.Canceled)
+	}
+}
+
+type blockingWriter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w blockingWriter) Write(context.Context, *api.ValueList) error {
+	w.started <- struct{}{}
+	<-w.release
+	return nil
+}
+
+func TestGrouper_WriteDuringFlush(t *testing.T) {
+	ctx := context.Background()
+
+	w := blockingWriter{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	g := &api.Grouper{Writer: w}
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestGrouper_WriteDuringFlush",
+			Type:   "gauge",
+		},
+		Values: []api.Value{api.Gauge(42)},
+	}
+	if err := g.Write(ctx, vl); err != nil {
+		t.Fatalf("Grouper.Write() = %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- g.Flush(ctx)
+	}()
+	<-w.started
+
+	// The underlying Writer is blocked; Write must not wait for it.
+	written := make(chan error)
+	go func() {
+		written <- g.Write(ctx, vl)
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Errorf("Grouper.Write() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Grouper.Write() blocked by a flush in progress")
+	}
+
+	close(w.release)
+	if err := <-done; err != nil {
+		t.Errorf("Grouper.Flush() = %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHostCacheTTL is the time HostRewriter caches lookup results if
+// CacheTTL is not set.
+const DefaultHostCacheTTL = 10 * time.Minute
+
+// HostRewriter is a Writer that replaces the Host field of value lists, e.g.
+// an IP address, with the result of Lookup before passing value lists on to
+// the next Writer. Lookup results, including failures, are cached. Failures
+// caused by the context passed to Write being cancelled are not cached.
+type HostRewriter struct {
+	Writer Writer
+	// Lookup returns the name to use for host. If Lookup returns an
+	// error, the original host is used. See HostMap and LookupAddr for
+	// implementations.
+	Lookup func(ctx context.Context, host string) (string, error)
+	// CacheTTL is the time lookup results are cached for. If zero,
+	// DefaultHostCacheTTL is used.
+	CacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]hostCacheEntry
+	// nextPurge is the time after which expired entries are removed from
+	// cache.
+	nextPurge time.Time
+}
+
+type hostCacheEntry struct {
+	name    string
+	expires time.Time
+}
+
+// Write passes a copy of vl with the rewritten Host field to hr.Writer. If
+// the host does not change, vl is passed on unmodified.
+func (hr *HostRewriter) Write(ctx context.Context, vl *ValueList) error {
+	name := hr.lookup(ctx, vl.Host)
+	if name != vl.Host {
+		// Don't modify the argument.
+		vl = vl.Clone()
+		vl.Host = name
+	}
+
+	return hr.Writer.Write(ctx, vl)
+}
+
+func (hr *HostRewriter) lookup(ctx context.Context, host string) string {
+	now := time.Now()
+
+	hr.mu.Lock()
+	e, ok := hr.cache[host]
+	hr.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.name
+	}
+
+	name, err := hr.Lookup(ctx, host)
+	if err != nil && ctx.Err() != nil {
+		// The lookup may succeed with another context.
+		return host
+	}
+	if err != nil || name == "" {
+		name = host
+	}
+
+	ttl := hr.CacheTTL
+	if ttl <= 0 {
+		ttl = DefaultHostCacheTTL
+	}
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	if hr.cache == nil {
+		hr.cache = make(map[string]hostCacheEntry)
+	}
+	if now.After(hr.nextPurge) {
+		for h, e := range hr.cache {
+			if !now.Before(e.expires) {
+				delete(hr.cache, h)
+			}
+		}
+		hr.nextPurge = now.Add(ttl)
+	}
+	hr.cache[host] = hostCacheEntry{
+		name:    name,
+		expires: now.Add(ttl),
+	}
+
+	return name
+}
+
+// HostMap is a static mapping from hosts to names. Its Lookup method can be
+// used with HostRewriter.
+type HostMap map[string]string
+
+// Lookup returns the name host maps to, or an error if host is not in m.
+func (m HostMap) Lookup(_ context.Context, host string) (string, error) {
+	name, ok := m[host]
+	if !ok {
+		return "", fmt.Errorf("host %q not found", host)
+	}
+	return name, nil
+}
+
+// LookupAddr does a reverse DNS lookup of the IP address host and returns the
+// first name found, without the trailing dot. It can be used with
+// HostRewriter.
+func LookupAddr(ctx context.Context, host string) (string, error) {
+	names, err := net.DefaultResolver.LookupAddr(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no names found for %q", host)
+	}
+
+	return strings.TrimSuffix(names[0], "."), nil
+}
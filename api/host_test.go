@@ -0,0 +1,119 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHostRewriter(t *testing.T) {
+	ctx := context.Background()
+
+	hosts := api.HostMap{
+		"192.0.2.1": "www.example.com",
+		"192.0.2.2": "db.example.com",
+	}
+	lookups := make(map[string]int)
+
+	w := &recordingWriter{}
+	hr := &api.HostRewriter{
+		Writer: w,
+		Lookup: func(ctx context.Context, host string) (string, error) {
+			lookups[host]++
+			return hosts.Lookup(ctx, host)
+		},
+	}
+
+	var originals []*api.ValueList
+	for _, host := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.1", "192.0.2.3"} {
+		vl := &api.ValueList{
+			Identifier: api.Identifier{
+				Host:   host,
+				Plugin: "cpu",
+				Type:   "cpu",
+			},
+			Values: []api.Value{api.Derive(42)},
+		}
+		if err := hr.Write(ctx, vl); err != nil {
+			t.Fatalf("HostRewriter.Write() = %v", err)
+		}
+		originals = append(originals, vl)
+	}
+
+	want := []string{
+		"www.example.com/cpu/cpu",
+		"db.example.com/cpu/cpu",
+		"192.0.2.3/cpu/cpu",
+		"www.example.com/cpu/cpu",
+		"192.0.2.3/cpu/cpu",
+	}
+	if diff := cmp.Diff(want, w.got); diff != "" {
+		t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+	}
+
+	// Each host is looked up once, including the one failing the lookup.
+	wantLookups := map[string]int{
+		"192.0.2.1": 1,
+		"192.0.2.2": 1,
+		"192.0.2.3": 1,
+	}
+	if diff := cmp.Diff(wantLookups, lookups); diff != "" {
+		t.Errorf("lookups differ (+got/-want):\n%s", diff)
+	}
+
+	// The value lists passed to Write must not be modified.
+	if got, want := originals[0].Host, "192.0.2.1"; got != want {
+		t.Errorf("vl.Host = %q, want %q", got, want)
+	}
+}
+
+func TestHostRewriter_Canceled(t *testing.T) {
+	hosts := api.HostMap{
+		"192.0.2.1": "www.example.com",
+	}
+	lookups := 0
+
+	w := &recordingWriter{}
+	hr := &api.HostRewriter{
+		Writer: w,
+		Lookup: func(ctx context.Context, host string) (string, error) {
+			lookups++
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			return hosts.Lookup(ctx, host)
+		},
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, ctx := range []context.Context{canceled, context.Background(), context.Background()} {
+		vl := &api.ValueList{
+			Identifier: api.Identifier{
+				Host:   "192.0.2.1",
+				Plugin: "cpu",
+				Type:   "cpu",
+			},
+			Values: []api.Value{api.Derive(42)},
+		}
+		if err := hr.Write(ctx, vl); err != nil {
+			t.Fatalf("HostRewriter.Write() = %v", err)
+		}
+	}
+
+	// The failure due to the cancelled context must not be cached.
+	want := []string{
+		"192.0.2.1/cpu/cpu",
+		"www.example.com/cpu/cpu",
+		"www.example.com/cpu/cpu",
+	}
+	if diff := cmp.Diff(want, w.got); diff != "" {
+		t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+	}
+	if lookups != 2 {
+		t.Errorf("got %d lookups, want 2", lookups)
+	}
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
 
 	"collectd.org/cdtime"
 	"collectd.org/meta"
@@ -10,26 +12,51 @@ import (
 
 // jsonValueList represents the format used by collectd's JSON export.
 type jsonValueList struct {
-	Values         []json.Number `json:"values"`
-	DSTypes        []string      `json:"dstypes"`
-	DSNames        []string      `json:"dsnames,omitempty"`
-	Time           cdtime.Time   `json:"time"`
-	Interval       cdtime.Time   `json:"interval"`
-	Host           string        `json:"host"`
-	Plugin         string        `json:"plugin"`
-	PluginInstance string        `json:"plugin_instance,omitempty"`
-	Type           string        `json:"type"`
-	TypeInstance   string        `json:"type_instance,omitempty"`
-	Meta           meta.Data     `json:"meta,omitempty"`
+	Values         []json.RawMessage `json:"values"`
+	DSTypes        []string          `json:"dstypes"`
+	DSNames        []string          `json:"dsnames,omitempty"`
+	Time           cdtime.Time       `json:"time"`
+	Interval       cdtime.Time       `json:"interval"`
+	Host           string            `json:"host"`
+	Plugin         string            `json:"plugin"`
+	PluginInstance string            `json:"plugin_instance,omitempty"`
+	Type           string            `json:"type"`
+	TypeInstance   string            `json:"type_instance,omitempty"`
+	Meta           meta.Data         `json:"meta,omitempty"`
+	ValueMeta      []meta.Data       `json:"value_meta,omitempty"`
 }
 
 // MarshalJSON implements the "encoding/json".Marshaler interface for
-// ValueList.
+// ValueList. Gauges are formatted like collectd's write_http plugin does; use
+// JSONMarshaler to customize this.
 func (vl *ValueList) MarshalJSON() ([]byte, error) {
+	return JSONMarshaler{}.Marshal(vl)
+}
+
+// JSONMarshaler marshals value lists to JSON with custom formatting of gauge
+// values. The zero value is equivalent to ValueList.MarshalJSON.
+type JSONMarshaler struct {
+	// FormatGauge returns the JSON representation of a gauge value, e.g.
+	// using strconv.FormatFloat. The result must be a valid JSON number.
+	// FormatGauge is not called for NaN, which is always encoded as
+	// null. If nil, gauges are formatted with 15 significant digits,
+	// like collectd's write_http plugin does.
+	FormatGauge func(float64) string
+}
+
+// Marshal returns the JSON encoding of vl.
+func (m JSONMarshaler) Marshal(vl *ValueList) ([]byte, error) {
+	formatGauge := m.FormatGauge
+	if formatGauge == nil {
+		formatGauge = func(f float64) string {
+			return fmt.Sprintf("%.15g", f)
+		}
+	}
+
 	jvl := jsonValueList{
-		Values:         make([]json.Number, len(vl.Values)),
+		Values:         make([]json.RawMessage, len(vl.Values)),
 		DSTypes:        make([]string, len(vl.Values)),
-		DSNames:        make([]string, len(vl.Values)),
+		DSNames:        vl.ResolvedDSNames(),
 		Time:           cdtime.New(vl.Time),
 		Interval:       cdtime.NewDuration(vl.Interval),
 		Host:           vl.Host,
@@ -38,21 +65,28 @@ func (vl *ValueList) MarshalJSON() ([]byte, error) {
 		Type:           vl.Type,
 		TypeInstance:   vl.TypeInstance,
 		Meta:           vl.Meta,
+		ValueMeta:      vl.ValueMeta,
 	}
 
 	for i, v := range vl.Values {
 		switch v := v.(type) {
 		case Gauge:
-			jvl.Values[i] = json.Number(fmt.Sprintf("%.15g", v))
+			if math.IsNaN(float64(v)) {
+				// collectd's write_http plugin encodes NaN as null.
+				jvl.Values[i] = json.RawMessage("null")
+			} else {
+				jvl.Values[i] = json.RawMessage(formatGauge(float64(v)))
+			}
 		case Derive:
-			jvl.Values[i] = json.Number(fmt.Sprintf("%d", v))
+			jvl.Values[i] = json.RawMessage(fmt.Sprintf("%d", v))
 		case Counter:
-			jvl.Values[i] = json.Number(fmt.Sprintf("%d", v))
+			jvl.Values[i] = json.RawMessage(fmt.Sprintf("%d", v))
+		case Absolute:
+			jvl.Values[i] = json.RawMessage(fmt.Sprintf("%d", v))
 		default:
 			return nil, fmt.Errorf("unexpected data source type: %T", v)
 		}
 		jvl.DSTypes[i] = v.Type()
-		jvl.DSNames[i] = vl.DSName(i)
 	}
 
 	return json.Marshal(jvl)
@@ -88,7 +122,16 @@ func (vl *ValueList) UnmarshalJSON(data []byte) error {
 			len(jvl.Values), len(jvl.DSTypes))
 	}
 
-	for i, n := range jvl.Values {
+	for i, raw := range jvl.Values {
+		if string(raw) == "null" {
+			if jvl.DSTypes[i] != "gauge" {
+				return fmt.Errorf("unexpected null value for data source type %q", jvl.DSTypes[i])
+			}
+			vl.Values[i] = Gauge(math.NaN())
+			continue
+		}
+
+		n := json.Number(raw)
 		switch jvl.DSTypes[i] {
 		case "gauge":
 			v, err := n.Float64()
@@ -103,11 +146,19 @@ func (vl *ValueList) UnmarshalJSON(data []byte) error {
 			}
 			vl.Values[i] = Derive(v)
 		case "counter":
-			v, err := n.Int64()
+			// Counter and Absolute are unsigned 64 bit integers,
+			// which may exceed the range of n.Int64().
+			v, err := strconv.ParseUint(string(n), 10, 64)
 			if err != nil {
 				return err
 			}
 			vl.Values[i] = Counter(v)
+		case "absolute":
+			v, err := strconv.ParseUint(string(n), 10, 64)
+			if err != nil {
+				return err
+			}
+			vl.Values[i] = Absolute(v)
 		default:
 			return fmt.Errorf("unexpected data source type: %q", jvl.DSTypes[i])
 		}
@@ -120,5 +171,10 @@ func (vl *ValueList) UnmarshalJSON(data []byte) error {
 
 	vl.Meta = jvl.Meta
 
+	if jvl.ValueMeta != nil && len(jvl.ValueMeta) != len(vl.Values) {
+		return fmt.Errorf("invalid data: %d value(s), %d value meta data", len(vl.Values), len(jvl.ValueMeta))
+	}
+	vl.ValueMeta = jvl.ValueMeta
+
 	return nil
 }
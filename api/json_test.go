@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"reflect"
+	"strconv"
 	"testing"
 	"time"
 
@@ -52,6 +54,207 @@ func TestValueList(t *testing.T) {
 	}
 }
 
+func TestValueList_JSON(t *testing.T) {
+	cases := []struct {
+		title string
+		vl    ValueList
+		want  string
+	}{
+		{
+			title: "multiple values",
+			vl: ValueList{
+				Identifier: Identifier{
+					Host:           "example.com",
+					Plugin:         "golang",
+					PluginInstance: "test",
+					Type:           "if_octets",
+				},
+				Time:     time.Unix(1426585562, 0),
+				Interval: 10 * time.Second,
+				Values:   []Value{Derive(1), Derive(2)},
+				DSNames:  []string{"rx", "tx"},
+			},
+			want: `{"values":[1,2],"dstypes":["derive","derive"],"dsnames":["rx","tx"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","plugin_instance":"test","type":"if_octets"}`,
+		},
+		{
+			title: "default DS names",
+			vl: ValueList{
+				Identifier: Identifier{
+					Host:   "example.com",
+					Plugin: "golang",
+					Type:   "counter",
+				},
+				Time:     time.Unix(1426585562, 0),
+				Interval: 10 * time.Second,
+				Values:   []Value{Counter(42)},
+				DSNames:  []string{"value"},
+			},
+			want: `{"values":[42],"dstypes":["counter"],"dsnames":["value"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","type":"counter"}`,
+		},
+		{
+			title: "large unsigned values",
+			vl: ValueList{
+				Identifier: Identifier{
+					Host:   "example.com",
+					Plugin: "golang",
+					Type:   "test",
+				},
+				Time:     time.Unix(1426585562, 0),
+				Interval: 10 * time.Second,
+				Values:   []Value{Counter(math.MaxUint64), Absolute(1 << 63)},
+				DSNames:  []string{"counter", "absolute"},
+			},
+			want: `{"values":[18446744073709551615,9223372036854775808],"dstypes":["counter","absolute"],"dsnames":["counter","absolute"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","type":"test"}`,
+		},
+		{
+			title: "NaN",
+			vl: ValueList{
+				Identifier: Identifier{
+					Host:   "example.com",
+					Plugin: "golang",
+					Type:   "gauge",
+				},
+				Time:     time.Unix(1426585562, 0),
+				Interval: 10 * time.Second,
+				Values:   []Value{Gauge(math.NaN())},
+				DSNames:  []string{"value"},
+			},
+			want: `{"values":[null],"dstypes":["gauge"],"dsnames":["value"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","type":"gauge"}`,
+		},
+		{
+			title: "meta data",
+			vl: ValueList{
+				Identifier: Identifier{
+					Host:   "example.com",
+					Plugin: "golang",
+					Type:   "gauge",
+				},
+				Time:     time.Unix(1426585562, 0),
+				Interval: 10 * time.Second,
+				Values:   []Value{Gauge(42)},
+				DSNames:  []string{"value"},
+				Meta: meta.Data{
+					"bool":   meta.Bool(true),
+					"int64":  meta.Int64(-23),
+					"string": meta.String("foo"),
+				},
+			},
+			want: `{"values":[42],"dstypes":["gauge"],"dsnames":["value"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","type":"gauge","meta":{"bool":true,"int64":-23,"string":"foo"}}`,
+		},
+		{
+			title: "value meta data",
+			vl: ValueList{
+				Identifier: Identifier{
+					Host:   "example.com",
+					Plugin: "golang",
+					Type:   "if_octets",
+				},
+				Time:      time.Unix(1426585562, 0),
+				Interval:  10 * time.Second,
+				Values:    []Value{Derive(1), Derive(2)},
+				DSNames:   []string{"rx", "tx"},
+				ValueMeta: []meta.Data{nil, {"unit": meta.String("bytes")}},
+			},
+			want: `{"values":[1,2],"dstypes":["derive","derive"],"dsnames":["rx","tx"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","type":"if_octets","value_meta":[null,{"unit":"bytes"}]}`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := json.Marshal(&tc.vl)
+			if err != nil {
+				t.Fatalf("json.Marshal() = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("json.Marshal() differs (+got/-want):\n%s", diff)
+			}
+
+			var vl ValueList
+			if err := json.Unmarshal(got, &vl); err != nil {
+				t.Fatalf("json.Unmarshal() = %v", err)
+			}
+
+			opts := []cmp.Option{
+				cmp.AllowUnexported(meta.Entry{}),
+				cmp.Comparer(func(a, b Gauge) bool {
+					return a == b || (math.IsNaN(float64(a)) && math.IsNaN(float64(b)))
+				}),
+			}
+			if diff := cmp.Diff(tc.vl, vl, opts...); diff != "" {
+				t.Errorf("json.Unmarshal() differs (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJSONMarshaler(t *testing.T) {
+	vl := &ValueList{
+		Identifier: Identifier{
+			Host:   "example.com",
+			Plugin: "golang",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1426585562, 0),
+		Interval: 10 * time.Second,
+		Values:   []Value{Gauge(1e21), Gauge(math.NaN())},
+		DSNames:  []string{"big", "nan"},
+	}
+
+	cases := []struct {
+		title   string
+		m       JSONMarshaler
+		want    string
+		wantErr bool
+	}{
+		{
+			title: "default",
+			want:  `{"values":[1e+21,null],"dstypes":["gauge","gauge"],"dsnames":["big","nan"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","type":"gauge"}`,
+		},
+		{
+			title: "custom",
+			m: JSONMarshaler{
+				FormatGauge: func(f float64) string {
+					return strconv.FormatFloat(f, 'f', 2, 64)
+				},
+			},
+			want: `{"values":[1000000000000000000000.00,null],"dstypes":["gauge","gauge"],"dsnames":["big","nan"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","type":"gauge"}`,
+		},
+		{
+			title: "invalid JSON",
+			m: JSONMarshaler{
+				FormatGauge: func(float64) string {
+					return "not a number"
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := tc.m.Marshal(vl)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("JSONMarshaler.Marshal() = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("JSONMarshaler.Marshal() differs (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValueList_UnmarshalJSON_ValueMeta(t *testing.T) {
+	data := `{"values":[1,2],"dstypes":["derive","derive"],"time":1426585562.000,"interval":10.000,"host":"example.com","plugin":"golang","type":"if_octets","value_meta":[{"unit":"bytes"}]}`
+
+	var vl ValueList
+	if err := json.Unmarshal([]byte(data), &vl); err == nil {
+		t.Errorf("json.Unmarshal() = %v, want error", err)
+	}
+}
+
 func ExampleValueList_UnmarshalJSON() {
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		data, err := ioutil.ReadAll(r.Body)
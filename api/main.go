@@ -5,6 +5,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -48,6 +51,15 @@ type Counter uint64
 // Type returns "counter".
 func (v Counter) Type() string { return "counter" }
 
+// Absolute represents a counter metric value that is reset every time it is
+// read, such as the number of requests since the last read. Use of this type
+// is discouraged; collectd only supports it for legacy reasons.
+// This is Go's equivalent to the C type "absolute_t".
+type Absolute uint64
+
+// Type returns "absolute".
+func (v Absolute) Type() string { return "absolute" }
+
 // Identifier identifies one metric.
 type Identifier struct {
 	Host                   string
@@ -55,30 +67,95 @@ type Identifier struct {
 	Type, TypeInstance     string
 }
 
-// ParseIdentifier parses the identifier encoded in s and returns it.
+// ParseIdentifier parses the identifier encoded in s and returns it. It is the
+// inverse of Identifier.String: a backslash escapes the following character,
+// so that fields may contain slashes and the plugin and type may contain
+// hyphens.
 func ParseIdentifier(s string) (Identifier, error) {
-	fields := strings.Split(s, "/")
+	fields := splitUnescaped(s, '/', -1)
 	if len(fields) != 3 {
 		return Identifier{}, fmt.Errorf("not a valid identifier: %q", s)
 	}
 
-	id := Identifier{
-		Host:   fields[0],
-		Plugin: fields[1],
-		Type:   fields[2],
+	parts := []string{fields[0]}
+	for _, f := range fields[1:] {
+		nameInstance := splitUnescaped(f, '-', 2)
+		if len(nameInstance) == 1 {
+			nameInstance = append(nameInstance, "")
+		}
+		parts = append(parts, nameInstance...)
+	}
+
+	for i, p := range parts {
+		var err error
+		if parts[i], err = unescapeIdentifier(p); err != nil {
+			return Identifier{}, fmt.Errorf("not a valid identifier: %q: %w", s, err)
+		}
+	}
+
+	return Identifier{
+		Host:           parts[0],
+		Plugin:         parts[1],
+		PluginInstance: parts[2],
+		Type:           parts[3],
+		TypeInstance:   parts[4],
+	}, nil
+}
+
+// splitUnescaped splits s at each occurrence of sep that is not preceded by a
+// backslash. Like with strings.SplitN, n determines the maximum number of
+// fields returned; if n is negative, all fields are returned.
+func splitUnescaped(s string, sep byte, n int) []string {
+	var (
+		fields []string
+		start  int
+	)
+	for i := 0; i < len(s) && n != len(fields)+1; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			fields = append(fields, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, s[start:])
+}
+
+// unescapeIdentifier removes the backslashes added by escapeIdentifier.
+func unescapeIdentifier(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
 	}
 
-	if i := strings.Index(id.Plugin, "-"); i != -1 {
-		id.PluginInstance = id.Plugin[i+1:]
-		id.Plugin = id.Plugin[:i]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			if i == len(s) {
+				return "", errors.New("trailing backslash")
+			}
+		}
+		b.WriteByte(s[i])
 	}
+	return b.String(), nil
+}
 
-	if i := strings.Index(id.Type, "-"); i != -1 {
-		id.TypeInstance = id.Type[i+1:]
-		id.Type = id.Type[:i]
+// escapeIdentifier prefixes backslashes and the characters in special with a
+// backslash.
+func escapeIdentifier(s, special string) string {
+	if !strings.ContainsAny(s, special+`\`) {
+		return s
 	}
 
-	return id, nil
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' || strings.IndexByte(special, s[i]) != -1 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
 }
 
 // ValueList represents one (set of) data point(s) of one metric. It is Go's
@@ -90,6 +167,11 @@ type ValueList struct {
 	Values   []Value
 	DSNames  []string
 	Meta     meta.Data
+	// ValueMeta optionally holds meta data for individual data sources,
+	// e.g. ValueMeta[i] annotates Values[i]. If not nil, it must have the
+	// same length as Values. Nil entries are allowed. Meta applies to all
+	// values, i.e. to the value list as a whole.
+	ValueMeta []meta.Data
 }
 
 // DSName returns the name of the data source at the given index. If vl.DSNames
@@ -105,6 +187,17 @@ func (vl *ValueList) DSName(index int) string {
 	return "value"
 }
 
+// ResolvedDSNames returns the names of all data sources in vl, as returned by
+// DSName. Unlike the DSNames field, the returned slice always has the same
+// length as vl.Values.
+func (vl *ValueList) ResolvedDSNames() []string {
+	names := make([]string, len(vl.Values))
+	for i := range vl.Values {
+		names[i] = vl.DSName(i)
+	}
+	return names
+}
+
 // Check does a sanity check on vl and returns any errors it finds.
 func (vl *ValueList) Check() error {
 	var err error
@@ -126,6 +219,8 @@ func (vl *ValueList) Check() error {
 	}
 	if vl.Interval == 0 {
 		err = multierr.Append(err, errors.New("Interval is unset"))
+	} else if vl.Interval < 0 {
+		err = multierr.Append(err, fmt.Errorf("Interval is negative (%v)", vl.Interval))
 	}
 	if len(vl.Values) == 0 {
 		err = multierr.Append(err, errors.New("Values is unset"))
@@ -133,6 +228,9 @@ func (vl *ValueList) Check() error {
 	if n, v := len(vl.DSNames), len(vl.Values); n != 0 && v != 0 && n != v {
 		err = multierr.Append(err, fmt.Errorf("number of values (%d) and number of DS names (%d) don't match", v, n))
 	}
+	if n, v := len(vl.ValueMeta), len(vl.Values); n != 0 && v != 0 && n != v {
+		err = multierr.Append(err, fmt.Errorf("number of values (%d) and number of value meta data (%d) don't match", v, n))
+	}
 
 	nameCount := make(map[string]int)
 	for _, name := range vl.DSNames {
@@ -158,17 +256,111 @@ func (vl *ValueList) Clone() *ValueList {
 
 	vlCopy := *vl
 
-	vlCopy.Values = make([]Value, len(vl.Values))
-	copy(vlCopy.Values, vl.Values)
+	if vl.Values != nil {
+		vlCopy.Values = make([]Value, len(vl.Values))
+		copy(vlCopy.Values, vl.Values)
+	}
 
-	vlCopy.DSNames = make([]string, len(vl.DSNames))
-	copy(vlCopy.DSNames, vl.DSNames)
+	// DSName() treats a nil DSNames field specially, so don't turn it
+	// into an empty slice.
+	if vl.DSNames != nil {
+		vlCopy.DSNames = make([]string, len(vl.DSNames))
+		copy(vlCopy.DSNames, vl.DSNames)
+	}
 
 	vlCopy.Meta = vl.Meta.Clone()
 
+	if vl.ValueMeta != nil {
+		vlCopy.ValueMeta = make([]meta.Data, len(vl.ValueMeta))
+		for i, md := range vl.ValueMeta {
+			vlCopy.ValueMeta[i] = md.Clone()
+		}
+	}
+
 	return &vlCopy
 }
 
+// Equal reports whether vl and other hold the same data. Times are compared
+// using time.Time.Equal, values must have the same concrete type and value.
+// Unlike the == operator for floating point numbers, Equal considers NaN
+// gauges to be equal to one another, so that a value list is always equal to
+// its clone. The same applies to float64 meta data entries. Nil and empty
+// DSNames, Meta and ValueMeta fields are considered equal.
+//
+// Because of this method, github.com/google/go-cmp uses Equal to compare value
+// lists and ignores options targeting their fields, such as
+// cmpopts.IgnoreFields. Use a transformer on *ValueList instead.
+func (vl *ValueList) Equal(other *ValueList) bool {
+	if vl == nil || other == nil {
+		return vl == other
+	}
+
+	if vl.Identifier != other.Identifier ||
+		!vl.Time.Equal(other.Time) ||
+		vl.Interval != other.Interval ||
+		len(vl.Values) != len(other.Values) ||
+		len(vl.DSNames) != len(other.DSNames) ||
+		len(vl.ValueMeta) != len(other.ValueMeta) {
+		return false
+	}
+
+	for i, v := range vl.Values {
+		if !valueEqual(v, other.Values[i]) {
+			return false
+		}
+	}
+
+	for i, name := range vl.DSNames {
+		if name != other.DSNames[i] {
+			return false
+		}
+	}
+
+	if !metaEqual(vl.Meta, other.Meta) {
+		return false
+	}
+
+	for i, md := range vl.ValueMeta {
+		if !metaEqual(md, other.ValueMeta[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func valueEqual(a, b Value) bool {
+	if ga, ok := a.(Gauge); ok {
+		gb, ok := b.(Gauge)
+		return ok && (ga == gb || math.IsNaN(float64(ga)) && math.IsNaN(float64(gb)))
+	}
+	return a == b
+}
+
+func metaEqual(a, b meta.Data) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, ea := range a {
+		eb, ok := b[k]
+		if !ok {
+			return false
+		}
+
+		fa, okA := ea.Float64()
+		fb, okB := eb.Float64()
+		if okA && okB && math.IsNaN(fa) && math.IsNaN(fb) {
+			continue
+		}
+		if ea != eb {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Writer are objects accepting a ValueList for writing, for example to the
 // network.
 type Writer interface {
@@ -183,19 +375,58 @@ func (f WriterFunc) Write(ctx context.Context, vl *ValueList) error {
 	return f(ctx, vl)
 }
 
-// String returns a string representation of the Identifier.
+// String returns a string representation of the Identifier. Slashes, as well
+// as hyphens in the plugin and type, are escaped with a backslash, so that
+// ParseIdentifier can restore the Identifier. Identifiers without these
+// characters or backslashes are not modified.
+//
+// collectd doesn't understand this escaping, so the result is not suitable as
+// an identifier in collectd's protocols if any characters were escaped.
 func (id Identifier) String() string {
-	str := id.Host + "/" + id.Plugin
+	str := escapeIdentifier(id.Host, "/") + "/" + escapeIdentifier(id.Plugin, "/-")
 	if id.PluginInstance != "" {
-		str += "-" + id.PluginInstance
+		str += "-" + escapeIdentifier(id.PluginInstance, "/")
 	}
-	str += "/" + id.Type
+	str += "/" + escapeIdentifier(id.Type, "/-")
 	if id.TypeInstance != "" {
-		str += "-" + id.TypeInstance
+		str += "-" + escapeIdentifier(id.TypeInstance, "/")
 	}
 	return str
 }
 
+// Match reports whether id matches pattern. Each field of pattern is a shell
+// wildcard pattern as understood by path.Match, i.e. "*" and "?" as well as
+// character classes are supported. Empty fields in pattern match any value.
+// Malformed patterns do not match anything.
+func (id Identifier) Match(pattern Identifier) bool {
+	for _, f := range []struct{ pattern, value string }{
+		{pattern.Host, id.Host},
+		{pattern.Plugin, id.Plugin},
+		{pattern.PluginInstance, id.PluginInstance},
+		{pattern.Type, id.Type},
+		{pattern.TypeInstance, id.TypeInstance},
+	} {
+		if f.pattern == "" {
+			continue
+		}
+		if ok, _ := path.Match(f.pattern, f.value); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hash returns a 64-bit FNV-1a hash of the identifier. Fields are separated by
+// a zero byte, so that e.g. "ab"/"c" and "a"/"bc" hash differently.
+func (id Identifier) hash() uint64 {
+	h := fnv.New64a()
+	for _, f := range []string{id.Host, id.Plugin, id.PluginInstance, id.Type, id.TypeInstance} {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
 // Fanout implements a multiplexer for Writer, i.e. each ValueList written to
 // it is copied and written to each Writer.
 type Fanout []Writer
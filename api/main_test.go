@@ -3,11 +3,13 @@ package api_test
 import (
 	"context"
 	"errors"
+	"math"
 	"sync"
 	"testing"
 	"time"
 
 	"collectd.org/api"
+	"collectd.org/meta"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -55,6 +57,7 @@ func TestParseIdentifier(t *testing.T) {
 	failures := []string{
 		"example.com/golang",
 		"example.com/golang/gauge/extra",
+		`example.com/golang/gauge\`,
 	}
 
 	for _, c := range failures {
@@ -92,6 +95,95 @@ func TestIdentifierString(t *testing.T) {
 	}
 }
 
+func TestIdentifier_Match(t *testing.T) {
+	id := api.Identifier{
+		Host:           "example.com",
+		Plugin:         "cpu",
+		PluginInstance: "0",
+		Type:           "cpu",
+		TypeInstance:   "idle",
+	}
+
+	cases := []struct {
+		pattern api.Identifier
+		want    bool
+	}{
+		{api.Identifier{}, true},
+		{api.Identifier{Host: "*", Plugin: "*", PluginInstance: "*", Type: "*", TypeInstance: "*"}, true},
+		{id, true},
+		// host
+		{api.Identifier{Host: "*.com"}, true},
+		{api.Identifier{Host: "example.???"}, true},
+		{api.Identifier{Host: "example.org"}, false},
+		// plugin
+		{api.Identifier{Plugin: "c*"}, true},
+		{api.Identifier{Plugin: "memory"}, false},
+		// plugin instance
+		{api.Identifier{PluginInstance: "[0-3]"}, true},
+		{api.Identifier{PluginInstance: "1"}, false},
+		// type
+		{api.Identifier{Type: "?pu"}, true},
+		{api.Identifier{Type: "cpu?"}, false},
+		// type instance
+		{api.Identifier{TypeInstance: "i*e"}, true},
+		{api.Identifier{TypeInstance: "user"}, false},
+		// all fields must match
+		{api.Identifier{Host: "example.com", TypeInstance: "user"}, false},
+		// malformed pattern
+		{api.Identifier{Host: "["}, false},
+	}
+
+	for _, tc := range cases {
+		if got := id.Match(tc.pattern); got != tc.want {
+			t.Errorf("%#v.Match(%#v) = %v, want %v", id, tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestIdentifier_roundTrip(t *testing.T) {
+	cases := []struct {
+		id   api.Identifier
+		want string
+	}{
+		{
+			id:   api.Identifier{Host: "example.com", Plugin: "golang", PluginInstance: "a-b", Type: "gauge", TypeInstance: "c-d"},
+			want: "example.com/golang-a-b/gauge-c-d",
+		},
+		{
+			id:   api.Identifier{Host: "example.com/foo", Plugin: "golang", Type: "gauge"},
+			want: `example.com\/foo/golang/gauge`,
+		},
+		{
+			id:   api.Identifier{Host: "example.com", Plugin: "df", PluginInstance: "/var/log", Type: "df_complex", TypeInstance: "free/used"},
+			want: `example.com/df-\/var\/log/df_complex-free\/used`,
+		},
+		{
+			id:   api.Identifier{Host: "example.com", Plugin: "go-lang", PluginInstance: "-", Type: "gau-ge"},
+			want: `example.com/go\-lang--/gau\-ge`,
+		},
+		{
+			id:   api.Identifier{Host: "my-host", Plugin: "go-lang", Type: "gauge"},
+			want: `my-host/go\-lang/gauge`,
+		},
+		{
+			id:   api.Identifier{Host: `C:\`, Plugin: "golang", Type: "gauge", TypeInstance: `\-/`},
+			want: `C:\\/golang/gauge-\\-\/`,
+		},
+	}
+
+	for _, tc := range cases {
+		got := tc.id.String()
+		if got != tc.want {
+			t.Errorf("%#v.String() = %q, want %q", tc.id, got, tc.want)
+		}
+
+		id, err := api.ParseIdentifier(got)
+		if err != nil || id != tc.id {
+			t.Errorf("ParseIdentifier(%q) = (%#v, %v), want (%#v, %v)", got, id, err, tc.id, nil)
+		}
+	}
+}
+
 type testWriter struct {
 	got *api.ValueList
 	wg  *sync.WaitGroup
@@ -117,6 +209,27 @@ func (testError) Error() string {
 	return "test error"
 }
 
+func TestWriterFunc(t *testing.T) {
+	wantErr := errors.New("test error")
+	want := &api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "TestWriterFunc", Type: "gauge"},
+		Values:     []api.Value{api.Gauge(42)},
+	}
+
+	var got *api.ValueList
+	var w api.Writer = api.WriterFunc(func(_ context.Context, vl *api.ValueList) error {
+		got = vl
+		return wantErr
+	})
+
+	if err := w.Write(context.Background(), want); !errors.Is(err, wantErr) {
+		t.Errorf("WriterFunc.Write() = %v, want %v", err, wantErr)
+	}
+	if got != want {
+		t.Errorf("WriterFunc.Write() called the function with %v, want %v", got, want)
+	}
+}
+
 func TestFanout(t *testing.T) {
 	cases := []struct {
 		title         string
@@ -212,6 +325,50 @@ func TestFanout(t *testing.T) {
 	}
 }
 
+func TestValueList_ResolvedDSNames(t *testing.T) {
+	cases := []struct {
+		title   string
+		values  []api.Value
+		dsNames []string
+		want    []string
+	}{
+		{
+			title:  "single value",
+			values: []api.Value{api.Gauge(42)},
+			want:   []string{"value"},
+		},
+		{
+			title:  "multiple values",
+			values: []api.Value{api.Derive(1), api.Derive(2)},
+			want:   []string{"0", "1"},
+		},
+		{
+			title:   "explicit names",
+			values:  []api.Value{api.Derive(1), api.Derive(2)},
+			dsNames: []string{"rx", "tx"},
+			want:    []string{"rx", "tx"},
+		},
+		{
+			title: "no values",
+			want:  []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			vl := &api.ValueList{
+				Values:  tc.values,
+				DSNames: tc.dsNames,
+			}
+
+			got := vl.ResolvedDSNames()
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ResolvedDSNames() differs (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestValueList_Check(t *testing.T) {
 	baseVL := api.ValueList{
 		Identifier: api.Identifier{
@@ -287,6 +444,13 @@ func TestValueList_Check(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			title: "negative interval",
+			modify: func(vl *api.ValueList) {
+				vl.Interval = -10 * time.Second
+			},
+			wantErr: true,
+		},
 		{
 			title: "without values",
 			modify: func(vl *api.ValueList) {
@@ -331,6 +495,19 @@ func TestValueList_Check(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			title: "value meta data",
+			modify: func(vl *api.ValueList) {
+				vl.ValueMeta = []meta.Data{{"key": meta.String("value")}}
+			},
+		},
+		{
+			title: "surplus value meta data",
+			modify: func(vl *api.ValueList) {
+				vl.ValueMeta = []meta.Data{nil, nil}
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -348,3 +525,219 @@ func TestValueList_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestValueList_Clone(t *testing.T) {
+	orig := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestValueList_Clone",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1589283551, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+		DSNames:  []string{"value"},
+		Meta: meta.Data{
+			"key": meta.String("value"),
+		},
+		ValueMeta: []meta.Data{
+			{"key": meta.String("value")},
+		},
+	}
+	want := &api.ValueList{
+		Identifier: orig.Identifier,
+		Time:       orig.Time,
+		Interval:   orig.Interval,
+		Values:     []api.Value{api.Gauge(42)},
+		DSNames:    []string{"value"},
+		Meta: meta.Data{
+			"key": meta.String("value"),
+		},
+		ValueMeta: []meta.Data{
+			{"key": meta.String("value")},
+		},
+	}
+
+	opts := []cmp.Option{cmp.AllowUnexported(meta.Entry{})}
+
+	got := orig.Clone()
+	if diff := cmp.Diff(want, got, opts...); diff != "" {
+		t.Errorf("Clone() differs (+got/-want):\n%s", diff)
+	}
+
+	got.Values[0] = api.Gauge(23)
+	got.DSNames[0] = "modified"
+	got.Meta["key"] = meta.String("modified")
+	got.ValueMeta[0]["key"] = meta.String("modified")
+
+	if diff := cmp.Diff(want, orig, opts...); diff != "" {
+		t.Errorf("modifying the clone changed the original (+got/-want):\n%s", diff)
+	}
+
+	t.Run("nil DSNames", func(t *testing.T) {
+		vl := orig.Clone()
+		vl.DSNames = nil
+
+		got := vl.Clone()
+		if got.DSNames != nil {
+			t.Errorf("Clone().DSNames = %#v, want nil", got.DSNames)
+		}
+		if got, want := got.DSName(0), "value"; got != want {
+			t.Errorf("Clone().DSName(0) = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestValueList_Equal(t *testing.T) {
+	base := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestValueList_Equal",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1589283551, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(math.NaN())},
+		DSNames:  []string{"value"},
+		Meta: meta.Data{
+			"key": meta.String("value"),
+			"nan": meta.Float64(math.NaN()),
+		},
+		ValueMeta: []meta.Data{
+			{"key": meta.String("value")},
+		},
+	}
+
+	cases := []struct {
+		title  string
+		modify func(vl *api.ValueList)
+		want   bool
+	}{
+		{
+			title: "clone",
+			want:  true,
+		},
+		{
+			title: "time in different location",
+			modify: func(vl *api.ValueList) {
+				vl.Time = vl.Time.UTC()
+			},
+			want: true,
+		},
+		{
+			title: "empty and nil fields",
+			modify: func(vl *api.ValueList) {
+				vl.DSNames = nil
+				vl.Meta = nil
+				vl.ValueMeta = nil
+			},
+			want: false,
+		},
+		{
+			title: "identifier",
+			modify: func(vl *api.ValueList) {
+				vl.TypeInstance = "modified"
+			},
+		},
+		{
+			title: "time",
+			modify: func(vl *api.ValueList) {
+				vl.Time = vl.Time.Add(time.Nanosecond)
+			},
+		},
+		{
+			title: "interval",
+			modify: func(vl *api.ValueList) {
+				vl.Interval = time.Minute
+			},
+		},
+		{
+			title: "NaN and number",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{api.Gauge(42)}
+			},
+		},
+		{
+			title: "value type",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{api.Derive(0)}
+			},
+		},
+		{
+			title: "number of values",
+			modify: func(vl *api.ValueList) {
+				vl.Values = append(vl.Values, api.Gauge(42))
+			},
+		},
+		{
+			title: "DSNames",
+			modify: func(vl *api.ValueList) {
+				vl.DSNames = []string{"modified"}
+			},
+		},
+		{
+			title: "meta value",
+			modify: func(vl *api.ValueList) {
+				vl.Meta["key"] = meta.String("modified")
+			},
+		},
+		{
+			title: "meta type",
+			modify: func(vl *api.ValueList) {
+				vl.Meta["nan"] = meta.String("NaN")
+			},
+		},
+		{
+			title: "meta key",
+			modify: func(vl *api.ValueList) {
+				delete(vl.Meta, "key")
+				vl.Meta["other"] = meta.String("value")
+			},
+		},
+		{
+			title: "value meta",
+			modify: func(vl *api.ValueList) {
+				vl.ValueMeta[0]["key"] = meta.Bool(true)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			vl := base.Clone()
+			if tc.modify != nil {
+				tc.modify(vl)
+			}
+
+			if got := base.Equal(vl); got != tc.want {
+				t.Errorf("%v.Equal(%v) = %v, want %v", base, vl, got, tc.want)
+			}
+			if got := vl.Equal(base); got != tc.want {
+				t.Errorf("%v.Equal(%v) = %v, want %v", vl, base, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var vl *api.ValueList
+		if !vl.Equal(nil) {
+			t.Error("(*api.ValueList)(nil).Equal(nil) = false, want true")
+		}
+		if base.Equal(nil) {
+			t.Errorf("%v.Equal(nil) = true, want false", base)
+		}
+	})
+
+	t.Run("nil and empty", func(t *testing.T) {
+		a := &api.ValueList{Identifier: base.Identifier}
+		b := &api.ValueList{
+			Identifier: base.Identifier,
+			Values:     []api.Value{},
+			DSNames:    []string{},
+			Meta:       meta.Data{},
+		}
+		if !a.Equal(b) {
+			t.Errorf("%v.Equal(%v) = false, want true", a, b)
+		}
+	})
+}
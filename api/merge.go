@@ -0,0 +1,108 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"sync"
+
+	"collectd.org/meta"
+)
+
+// MergeByIdentifier combines consecutive value lists with the same identifier
+// and time into a single value list, concatenating their values, data source
+// names and per-value meta data. This is useful when a source sends each data
+// source of a multi-value type as a separate value list. The interval and meta
+// data of the first value list of each run are used. The value lists in vls
+// are not modified.
+func MergeByIdentifier(vls []*ValueList) []*ValueList {
+	var ret []*ValueList
+	for _, vl := range vls {
+		if n := len(ret); n > 0 && mergeable(ret[n-1], vl) {
+			merge(ret[n-1], vl)
+			continue
+		}
+		ret = append(ret, vl.Clone())
+	}
+
+	return ret
+}
+
+// Merger is a Writer that combines consecutive value lists with the same
+// identifier and time, see MergeByIdentifier. Since it is not known whether
+// the next value list belongs to the same metric, the last value list is held
+// back until a different value list is written or Flush is called.
+type Merger struct {
+	Writer Writer
+
+	mu      sync.Mutex
+	pending *ValueList
+}
+
+// Write merges vl into the pending value list. If that is not possible, the
+// pending value list is written to the underlying Writer and a copy of vl
+// becomes the new pending value list.
+func (m *Merger) Write(ctx context.Context, vl *ValueList) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.pending != nil && mergeable(m.pending, vl) {
+		merge(m.pending, vl)
+		return nil
+	}
+
+	err := m.flush(ctx)
+	m.pending = vl.Clone()
+	return err
+}
+
+// Flush writes the pending value list, if any, to the underlying Writer.
+func (m *Merger) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.flush(ctx)
+}
+
+func (m *Merger) flush(ctx context.Context) error {
+	if m.pending == nil {
+		return nil
+	}
+
+	vl := m.pending
+	m.pending = nil
+	return m.Writer.Write(ctx, vl)
+}
+
+func mergeable(a, b *ValueList) bool {
+	return a.Identifier == b.Identifier && a.Time.Equal(b.Time)
+}
+
+// merge appends the values of src to dst. If only one of them has explicit
+// data source names, the default names of the other are used, so that names
+// and values continue to match up.
+func merge(dst, src *ValueList) {
+	switch {
+	case dst.DSNames == nil && src.DSNames == nil:
+		// keep using default names
+	case dst.DSNames == nil:
+		dst.DSNames = append(dst.ResolvedDSNames(), src.DSNames...)
+	default:
+		dst.DSNames = append(dst.DSNames, src.ResolvedDSNames()...)
+	}
+
+	if dst.ValueMeta != nil || src.ValueMeta != nil {
+		dst.ValueMeta = append(resolvedValueMeta(dst), resolvedValueMeta(src)...)
+	}
+
+	dst.Values = append(dst.Values, src.Values...)
+}
+
+// resolvedValueMeta returns a copy of vl.ValueMeta with one entry per value.
+func resolvedValueMeta(vl *ValueList) []meta.Data {
+	ret := make([]meta.Data, len(vl.Values))
+	for i := range ret {
+		if i < len(vl.ValueMeta) {
+			ret[i] = vl.ValueMeta[i].Clone()
+		}
+	}
+	return ret
+}
@@ -0,0 +1,176 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/meta"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMergeByIdentifier(t *testing.T) {
+	id := api.Identifier{
+		Host:   "example.com",
+		Plugin: "interface",
+		Type:   "if_octets",
+	}
+	otherID := id
+	otherID.TypeInstance = "eth0"
+	t0 := time.Unix(1588164686, 0)
+	t1 := t0.Add(10 * time.Second)
+
+	newVL := func(id api.Identifier, tm time.Time, v api.Derive, dsNames ...string) *api.ValueList {
+		return &api.ValueList{
+			Identifier: id,
+			Time:       tm,
+			Interval:   10 * time.Second,
+			Values:     []api.Value{v},
+			DSNames:    dsNames,
+		}
+	}
+
+	cases := []struct {
+		title string
+		in    []*api.ValueList
+		want  []*api.ValueList
+	}{
+		{
+			title: "explicit names",
+			in: []*api.ValueList{
+				newVL(id, t0, 1, "rx"),
+				newVL(id, t0, 2, "tx"),
+			},
+			want: []*api.ValueList{
+				{
+					Identifier: id,
+					Time:       t0,
+					Interval:   10 * time.Second,
+					Values:     []api.Value{api.Derive(1), api.Derive(2)},
+					DSNames:    []string{"rx", "tx"},
+				},
+			},
+		},
+		{
+			title: "implicit names",
+			in: []*api.ValueList{
+				newVL(id, t0, 1),
+				newVL(id, t0, 2),
+			},
+			want: []*api.ValueList{
+				{
+					Identifier: id,
+					Time:       t0,
+					Interval:   10 * time.Second,
+					Values:     []api.Value{api.Derive(1), api.Derive(2)},
+				},
+			},
+		},
+		{
+			title: "mixed names",
+			in: []*api.ValueList{
+				newVL(id, t0, 1),
+				newVL(id, t0, 2, "tx"),
+			},
+			want: []*api.ValueList{
+				{
+					Identifier: id,
+					Time:       t0,
+					Interval:   10 * time.Second,
+					Values:     []api.Value{api.Derive(1), api.Derive(2)},
+					DSNames:    []string{"value", "tx"},
+				},
+			},
+		},
+		{
+			title: "value meta data",
+			in: []*api.ValueList{
+				newVL(id, t0, 1, "rx"),
+				{
+					Identifier: id,
+					Time:       t0,
+					Interval:   10 * time.Second,
+					Values:     []api.Value{api.Derive(2)},
+					DSNames:    []string{"tx"},
+					ValueMeta:  []meta.Data{{"key": meta.String("tx")}},
+				},
+			},
+			want: []*api.ValueList{
+				{
+					Identifier: id,
+					Time:       t0,
+					Interval:   10 * time.Second,
+					Values:     []api.Value{api.Derive(1), api.Derive(2)},
+					DSNames:    []string{"rx", "tx"},
+					ValueMeta:  []meta.Data{nil, {"key": meta.String("tx")}},
+				},
+			},
+		},
+		{
+			title: "different identifier and time",
+			in: []*api.ValueList{
+				newVL(id, t0, 1, "rx"),
+				newVL(otherID, t0, 2, "tx"),
+				newVL(otherID, t1, 3, "tx"),
+			},
+			want: []*api.ValueList{
+				newVL(id, t0, 1, "rx"),
+				newVL(otherID, t0, 2, "tx"),
+				newVL(otherID, t1, 3, "tx"),
+			},
+		},
+		{
+			title: "only consecutive",
+			in: []*api.ValueList{
+				newVL(id, t0, 1, "rx"),
+				newVL(otherID, t0, 2, "rx"),
+				newVL(id, t0, 3, "tx"),
+			},
+			want: []*api.ValueList{
+				newVL(id, t0, 1, "rx"),
+				newVL(otherID, t0, 2, "rx"),
+				newVL(id, t0, 3, "tx"),
+			},
+		},
+	}
+
+	opts := []cmp.Option{cmp.AllowUnexported(meta.Entry{})}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			var in []*api.ValueList
+			for _, vl := range tc.in {
+				in = append(in, vl.Clone())
+			}
+
+			got := api.MergeByIdentifier(in)
+			if diff := cmp.Diff(tc.want, got, opts...); diff != "" {
+				t.Errorf("MergeByIdentifier() differs (+got/-want):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.in, in, opts...); diff != "" {
+				t.Errorf("MergeByIdentifier() modified its argument (+got/-want):\n%s", diff)
+			}
+
+			var written []*api.ValueList
+			m := &api.Merger{
+				Writer: api.WriterFunc(func(_ context.Context, vl *api.ValueList) error {
+					written = append(written, vl)
+					return nil
+				}),
+			}
+			ctx := context.Background()
+			for _, vl := range tc.in {
+				if err := m.Write(ctx, vl); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := m.Flush(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, written, opts...); diff != "" {
+				t.Errorf("Merger wrote (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
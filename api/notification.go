@@ -0,0 +1,43 @@
+package api // import "collectd.org/api"
+
+import (
+	"fmt"
+	"time"
+
+	"collectd.org/meta"
+)
+
+// Severity is the severity of a notification.
+type Severity int
+
+// Severities supported by collectd.
+const (
+	Failure Severity = 1
+	Warning Severity = 2
+	Okay    Severity = 4
+)
+
+// String returns the severity as used by the PUTNOTIF command, e.g. "failure".
+func (s Severity) String() string {
+	switch s {
+	case Failure:
+		return "failure"
+	case Warning:
+		return "warning"
+	case Okay:
+		return "okay"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Notification represents a collectd notification, i.e. an event such as a
+// threshold being exceeded. It is Go's equivalent to the C type
+// "notification_t".
+type Notification struct {
+	Identifier
+	Time     time.Time
+	Severity Severity
+	Message  string
+	Meta     meta.Data
+}
@@ -0,0 +1,20 @@
+package api_test
+
+import (
+	"testing"
+
+	"collectd.org/api"
+)
+
+func TestSeverity_String(t *testing.T) {
+	for s, want := range map[api.Severity]string{
+		api.Failure:     "failure",
+		api.Warning:     "warning",
+		api.Okay:        "okay",
+		api.Severity(3): "Severity(3)",
+	} {
+		if got := s.String(); got != want {
+			t.Errorf("Severity(%d).String() = %q, want %q", int(s), got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultQueueSize is the capacity of a QueueWriter if Size is not set.
+const DefaultQueueSize = 1024
+
+// QueueWriter is a Writer that decouples producers from a (slow) consumer.
+// Write adds value lists to a queue, Run takes them off the queue and writes
+// them to the downstream Writer.
+type QueueWriter struct {
+	// Size is the capacity of the queue. If zero, DefaultQueueSize is used.
+	// Size must not be changed after the first call to Write or Run.
+	Size int
+	// Drop determines what happens when the queue is full. If true, value
+	// lists are dropped. Otherwise, Write blocks until space becomes
+	// available or the context is cancelled.
+	Drop bool
+	// WriteError, if not nil, is called for every value list the downstream
+	// Writer failed to write. If WriteError is nil, errors are logged.
+	WriteError func(vl *ValueList, err error)
+
+	once    sync.Once
+	ch      chan *ValueList
+	dropped uint64
+}
+
+// Write adds a copy of vl to the queue. Dropped value lists are counted, but
+// are not considered an error.
+func (q *QueueWriter) Write(ctx context.Context, vl *ValueList) error {
+	ch := q.queue()
+
+	// Producers may reuse vl after Write returns.
+	vl = vl.Clone()
+
+	if q.Drop {
+		select {
+		case ch <- vl:
+		default:
+			atomic.AddUint64(&q.dropped, 1)
+		}
+		return nil
+	}
+
+	select {
+	case ch <- vl:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run writes queued value lists to w until ctx is cancelled. It then writes
+// the value lists remaining in the queue and returns the context's error.
+// Producers should be stopped before cancelling ctx, otherwise value lists
+// written concurrently may remain in the queue.
+func (q *QueueWriter) Run(ctx context.Context, w Writer) error {
+	ch := q.queue()
+
+	for {
+		select {
+		case vl := <-ch:
+			q.write(ctx, w, vl)
+		case <-ctx.Done():
+			for {
+				select {
+				case vl := <-ch:
+					// ctx is already cancelled; don't pass it to
+					// the writer.
+					q.write(context.Background(), w, vl)
+				default:
+					return ctx.Err()
+				}
+			}
+		}
+	}
+}
+
+// Len returns the number of value lists in the queue.
+func (q *QueueWriter) Len() int {
+	return len(q.queue())
+}
+
+// Dropped returns the number of value lists dropped because the queue was
+// full.
+func (q *QueueWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+func (q *QueueWriter) queue() chan *ValueList {
+	q.once.Do(func() {
+		size := q.Size
+		if size <= 0 {
+			size = DefaultQueueSize
+		}
+		q.ch = make(chan *ValueList, size)
+	})
+
+	return q.ch
+}
+
+func (q *QueueWriter) write(ctx context.Context, w Writer, vl *ValueList) {
+	err := w.Write(ctx, vl)
+	if err == nil {
+		return
+	}
+
+	if q.WriteError == nil {
+		log.Printf("%T.Write(): %v", w, err)
+		return
+	}
+	q.WriteError(vl, err)
+}
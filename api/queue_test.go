@@ -0,0 +1,120 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestQueueWriter(t *testing.T) {
+	ids := []api.Identifier{
+		{Host: "a", Plugin: "cpu", Type: "cpu"},
+		{Host: "b", Plugin: "cpu", Type: "cpu"},
+		{Host: "c", Plugin: "cpu", Type: "cpu"},
+	}
+
+	cases := []struct {
+		title       string
+		size        int
+		drop        bool
+		want        []string
+		wantDropped uint64
+		wantErr     error
+	}{
+		{
+			title: "default size",
+			want:  []string{"a/cpu/cpu", "b/cpu/cpu", "c/cpu/cpu"},
+		},
+		{
+			title:       "drop",
+			size:        2,
+			drop:        true,
+			want:        []string{"a/cpu/cpu", "b/cpu/cpu"},
+			wantDropped: 1,
+		},
+		{
+			title:   "block",
+			size:    2,
+			want:    []string{"a/cpu/cpu", "b/cpu/cpu"},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			q := &api.QueueWriter{
+				Size: tc.size,
+				Drop: tc.drop,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			var err error
+			for _, id := range ids {
+				vl := &api.ValueList{
+					Identifier: id,
+					Values:     []api.Value{api.Gauge(42)},
+				}
+				if err = q.Write(ctx, vl); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("QueueWriter.Write() = %v, want %v", err, tc.wantErr)
+			}
+			if got := q.Dropped(); got != tc.wantDropped {
+				t.Errorf("QueueWriter.Dropped() = %d, want %d", got, tc.wantDropped)
+			}
+
+			// With a cancelled context, Run writes the remaining
+			// value lists and returns.
+			runCtx, runCancel := context.WithCancel(context.Background())
+			runCancel()
+
+			w := &recordingWriter{}
+			if err := q.Run(runCtx, w); !errors.Is(err, context.Canceled) {
+				t.Errorf("QueueWriter.Run() = %v, want %v", err, context.Canceled)
+			}
+
+			if diff := cmp.Diff(tc.want, w.got); diff != "" {
+				t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+			}
+			if got := q.Len(); got != 0 {
+				t.Errorf("QueueWriter.Len() = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestQueueWriter_WriteError(t *testing.T) {
+	wantErr := errors.New("test error")
+
+	var got []error
+	q := &api.QueueWriter{
+		WriteError: func(_ *api.ValueList, err error) {
+			got = append(got, err)
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := q.Write(ctx, &api.ValueList{}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	w := api.WriterFunc(func(context.Context, *api.ValueList) error {
+		return wantErr
+	})
+	if err := q.Run(ctx, w); !errors.Is(err, context.Canceled) {
+		t.Errorf("QueueWriter.Run() = %v, want %v", err, context.Canceled)
+	}
+
+	if len(got) != 1 || !errors.Is(got[0], wantErr) {
+		t.Errorf("WriteError called with %v, want [%v]", got, wantErr)
+	}
+}
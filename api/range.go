@@ -0,0 +1,94 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// RangeChecker is a Writer that enforces the minimum and maximum values
+// declared in a TypesDB before passing value lists on to the next Writer.
+//
+// Only Gauge values are checked: for the other data source types the minimum
+// and maximum apply to the rate, which is not known at this point. Value lists
+// with a type that is not found in TypesDB are passed on unmodified.
+type RangeChecker struct {
+	Writer  Writer
+	TypesDB *TypesDB
+	// Clamp determines how out-of-range values are handled. If true, such
+	// values are set to the closest permissible value. Otherwise, the
+	// entire value list is dropped.
+	Clamp bool
+
+	dropped uint64
+}
+
+// Write checks the values of vl against the corresponding DataSet and passes
+// vl, or a clamped copy of it, to rc.Writer. Dropped value lists are counted,
+// but are not considered an error.
+func (rc *RangeChecker) Write(ctx context.Context, vl *ValueList) error {
+	ds, ok := rc.TypesDB.DataSet(vl.Type)
+	if !ok {
+		return rc.Writer.Write(ctx, vl)
+	}
+
+	if len(ds.Sources) != len(vl.Values) {
+		return fmt.Errorf("len(vl.Values) = %d, want %d", len(vl.Values), len(ds.Sources))
+	}
+
+	var clamped *ValueList
+	for i, dsrc := range ds.Sources {
+		g, ok := vl.Values[i].(Gauge)
+		if !ok || dsrc.inRange(float64(g)) {
+			continue
+		}
+
+		if !rc.Clamp {
+			atomic.AddUint64(&rc.dropped, 1)
+			return nil
+		}
+
+		// Don't modify the argument.
+		if clamped == nil {
+			clamped = vl.Clone()
+		}
+		clamped.Values[i] = Gauge(dsrc.clamp(float64(g)))
+	}
+
+	if clamped != nil {
+		vl = clamped
+	}
+	return rc.Writer.Write(ctx, vl)
+}
+
+// Dropped returns the number of value lists dropped because of out-of-range
+// values.
+func (rc *RangeChecker) Dropped() uint64 {
+	return atomic.LoadUint64(&rc.dropped)
+}
+
+// inRange returns true if v is within dsrc's minimum and maximum. NaN values
+// and unset (NaN) limits are always considered in range.
+func (dsrc DataSource) inRange(v float64) bool {
+	if math.IsNaN(v) {
+		return true
+	}
+	if !math.IsNaN(dsrc.Min) && v < dsrc.Min {
+		return false
+	}
+	if !math.IsNaN(dsrc.Max) && v > dsrc.Max {
+		return false
+	}
+	return true
+}
+
+func (dsrc DataSource) clamp(v float64) float64 {
+	if !math.IsNaN(dsrc.Min) && v < dsrc.Min {
+		return dsrc.Min
+	}
+	if !math.IsNaN(dsrc.Max) && v > dsrc.Max {
+		return dsrc.Max
+	}
+	return v
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRangeChecker(t *testing.T) {
+	db, err := NewTypesDB(strings.NewReader(`
+percent		value:GAUGE:0:100
+derive		value:DERIVE:0:U
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		title       string
+		typ         string
+		value       Value
+		clamp       bool
+		want        Value
+		wantDropped bool
+	}{
+		{"in range", "percent", Gauge(42), false, Gauge(42), false},
+		{"too large", "percent", Gauge(101), false, nil, true},
+		{"too small", "percent", Gauge(-1), false, nil, true},
+		{"clamp too large", "percent", Gauge(101), true, Gauge(100), false},
+		{"clamp too small", "percent", Gauge(-1), true, Gauge(0), false},
+		{"derive is not checked", "derive", Derive(-1), false, Derive(-1), false},
+		{"unknown type", "unknown", Gauge(-1), false, Gauge(-1), false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			var got *ValueList
+			rc := &RangeChecker{
+				Writer: WriterFunc(func(_ context.Context, vl *ValueList) error {
+					got = vl
+					return nil
+				}),
+				TypesDB: db,
+				Clamp:   tc.clamp,
+			}
+
+			vl := &ValueList{
+				Identifier: Identifier{
+					Host:   "example.com",
+					Plugin: "golang",
+					Type:   tc.typ,
+				},
+				Values: []Value{tc.value},
+			}
+
+			if err := rc.Write(context.Background(), vl); err != nil {
+				t.Fatalf("RangeChecker.Write() = %v", err)
+			}
+
+			if tc.wantDropped {
+				if got != nil {
+					t.Errorf("RangeChecker.Write() passed on %v, want value list to be dropped", got)
+				}
+				if got, want := rc.Dropped(), uint64(1); got != want {
+					t.Errorf("RangeChecker.Dropped() = %d, want %d", got, want)
+				}
+				return
+			}
+
+			if got == nil {
+				t.Fatal("RangeChecker.Write() dropped the value list")
+			}
+			if diff := cmp.Diff([]Value{tc.want}, got.Values); diff != "" {
+				t.Errorf("values differ (+got/-want):\n%s", diff)
+			}
+			if diff := cmp.Diff([]Value{tc.value}, vl.Values); diff != "" {
+				t.Errorf("RangeChecker.Write() modified its argument (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package api // import "collectd.org/api"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Rate calculates per-second rates from prev and vl, two consecutive value
+// lists of the same metric. The rate of each value is determined by its type:
+//
+//   - Gauge values are returned unchanged.
+//   - Derive rates are calculated from the difference to the previous value.
+//     Negative rates, e.g. caused by a reset, are discarded and returned as
+//     NaN.
+//   - Counter rates assume a 32 bit or 64 bit wrap around if the value is
+//     smaller than the previous value.
+//   - Absolute values are divided by the time passed since prev.
+//
+// Rate returns an error if the identifiers or value types differ, or if vl is
+// not newer than prev.
+func (vl *ValueList) Rate(prev *ValueList) ([]Gauge, error) {
+	if prev == nil {
+		return nil, errors.New("previous value list is nil")
+	}
+	if vl.Identifier != prev.Identifier {
+		return nil, fmt.Errorf("identifiers differ: %v and %v", vl.Identifier, prev.Identifier)
+	}
+	if len(vl.Values) != len(prev.Values) {
+		return nil, fmt.Errorf("len(Values) = %d, previous value list has %d", len(vl.Values), len(prev.Values))
+	}
+	if !vl.Time.After(prev.Time) {
+		return nil, fmt.Errorf("time %v is not after the previous time %v", vl.Time, prev.Time)
+	}
+	interval := vl.Time.Sub(prev.Time).Seconds()
+
+	rates := make([]Gauge, len(vl.Values))
+	for i, v := range vl.Values {
+		p := prev.Values[i]
+		if v.Type() != p.Type() {
+			return nil, fmt.Errorf("Values[%d] is a %T, previous value is a %T", i, v, p)
+		}
+
+		switch v := v.(type) {
+		case Gauge:
+			rates[i] = v
+		case Derive:
+			r := float64(v-p.(Derive)) / interval
+			if r < 0 {
+				r = math.NaN()
+			}
+			rates[i] = Gauge(r)
+		case Counter:
+			rates[i] = Gauge(float64(counterDiff(p.(Counter), v)) / interval)
+		case Absolute:
+			rates[i] = Gauge(float64(v) / interval)
+		default:
+			return nil, fmt.Errorf("unexpected type %T", v)
+		}
+	}
+
+	return rates, nil
+}
+
+// counterDiff returns the difference between two counter values, assuming a
+// 32 bit or 64 bit overflow if cur is smaller than prev.
+func counterDiff(prev, cur Counter) Counter {
+	if cur >= prev {
+		return cur - prev
+	}
+
+	if prev <= math.MaxUint32 {
+		return math.MaxUint32 - prev + cur + 1
+	}
+	return math.MaxUint64 - prev + cur + 1
+}
+
+// RateTracker calculates per-second rates from a stream of value lists. It
+// keeps the most recent value list of each metric and uses ValueList.Rate to
+// calculate the rates when the next value list of that metric arrives. It is
+// safe for concurrent use.
+type RateTracker struct {
+	mu   sync.Mutex
+	prev map[Identifier]*ValueList
+}
+
+// NewRateTracker returns a new, empty RateTracker.
+func NewRateTracker() *RateTracker {
+	return &RateTracker{
+		prev: make(map[Identifier]*ValueList),
+	}
+}
+
+// Rate returns the per-second rates of vl's values, calculated as described
+// for ValueList.Rate, and reports whether rates could be calculated. This is
+// not the case for the first value list of a metric, or if the values' number
+// or types changed since the previous value list. Value lists that are not
+// newer than the previous value list of the same metric are ignored.
+func (t *RateTracker) Rate(vl *ValueList) ([]Gauge, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, ok := t.prev[vl.Identifier]
+	if ok && !vl.Time.After(prev.Time) {
+		return nil, false
+	}
+
+	// Only the identifier, time and values are required for calculating
+	// rates.
+	t.prev[vl.Identifier] = &ValueList{
+		Identifier: vl.Identifier,
+		Time:       vl.Time,
+		Values:     append([]Value(nil), vl.Values...),
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	rates, err := vl.Rate(prev)
+	if err != nil {
+		return nil, false
+	}
+	return rates, true
+}
+
+// Forget removes the state kept for the metric identified by id. The next
+// value list of that metric is treated as the first one.
+func (t *RateTracker) Forget(id Identifier) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.prev, id)
+}
@@ -0,0 +1,286 @@
+package api_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValueList_Rate(t *testing.T) {
+	id := api.Identifier{
+		Host:   "example.com",
+		Plugin: "TestValueList_Rate",
+		Type:   "test",
+	}
+	t0 := time.Unix(1588164686, 0)
+
+	cases := []struct {
+		title   string
+		prev    *api.ValueList
+		cur     *api.ValueList
+		want    []api.Gauge
+		wantErr bool
+	}{
+		{
+			title: "normal rates",
+			prev: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Gauge(1), api.Derive(100), api.Counter(100), api.Absolute(5)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0.Add(10 * time.Second),
+				Values:     []api.Value{api.Gauge(2), api.Derive(150), api.Counter(300), api.Absolute(50)},
+			},
+			want: []api.Gauge{2, 5, 20, 5},
+		},
+		{
+			title: "negative derive",
+			prev: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Derive(100)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0.Add(10 * time.Second),
+				Values:     []api.Value{api.Derive(0)},
+			},
+			want: []api.Gauge{api.Gauge(math.NaN())},
+		},
+		{
+			title: "32 bit counter wrap",
+			prev: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Counter(math.MaxUint32 - 9)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0.Add(10 * time.Second),
+				Values:     []api.Value{api.Counter(10)},
+			},
+			want: []api.Gauge{2},
+		},
+		{
+			title: "64 bit counter wrap",
+			prev: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Counter(math.MaxUint64 - 9)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0.Add(10 * time.Second),
+				Values:     []api.Value{api.Counter(10)},
+			},
+			want: []api.Gauge{2},
+		},
+		{
+			title: "zero interval",
+			prev: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Derive(100)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Derive(200)},
+			},
+			wantErr: true,
+		},
+		{
+			title: "time going backwards",
+			prev: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Derive(100)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0.Add(-time.Second),
+				Values:     []api.Value{api.Derive(200)},
+			},
+			wantErr: true,
+		},
+		{
+			title: "nil previous value list",
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Derive(100)},
+			},
+			wantErr: true,
+		},
+		{
+			title: "different identifiers",
+			prev: &api.ValueList{
+				Identifier: api.Identifier{Host: "other.example.com", Plugin: "TestValueList_Rate", Type: "test"},
+				Time:       t0,
+				Values:     []api.Value{api.Derive(100)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0.Add(10 * time.Second),
+				Values:     []api.Value{api.Derive(200)},
+			},
+			wantErr: true,
+		},
+		{
+			title: "different types",
+			prev: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Counter(100)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0.Add(10 * time.Second),
+				Values:     []api.Value{api.Derive(200)},
+			},
+			wantErr: true,
+		},
+		{
+			title: "different number of values",
+			prev: &api.ValueList{
+				Identifier: id,
+				Time:       t0,
+				Values:     []api.Value{api.Derive(100)},
+			},
+			cur: &api.ValueList{
+				Identifier: id,
+				Time:       t0.Add(10 * time.Second),
+				Values:     []api.Value{api.Derive(200), api.Derive(300)},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			got, err := tc.cur.Rate(tc.prev)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Rate() = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			equateNaNs := cmp.Comparer(func(a, b api.Gauge) bool {
+				return a == b || (math.IsNaN(float64(a)) && math.IsNaN(float64(b)))
+			})
+			if diff := cmp.Diff(tc.want, got, equateNaNs); diff != "" {
+				t.Errorf("Rate() differs (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRateTracker(t *testing.T) {
+	id := api.Identifier{
+		Host:   "example.com",
+		Plugin: "TestRateTracker",
+		Type:   "test",
+	}
+	t0 := time.Unix(1588164686, 0)
+
+	steps := []struct {
+		title  string
+		time   time.Time
+		values []api.Value
+		forget bool
+		want   []api.Gauge
+		wantOK bool
+	}{
+		{
+			title:  "first sample",
+			time:   t0,
+			values: []api.Value{api.Counter(math.MaxUint64 - 99), api.Derive(1000)},
+		},
+		{
+			title:  "steady rate",
+			time:   t0.Add(10 * time.Second),
+			values: []api.Value{api.Counter(math.MaxUint64 - 49), api.Derive(1100)},
+			want:   []api.Gauge{5, 10},
+			wantOK: true,
+		},
+		{
+			title:  "counter wrap and derive reset",
+			time:   t0.Add(20 * time.Second),
+			values: []api.Value{api.Counter(50), api.Derive(500)},
+			want:   []api.Gauge{10, api.Gauge(math.NaN())},
+			wantOK: true,
+		},
+		{
+			title:  "duplicate time",
+			time:   t0.Add(20 * time.Second),
+			values: []api.Value{api.Counter(60), api.Derive(600)},
+		},
+		{
+			title:  "after duplicate",
+			time:   t0.Add(30 * time.Second),
+			values: []api.Value{api.Counter(150), api.Derive(600)},
+			want:   []api.Gauge{10, 10},
+			wantOK: true,
+		},
+		{
+			title:  "number of values changed",
+			time:   t0.Add(40 * time.Second),
+			values: []api.Value{api.Counter(250)},
+		},
+		{
+			title:  "continues after change",
+			time:   t0.Add(50 * time.Second),
+			values: []api.Value{api.Counter(350)},
+			want:   []api.Gauge{10},
+			wantOK: true,
+		},
+		{
+			title:  "forget",
+			time:   t0.Add(60 * time.Second),
+			values: []api.Value{api.Counter(450)},
+			forget: true,
+		},
+	}
+
+	equateNaNs := cmp.Comparer(func(a, b api.Gauge) bool {
+		return a == b || (math.IsNaN(float64(a)) && math.IsNaN(float64(b)))
+	})
+
+	rt := api.NewRateTracker()
+	for _, s := range steps {
+		if s.forget {
+			rt.Forget(id)
+		}
+
+		got, ok := rt.Rate(&api.ValueList{
+			Identifier: id,
+			Time:       s.time,
+			Interval:   10 * time.Second,
+			Values:     s.values,
+		})
+		if ok != s.wantOK {
+			t.Errorf("%s: Rate() = (%v, %v), want ok %v", s.title, got, ok, s.wantOK)
+			continue
+		}
+		if diff := cmp.Diff(s.want, got, equateNaNs); diff != "" {
+			t.Errorf("%s: Rate() differs (+got/-want):\n%s", s.title, diff)
+		}
+	}
+
+	// Metrics are tracked independently.
+	other := id
+	other.TypeInstance = "other"
+	if got, ok := rt.Rate(&api.ValueList{
+		Identifier: other,
+		Time:       t0.Add(70 * time.Second),
+		Values:     []api.Value{api.Counter(1)},
+	}); ok {
+		t.Errorf("Rate(other) = (%v, true), want ok false", got)
+	}
+}
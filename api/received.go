@@ -0,0 +1,52 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"time"
+
+	"collectd.org/meta"
+)
+
+// DefaultReceivedKey is the meta data key used by ReceiveTagger if Key is not
+// set.
+const DefaultReceivedKey = "received_at"
+
+// ReceiveTagger is a Writer that records the time a value list was received
+// in its meta data before passing it on to the next Writer. Together with
+// ValueList.Time, this allows downstream systems to compute the ingestion lag.
+//
+// The time is stored as an Int64 entry holding nanoseconds since the Unix
+// epoch.
+type ReceiveTagger struct {
+	Writer Writer
+	// Key is the meta data key to store the receive time in. If empty,
+	// DefaultReceivedKey is used.
+	Key string
+	// Overwrite determines whether an existing entry with the same key is
+	// replaced. If false, such value lists are passed on unmodified.
+	Overwrite bool
+}
+
+// Write passes a copy of vl with the receive time added to its meta data to
+// rt.Writer.
+func (rt *ReceiveTagger) Write(ctx context.Context, vl *ValueList) error {
+	now := time.Now()
+
+	key := rt.Key
+	if key == "" {
+		key = DefaultReceivedKey
+	}
+
+	if _, ok := vl.Meta[key]; ok && !rt.Overwrite {
+		return rt.Writer.Write(ctx, vl)
+	}
+
+	// Don't modify the argument.
+	vl = vl.Clone()
+	if vl.Meta == nil {
+		vl.Meta = make(meta.Data)
+	}
+	vl.Meta[key] = meta.Int64(now.UnixNano())
+
+	return rt.Writer.Write(ctx, vl)
+}
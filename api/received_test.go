@@ -0,0 +1,100 @@
+package api_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/meta"
+)
+
+func TestReceiveTagger(t *testing.T) {
+	cases := []struct {
+		title     string
+		key       string
+		overwrite bool
+		meta      meta.Data
+		wantKey   string
+		wantKept  bool
+	}{
+		{
+			title:   "default key",
+			wantKey: api.DefaultReceivedKey,
+		},
+		{
+			title:   "custom key",
+			key:     "rx_time",
+			meta:    meta.Data{"other": meta.String("kept")},
+			wantKey: "rx_time",
+		},
+		{
+			title:    "existing entry is kept",
+			meta:     meta.Data{api.DefaultReceivedKey: meta.Int64(42)},
+			wantKey:  api.DefaultReceivedKey,
+			wantKept: true,
+		},
+		{
+			title:     "existing entry is overwritten",
+			overwrite: true,
+			meta:      meta.Data{api.DefaultReceivedKey: meta.Int64(42)},
+			wantKey:   api.DefaultReceivedKey,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			var got *api.ValueList
+			rt := &api.ReceiveTagger{
+				Writer: api.WriterFunc(func(_ context.Context, vl *api.ValueList) error {
+					got = vl
+					return nil
+				}),
+				Key:       tc.key,
+				Overwrite: tc.overwrite,
+			}
+
+			vl := &api.ValueList{
+				Identifier: api.Identifier{
+					Host:   "example.com",
+					Plugin: "TestReceiveTagger",
+					Type:   "gauge",
+				},
+				Values: []api.Value{api.Gauge(42)},
+				Meta:   tc.meta.Clone(),
+			}
+
+			before := time.Now()
+			if err := rt.Write(context.Background(), vl); err != nil {
+				t.Fatal(err)
+			}
+			after := time.Now()
+
+			ns, ok := got.Meta[tc.wantKey].Int64()
+			if !ok {
+				t.Fatalf("vl.Meta[%q] = %v, want an int64", tc.wantKey, got.Meta[tc.wantKey])
+			}
+			if tc.wantKept {
+				if ns != 42 {
+					t.Errorf("vl.Meta[%q] = %d, want 42", tc.wantKey, ns)
+				}
+				return
+			}
+			if ns < before.UnixNano() || ns > after.UnixNano() {
+				t.Errorf("vl.Meta[%q] = %d, want in [%d, %d]", tc.wantKey, ns, before.UnixNano(), after.UnixNano())
+			}
+
+			for k := range tc.meta {
+				if k == tc.wantKey {
+					continue
+				}
+				if _, ok := got.Meta[k]; !ok {
+					t.Errorf("vl.Meta[%q] is missing", k)
+				}
+			}
+			if len(vl.Meta) != len(tc.meta) {
+				t.Errorf("ReceiveTagger modified its argument: vl.Meta = %v", vl.Meta)
+			}
+		})
+	}
+}
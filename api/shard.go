@@ -0,0 +1,84 @@
+package api // import "collectd.org/api"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ShardWriter is a Writer that distributes value lists across multiple
+// downstream writers. The shard is chosen by hashing the identifier, so all
+// value lists of a metric are written to the same Writer.
+type ShardWriter struct {
+	// Writers are the downstream writers, one per shard. Writers must not
+	// be changed after the first call to Write, since that would move
+	// metrics to different shards.
+	Writers []Writer
+
+	once  sync.Once
+	stats []shardCounters
+}
+
+// ShardStats holds the counters of one shard.
+type ShardStats struct {
+	// Written is the number of value lists written to the shard
+	// successfully.
+	Written uint64
+	// Errors is the number of value lists the shard failed to write.
+	Errors uint64
+}
+
+type shardCounters struct {
+	written uint64
+	errors  uint64
+}
+
+// Write writes vl to the Writer responsible for its identifier. Errors are
+// annotated with the shard's index.
+func (s *ShardWriter) Write(ctx context.Context, vl *ValueList) error {
+	if len(s.Writers) == 0 {
+		return errors.New("ShardWriter: no writers")
+	}
+	s.init()
+
+	i := int(vl.Identifier.hash() % uint64(len(s.Writers)))
+	if err := s.Writers[i].Write(ctx, vl); err != nil {
+		atomic.AddUint64(&s.stats[i].errors, 1)
+		return fmt.Errorf("shard %d: %w", i, err)
+	}
+
+	atomic.AddUint64(&s.stats[i].written, 1)
+	return nil
+}
+
+// Stats returns the counters of each shard, in the order of Writers.
+func (s *ShardWriter) Stats() []ShardStats {
+	s.init()
+
+	ret := make([]ShardStats, len(s.stats))
+	for i := range s.stats {
+		ret[i] = ShardStats{
+			Written: atomic.LoadUint64(&s.stats[i].written),
+			Errors:  atomic.LoadUint64(&s.stats[i].errors),
+		}
+	}
+	return ret
+}
+
+// Total returns the sum of the counters of all shards.
+func (s *ShardWriter) Total() ShardStats {
+	var total ShardStats
+	for _, st := range s.Stats() {
+		total.Written += st.Written
+		total.Errors += st.Errors
+	}
+	return total
+}
+
+func (s *ShardWriter) init() {
+	s.once.Do(func() {
+		s.stats = make([]shardCounters, len(s.Writers))
+	})
+}
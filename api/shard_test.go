@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShardWriter(t *testing.T) {
+	writers := []*recordingWriter{{}, {}, {}}
+	s := &api.ShardWriter{}
+	for _, w := range writers {
+		s.Writers = append(s.Writers, w)
+	}
+
+	ctx := context.Background()
+	var ids []api.Identifier
+	for i := 0; i < 100; i++ {
+		ids = append(ids, api.Identifier{
+			Host:   fmt.Sprintf("host%d", i),
+			Plugin: "cpu",
+			Type:   "cpu",
+		})
+	}
+
+	// Write every identifier twice to check that shards are stable.
+	for round := 0; round < 2; round++ {
+		for _, id := range ids {
+			if err := s.Write(ctx, &api.ValueList{Identifier: id}); err != nil {
+				t.Fatalf("ShardWriter.Write(%v) = %v", id, err)
+			}
+		}
+	}
+
+	shardOf := make(map[string]int)
+	for i, w := range writers {
+		if len(w.got) == 0 {
+			t.Errorf("shard %d received no value lists", i)
+		}
+		for _, id := range w.got {
+			if prev, ok := shardOf[id]; ok && prev != i {
+				t.Errorf("%s written to shards %d and %d", id, prev, i)
+			}
+			shardOf[id] = i
+		}
+	}
+	if got, want := len(shardOf), len(ids); got != want {
+		t.Errorf("got %d distinct identifiers, want %d", got, want)
+	}
+
+	var want []api.ShardStats
+	for _, w := range writers {
+		want = append(want, api.ShardStats{Written: uint64(len(w.got))})
+	}
+	if diff := cmp.Diff(want, s.Stats()); diff != "" {
+		t.Errorf("ShardWriter.Stats() differs (+got/-want):\n%s", diff)
+	}
+	if diff := cmp.Diff(api.ShardStats{Written: 200}, s.Total()); diff != "" {
+		t.Errorf("ShardWriter.Total() differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestShardWriter_Error(t *testing.T) {
+	wantErr := errors.New("test error")
+	s := &api.ShardWriter{
+		Writers: []api.Writer{
+			api.WriterFunc(func(context.Context, *api.ValueList) error {
+				return wantErr
+			}),
+		},
+	}
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"},
+	}
+	if err := s.Write(context.Background(), vl); !errors.Is(err, wantErr) {
+		t.Errorf("ShardWriter.Write() = %v, want %v", err, wantErr)
+	}
+
+	if diff := cmp.Diff(api.ShardStats{Errors: 1}, s.Total()); diff != "" {
+		t.Errorf("ShardWriter.Total() differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestShardWriter_NoWriters(t *testing.T) {
+	var s api.ShardWriter
+	if err := s.Write(context.Background(), &api.ValueList{}); err == nil {
+		t.Error("ShardWriter.Write() succeeded, want error")
+	}
+}
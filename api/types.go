@@ -19,9 +19,10 @@ var (
 )
 
 var (
-	dsTypeCounter = reflect.TypeOf(Counter(0))
-	dsTypeDerive  = reflect.TypeOf(Derive(0))
-	dsTypeGauge   = reflect.TypeOf(Gauge(0))
+	dsTypeAbsolute = reflect.TypeOf(Absolute(0))
+	dsTypeCounter  = reflect.TypeOf(Counter(0))
+	dsTypeDerive   = reflect.TypeOf(Derive(0))
+	dsTypeGauge    = reflect.TypeOf(Gauge(0))
 )
 
 // TypesDB holds the type definitions of one or more types.db(5) files.
@@ -142,8 +143,8 @@ func (ds *DataSet) Names() []string {
 
 // Values converts the arguments to the Value interface type and returns them
 // as a slice. It expects the same number of arguments as it has Sources and
-// will return an error if there is a mismatch. Each argument is converted to a
-// Counter, Derive or Gauge according to the corresponding DataSource.Type.
+// will return an error if there is a mismatch. Each argument is converted to an
+// Absolute, Counter, Derive or Gauge according to the corresponding DataSource.Type.
 func (ds *DataSet) Values(args ...interface{}) ([]Value, error) {
 	if len(args) != len(ds.Sources) {
 		return nil, fmt.Errorf("len(args) = %d, want %d", len(args), len(ds.Sources))
@@ -192,7 +193,7 @@ func (ds *DataSet) Check(vl *ValueList) error {
 }
 
 // DataSource defines one metric within a "Type" / DataSet. Type is one of
-// Counter, Derive and Gauge. Min and Max apply to the rates of Counter and
+// Absolute, Counter, Derive and Gauge. Min and Max apply to the rates of Counter and
 // Derive types, not the raw incremental value.
 type DataSource struct {
 	Name     string
@@ -215,6 +216,8 @@ func parseSource(line string) (*DataSource, error) {
 	}
 
 	switch f[1] {
+	case "ABSOLUTE":
+		dsrc.Type = dsTypeAbsolute
 	case "COUNTER":
 		dsrc.Type = dsTypeCounter
 	case "DERIVE":
@@ -244,7 +247,7 @@ func parseSource(line string) (*DataSource, error) {
 	return dsrc, nil
 }
 
-// Value converts arg to a Counter, Derive or Gauge and returns it as the Value
+// Value converts arg to an Absolute, Counter, Derive or Gauge and returns it as the Value
 // interface type. Returns an error if arg cannot be converted.
 func (dsrc DataSource) Value(arg interface{}) (Value, error) {
 	if !reflect.TypeOf(arg).ConvertibleTo(dsrc.Type) {
@@ -253,6 +256,8 @@ func (dsrc DataSource) Value(arg interface{}) (Value, error) {
 
 	v := reflect.ValueOf(arg).Convert(dsrc.Type)
 	switch dsrc.Type {
+	case dsTypeAbsolute:
+		return v.Interface().(Absolute), nil
 	case dsTypeCounter:
 		return v.Interface().(Counter), nil
 	case dsTypeDerive:
@@ -98,6 +98,11 @@ func TestDataSource_Value(t *testing.T) {
 		wantValue Value
 		wantErr   bool
 	}{
+		// ABSOLUTE
+		{int(42), dsTypeAbsolute, Absolute(42), false},
+		{uint64(42), dsTypeAbsolute, Absolute(42), false},
+		{Absolute(42), dsTypeAbsolute, Absolute(42), false},
+		{true, dsTypeAbsolute, nil, true},
 		// COUNTER
 		{int(42), dsTypeCounter, Counter(42), false},
 		{uint(42), dsTypeCounter, Counter(42), false},
@@ -5,7 +5,10 @@ representation, cdtime_t.
 package cdtime // import "collectd.org/cdtime"
 
 import (
+	"encoding/binary"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -48,6 +51,56 @@ func (t Time) String() string {
 	return strconv.FormatFloat(f /* format */, 'f' /* precision */, 3 /* bits */, 64)
 }
 
+// Format returns the time as seconds since the epoch with nanosecond
+// precision, e.g. "1426588900.328000001". Trailing zeros of the fractional
+// part are omitted, as is the decimal point if the fractional part is zero.
+// Unlike String, the result can be converted back using Parse without losing
+// precision beyond the nanosecond rounding inherent to Time.
+func (t Time) Format() string {
+	s, ns := t.decompose()
+	if ns == 0 {
+		return strconv.FormatInt(s, 10)
+	}
+
+	frac := strings.TrimRight(fmt.Sprintf("%09d", ns), "0")
+	return strconv.FormatInt(s, 10) + "." + frac
+}
+
+// Parse parses the textual representation of a time, seconds since the epoch
+// with an optional fractional part, e.g. "1426588900.328". This is the format
+// used by collectd's text protocols. Digits beyond nanosecond precision are
+// ignored.
+func Parse(s string) (Time, error) {
+	secStr, fracStr, hasFrac := strings.Cut(s, ".")
+	if secStr == "" || (hasFrac && fracStr == "") {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	sec, err := strconv.ParseUint(secStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	// Time uses 34 bits for the seconds.
+	if sec >= 1<<34 {
+		return 0, fmt.Errorf("time %q out of range", s)
+	}
+
+	var ns uint64
+	for i, c := range fracStr {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		if i < 9 {
+			ns = 10*ns + uint64(c-'0')
+		}
+	}
+	for i := len(fracStr); i < 9; i++ {
+		ns *= 10
+	}
+
+	return newNano(1000000000*sec + ns), nil
+}
+
 // Float returns the time as seocnds since epoch. This is a lossy conversion,
 // which will lose up to 11 bits. This means that the returned value should be
 // considered to have roughly microsecond precision.
@@ -75,6 +128,26 @@ func (t *Time) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalBinary implements the "encoding".BinaryMarshaler interface for Time.
+// The raw value is encoded as an 8 byte big-endian integer. Unlike
+// MarshalJSON, this encoding is loss-less.
+func (t Time) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(t))
+	return data, nil
+}
+
+// UnmarshalBinary implements the "encoding".BinaryUnmarshaler interface for
+// Time.
+func (t *Time) UnmarshalBinary(data []byte) error {
+	if len(data) != 8 {
+		return fmt.Errorf("invalid length: got %d bytes, want 8", len(data))
+	}
+
+	*t = Time(binary.BigEndian.Uint64(data))
+	return nil
+}
+
 func (t Time) decompose() (s, ns int64) {
 	s = int64(t >> 30)
 
@@ -2,6 +2,7 @@ package cdtime_test
 
 import (
 	"encoding/json"
+	"math"
 	"testing"
 	"time"
 
@@ -74,6 +75,38 @@ func TestMarshalJSON(t *testing.T) {
 	}
 }
 
+func TestMarshalBinary(t *testing.T) {
+	cases := []cdtime.Time{
+		cdtime.Time(1546168526406004689),
+		cdtime.Time(1546168724171447263),
+		cdtime.Time(1546168770415815077),
+		cdtime.Time(1546168770415815413),
+		0,
+		cdtime.Time(math.MaxUint64),
+	}
+
+	for _, want := range cases {
+		data, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%#v.MarshalBinary() = %v", want, err)
+		}
+
+		var got cdtime.Time
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary(%x) = %v", data, err)
+		}
+
+		if got != want {
+			t.Errorf("UnmarshalBinary(%x) = %d, want %d", data, got, want)
+		}
+	}
+
+	var got cdtime.Time
+	if err := got.UnmarshalBinary([]byte{1, 2, 3}); err == nil {
+		t.Errorf("UnmarshalBinary(<short buffer>) = %v, want error", err)
+	}
+}
+
 func TestNewDuration(t *testing.T) {
 	cases := []struct {
 		d    time.Duration
@@ -101,3 +134,71 @@ func TestNewDuration(t *testing.T) {
 		}
 	}
 }
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "1587671455", want: time.Unix(1587671455, 0)},
+		{in: "1587671455.5", want: time.Unix(1587671455, 500000000)},
+		{in: "1587671455.499", want: time.Unix(1587671455, 499000000)},
+		{in: "1587671455.000000001", want: time.Unix(1587671455, 1)},
+		{in: "1587671455.1234567891", want: time.Unix(1587671455, 123456789)},
+		{in: "", wantErr: true},
+		{in: ".5", wantErr: true},
+		{in: "1587671455.", wantErr: true},
+		{in: "-1587671455", wantErr: true},
+		{in: "+1587671455", wantErr: true},
+		{in: "1587671455.5e3", wantErr: true},
+		{in: "N", wantErr: true},
+		{in: "17179869184", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := cdtime.Parse(tc.in)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("Parse(%q) = (%v, %v), want error %v", tc.in, got, err, tc.wantErr)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+
+		if !got.Time().Equal(tc.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tc.in, got.Time(), tc.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		in   time.Time
+		want string
+	}{
+		{time.Unix(1587671455, 0), "1587671455"},
+		{time.Unix(1587671455, 500000000), "1587671455.5"},
+		{time.Unix(1587671455, 499000000), "1587671455.499"},
+		{time.Unix(1587671455, 1), "1587671455.000000001"},
+		{time.Unix(1587671455, 123456789), "1587671455.123456789"},
+	}
+
+	for _, tc := range cases {
+		ct := cdtime.New(tc.in)
+		got := ct.Format()
+		if got != tc.want {
+			t.Errorf("New(%v).Format() = %q, want %q", tc.in, got, tc.want)
+		}
+
+		// Round-trip through Parse.
+		parsed, err := cdtime.Parse(got)
+		if err != nil {
+			t.Errorf("Parse(%q) = %v", got, err)
+			continue
+		}
+		if parsed != ct {
+			t.Errorf("Parse(%q) = %#x, want %#x", got, uint64(parsed), uint64(ct))
+		}
+	}
+}
@@ -40,8 +40,11 @@ import (
 	"math"
 	"net"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
+	"collectd.org/internal/quote"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -142,6 +145,33 @@ func (cv Value) Float64() (float64, bool) {
 	return cv.f, cv.typ == numberType
 }
 
+// Int64 returns the value of a float64 Value as an int64. It returns false if
+// cv is not a number, has a fractional part, or is outside of the range of an
+// int64. Note that collectd stores all numbers as float64, i.e. integers
+// larger than 2^53 may already have lost precision.
+func (cv Value) Int64() (int64, bool) {
+	if cv.typ != numberType || cv.f != math.Trunc(cv.f) {
+		return 0, false
+	}
+	if cv.f < math.MinInt64 || cv.f >= 1<<63 {
+		return 0, false
+	}
+	return int64(cv.f), true
+}
+
+// Uint64 returns the value of a float64 Value as an uint64. It returns false
+// if cv is not a number, has a fractional part, or is outside of the range of
+// an uint64. See Int64 for a note on precision.
+func (cv Value) Uint64() (uint64, bool) {
+	if cv.typ != numberType || cv.f != math.Trunc(cv.f) {
+		return 0, false
+	}
+	if cv.f < 0 || cv.f >= 1<<64 {
+		return 0, false
+	}
+	return uint64(cv.f), true
+}
+
 // Bool returns the value of a bool Value.
 func (cv Value) Bool() (bool, bool) {
 	return cv.b, cv.typ == booleanType
@@ -181,6 +211,16 @@ func (cv Value) unmarshal(v reflect.Value) error {
 		return fmt.Errorf("unexpected Value type: %v", cv.typ)
 	}
 
+	// Be tolerant of booleans written as strings, e.g. "yes" or "off".
+	if cv.typ == stringType && (v.Kind() == reflect.Bool || v.Kind() == reflect.Slice && rvt.Elem().Kind() == reflect.Bool) {
+		b, err := parseBool(cv.s)
+		if err != nil {
+			return err
+		}
+		cvt = reflect.TypeOf(b)
+		cvv = reflect.ValueOf(b)
+	}
+
 	if cvt.ConvertibleTo(rvt) {
 		v.Set(cvv.Convert(rvt))
 		return nil
@@ -192,6 +232,18 @@ func (cv Value) unmarshal(v reflect.Value) error {
 	return fmt.Errorf("cannot unmarshal a %T to a %s", cv.Interface(), v.Type())
 }
 
+// parseBool parses the string representation of a boolean value, e.g. "yes"
+// or "Off". Case is ignored.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("cannot unmarshal %q to a boolean", s)
+}
+
 // Block represents one configuration block, which may contain other configuration blocks.
 type Block struct {
 	Key      string
@@ -221,8 +273,65 @@ func (b *Block) Merge(other Block) error {
 	return nil
 }
 
+// Walk calls fn for b and all of its descendants, in depth-first order. Each
+// block is passed with its path, i.e. the keys of all blocks from b down to
+// and including the block itself. Walk stops and returns the first error
+// returned by fn.
+func (b Block) Walk(fn func(path []string, b Block) error) error {
+	return b.walk(nil, fn)
+}
+
+func (b Block) walk(parent []string, fn func([]string, Block) error) error {
+	// The full slice expression forces a copy, so that fn may retain path.
+	path := append(parent[:len(parent):len(parent)], b.Key)
+	if err := fn(path, b); err != nil {
+		return err
+	}
+
+	for _, c := range b.Children {
+		if err := c.walk(path, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Unmarshal applies the configuration from a Block to an arbitrary struct.
+//
+// Child blocks are mapped to the struct field with the same name. The mapping
+// can be customized with a "config" struct tag:
+//
+//	// Field is set from the "Hosts" option.
+//	HostList []string `config:"Hosts"`
+//	// Field is set from the "Timeout" option, ignoring case, e.g. "timeout".
+//	Timeout Duration `config:",ignorecase"`
+//	// Field is never set.
+//	Internal string `config:"-"`
+//
+// A field with a tag is only matched by the name given in the tag. A tag name
+// takes precedence over a field name, and exact matches take precedence over
+// case-insensitive matches.
+//
+// Map fields must have string keys. A block with values adds one entry, keyed
+// by its first value, e.g. `<Metric "foo">` adds the key "foo". A block
+// without values adds one entry per child block, keyed by the child's key.
+//
+// Fields with a `required:"true"` tag must be set by the configuration,
+// otherwise an error is returned. Required slice and map fields must not be
+// empty.
+//
+// Child blocks without a corresponding field are an error. Use a Decoder to
+// ignore them instead.
 func (b *Block) Unmarshal(v interface{}) error {
+	return b.unmarshal(v, unmarshalOpts{})
+}
+
+// unmarshalOpts holds the options of a Decoder.
+type unmarshalOpts struct {
+	allowUnknown bool
+}
+
+func (b *Block) unmarshal(v interface{}, opts unmarshalOpts) error {
 	// If the target supports unmarshalling let it
 	if u, ok := v.(Unmarshaler); ok {
 		return u.UnmarshalConfig(*b)
@@ -237,10 +346,10 @@ func (b *Block) Unmarshal(v interface{}) error {
 	drv := rv.Elem() // get dereferenced value
 	drvk := drv.Kind()
 
-	// If config block has child blocks we can only unmarshal to a struct or slice of structs
+	// If config block has child blocks we can only unmarshal to a struct, slice of structs or map
 	if len(b.Children) > 0 {
-		if drvk != reflect.Struct && (drvk != reflect.Slice || drv.Type().Elem().Kind() != reflect.Struct) {
-			return fmt.Errorf("cannot unmarshal a config with children except to a struct or slice of structs")
+		if drvk != reflect.Struct && drvk != reflect.Map && (drvk != reflect.Slice || drv.Type().Elem().Kind() != reflect.Struct) {
+			return fmt.Errorf("cannot unmarshal a config with children except to a struct, slice of structs or map")
 		}
 	}
 
@@ -250,26 +359,34 @@ func (b *Block) Unmarshal(v interface{}) error {
 		if err := storeStructConfigValues(b.Values, drv); err != nil {
 			return fmt.Errorf("while unmarshalling config block values into %s: %s", drv.Type(), err)
 		}
+		seen := make(map[int]bool)
+		if f, ok := drv.Type().FieldByName("Args"); ok && len(f.Index) == 1 && len(b.Values) > 0 {
+			seen[f.Index[0]] = true
+		}
 		for _, child := range b.Children {
 			// If a config has children but the struct has no corresponding field, or the corresponding field is an
 			// unexported struct field we throw an error.
-			if field := drv.FieldByName(child.Key); field.IsValid() && field.CanInterface() {
-				if err := child.Unmarshal(field.Addr().Interface()); err != nil {
+			index := fieldByKey(drv, child.Key)
+			if field := fieldByIndex(drv, index); field.IsValid() && field.CanInterface() {
+				if err := child.unmarshal(field.Addr().Interface(), opts); err != nil {
 					//	if err := child.Unmarshal(field.Interface()); err != nil {
 					return fmt.Errorf("in child config block %s: %s", child.Key, err)
 				}
-			} else {
+				if len(index) == 1 {
+					seen[index[0]] = true
+				}
+			} else if !opts.allowUnknown {
 				return fmt.Errorf("found child config block with no corresponding field: %s", child.Key)
 			}
 		}
-		return nil
+		return checkRequired(drv, seen)
 	case reflect.Slice:
 		switch drv.Type().Elem().Kind() {
 		case reflect.Struct:
 			// Create a temporary Value of the same type as dereferenced value, then get a Value of the same type as
 			// its elements. Unmarshal into that Value and append the temporary Value to the original.
 			tv := reflect.New(drv.Type().Elem()).Elem()
-			if err := b.Unmarshal(tv.Addr().Interface()); err != nil {
+			if err := b.unmarshal(tv.Addr().Interface(), opts); err != nil {
 				return fmt.Errorf("unmarshaling into temporary value failed: %s", err)
 			}
 			drv.Set(reflect.Append(drv, tv))
@@ -284,6 +401,8 @@ func (b *Block) Unmarshal(v interface{}) error {
 			}
 			return nil
 		}
+	case reflect.Map:
+		return b.unmarshalMap(drv, opts)
 	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
 		if len(b.Values) != 1 {
 			return fmt.Errorf("cannot unmarshal config option with %d values into scalar type %s", len(b.Values), drv.Type())
@@ -294,6 +413,184 @@ func (b *Block) Unmarshal(v interface{}) error {
 	}
 }
 
+// unmarshalMap adds entries to the map m, which must have string keys.
+//
+// If b has values, the first value is the map key and the block without it is
+// unmarshaled into the map value, e.g. `<Metric "foo">…</Metric>` adds the key
+// "foo". Repeated blocks add more entries. Otherwise, each child block is one
+// entry keyed by the child's key, e.g. `<Tags> env "prod" </Tags>` adds the
+// key "env" with the value "prod".
+func (b *Block) unmarshalMap(m reflect.Value, opts unmarshalOpts) error {
+	if m.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot unmarshal into map with %s keys", m.Type().Key())
+	}
+
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+
+	add := func(key string, src Block) error {
+		k := reflect.ValueOf(key).Convert(m.Type().Key())
+		if m.MapIndex(k).IsValid() {
+			return fmt.Errorf("duplicate map key %q", key)
+		}
+
+		tv := reflect.New(m.Type().Elem()).Elem()
+		if err := src.unmarshal(tv.Addr().Interface(), opts); err != nil {
+			return fmt.Errorf("while unmarshalling map key %q: %s", key, err)
+		}
+		m.SetMapIndex(k, tv)
+		return nil
+	}
+
+	if len(b.Values) > 0 {
+		if !b.Values[0].IsString() {
+			return fmt.Errorf("cannot use %v as map key, want a string", b.Values[0].Interface())
+		}
+		return add(b.Values[0].String(), Block{
+			Key:      b.Key,
+			Values:   b.Values[1:],
+			Children: b.Children,
+		})
+	}
+
+	for _, child := range b.Children {
+		if err := add(child.Key, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decoder unmarshals a Block like Block.Unmarshal, with additional options.
+type Decoder struct {
+	b    Block
+	opts unmarshalOpts
+}
+
+// NewDecoder returns a new Decoder for b.
+func NewDecoder(b Block) *Decoder {
+	return &Decoder{b: b}
+}
+
+// AllowUnknown causes Decode to ignore child blocks that have no corresponding
+// struct field, rather than returning an error. This is useful for configs
+// that may contain options added in later versions.
+func (d *Decoder) AllowUnknown() *Decoder {
+	d.opts.allowUnknown = true
+	return d
+}
+
+// Decode applies the configuration to v, see Block.Unmarshal.
+func (d *Decoder) Decode(v interface{}) error {
+	return d.b.unmarshal(v, d.opts)
+}
+
+// fieldByKey returns the index of the field of the struct v that key maps to,
+// taking the "config" struct tag into account. Exact matches of a tag name take
+// precedence over exact matches of a field name, which take precedence over
+// case-insensitive matches. Nil is returned if no field matches.
+func fieldByKey(v reflect.Value, key string) []int {
+	t := v.Type()
+
+	var byName, folded []int
+	for i := 0; i < t.NumField(); i++ {
+		name, opts, tagged, ok := configKey(t.Field(i))
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == key && tagged:
+			return []int{i}
+		case name == key && byName == nil:
+			byName = []int{i}
+		case strings.EqualFold(name, key) && hasTagOption(opts, "ignorecase") && folded == nil:
+			folded = []int{i}
+		}
+	}
+	if byName != nil {
+		return byName
+	}
+	if folded != nil {
+		return folded
+	}
+
+	// Fields promoted from embedded structs are matched by name.
+	if f, ok := t.FieldByName(key); ok && len(f.Index) > 1 {
+		return f.Index
+	}
+
+	return nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but returns the zero Value
+// for a nil index.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	if index == nil {
+		return reflect.Value{}
+	}
+	return v.FieldByIndex(index)
+}
+
+// configKey returns the config key of a struct field and the options from its
+// "config" tag. tagged is true if the tag specifies the key. ok is false if
+// the field is to be skipped.
+func configKey(f reflect.StructField) (key, opts string, tagged, ok bool) {
+	tag, found := f.Tag.Lookup("config")
+	if !found {
+		return f.Name, "", false, true
+	}
+	if tag == "-" {
+		return "", "", false, false
+	}
+
+	key, opts, _ = strings.Cut(tag, ",")
+	if key == "" {
+		return f.Name, opts, false, true
+	}
+	return key, opts, true, true
+}
+
+// checkRequired returns an error if a field of the struct v with a
+// `required:"true"` tag was not set from the configuration. seen holds the
+// indexes of the fields that were set. Slice and map fields must also be
+// non-empty.
+func checkRequired(v reflect.Value, seen map[int]bool) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("required") != "true" {
+			continue
+		}
+
+		key, _, _, ok := configKey(f)
+		if !ok {
+			continue
+		}
+
+		if !seen[i] {
+			return fmt.Errorf("required option %q (field %s) is missing", key, f.Name)
+		}
+		if k := f.Type.Kind(); (k == reflect.Slice || k == reflect.Map) && v.Field(i).Len() == 0 {
+			return fmt.Errorf("required option %q (field %s) is empty", key, f.Name)
+		}
+	}
+
+	return nil
+}
+
+func hasTagOption(opts, want string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == want {
+			return true
+		}
+	}
+	return false
+}
+
 func storeStructConfigValues(cvs []Value, v reflect.Value) error {
 	if len(cvs) == 0 {
 		return nil
@@ -357,8 +654,15 @@ func valuesMarshalText(values []Value) (string, error) {
 	for _, v := range values {
 		switch v := v.Interface().(type) {
 		case string:
-			fmt.Fprintf(&b, " %q", v)
-		case float64, bool:
+			fmt.Fprintf(&b, " %s", quote.String(v))
+		case float64:
+			// collectd has no syntax for NaN and infinity, and
+			// requires a decimal point in exponential notation.
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return "", fmt.Errorf("%v cannot be represented in collectd's config syntax", v)
+			}
+			fmt.Fprintf(&b, " %s", strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
 			fmt.Fprintf(&b, " %v", v)
 		default:
 			return "", fmt.Errorf("unexpected value type: %T", v)
@@ -406,3 +710,47 @@ func (p *Port) UnmarshalConfig(b Block) error {
 	*p = Port(port)
 	return nil
 }
+
+// Duration represents a time span in the configuration. When a configuration
+// is converted to Go types using Unmarshal, it implements special conversion
+// rules:
+// If the config option is a numeric value, it is interpreted as a number of
+// seconds, e.g. 1.5 is 1.5 seconds. If the config option is a string, it is
+// parsed using "time".ParseDuration, e.g. "1m30s". Negative durations are
+// rejected.
+type Duration time.Duration
+
+// UnmarshalConfig converts b to a duration.
+func (d *Duration) UnmarshalConfig(b Block) error {
+	if len(b.Values) != 1 || len(b.Children) != 0 {
+		return fmt.Errorf("option %q has to be a single scalar value", b.Key)
+	}
+
+	v := b.Values[0]
+	if f, ok := v.Float64(); ok {
+		if math.IsNaN(f) {
+			return fmt.Errorf("the value of the %q option (%v) is invalid", b.Key, f)
+		}
+		ns := f * float64(time.Second)
+		if ns < 0 || ns >= math.MaxInt64 {
+			return fmt.Errorf("the value of the %q option (%v) is out of range", b.Key, f)
+		}
+		*d = Duration(ns)
+		return nil
+	}
+
+	if !v.IsString() {
+		return fmt.Errorf("the value of the %q option must be a number or a string", b.Key)
+	}
+
+	dur, err := time.ParseDuration(v.String())
+	if err != nil {
+		return fmt.Errorf("%s: %w", b.Key, err)
+	}
+	if dur < 0 {
+		return fmt.Errorf("the value of the %q option (%v) is out of range", b.Key, dur)
+	}
+
+	*d = Duration(dur)
+	return nil
+}
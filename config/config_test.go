@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -378,6 +381,52 @@ func TestConfig_Unmarshal(t *testing.T) {
 			}{},
 			wantErr: true,
 		},
+		{
+			name: "bool from string",
+			src: Block{
+				Key: "myPlugin",
+				Children: []Block{
+					{
+						Key:    "KeepAlive",
+						Values: Values("Yes"),
+					},
+					{
+						Key:    "Flags",
+						Values: Values("on", "OFF", "1", "no"),
+					},
+				},
+			},
+			dst: &struct {
+				Args      string
+				KeepAlive bool
+				Flags     []bool
+			}{},
+			want: &struct {
+				Args      string
+				KeepAlive bool
+				Flags     []bool
+			}{
+				KeepAlive: true,
+				Flags:     []bool{true, false, true, false},
+			},
+		},
+		{
+			name: "bool from invalid string",
+			src: Block{
+				Key: "myPlugin",
+				Children: []Block{
+					{
+						Key:    "KeepAlive",
+						Values: Values("maybe"),
+					},
+				},
+			},
+			dst: &struct {
+				Args      string
+				KeepAlive bool
+			}{},
+			wantErr: true,
+		},
 		{
 			name: "port invalid type",
 			src: Block{
@@ -438,6 +487,360 @@ func TestConfig_Unmarshal(t *testing.T) {
 			}{},
 			wantErr: true,
 		},
+		{
+			name: "renamed field",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key:    "Hosts",
+						Values: Values("a.example.com", "b.example.com"),
+					},
+				},
+			},
+			dst: &struct {
+				Args     string
+				HostList []string `config:"Hosts"`
+			}{},
+			want: &struct {
+				Args     string
+				HostList []string `config:"Hosts"`
+			}{
+				HostList: []string{"a.example.com", "b.example.com"},
+			},
+		},
+		{
+			name: "renamed field not matched by field name",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key:    "HostList",
+						Values: Values("a.example.com"),
+					},
+				},
+			},
+			dst: &struct {
+				Args     string
+				HostList []string `config:"Hosts"`
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "skipped field",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key:    "Internal",
+						Values: Values("value"),
+					},
+				},
+			},
+			dst: &struct {
+				Args     string
+				Internal string `config:"-"`
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "tag takes precedence over field name",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key:    "Host",
+						Values: Values("tagged"),
+					},
+				},
+			},
+			dst: &struct {
+				Args   string
+				Host   string
+				Server string `config:"Host"`
+			}{},
+			want: &struct {
+				Args   string
+				Host   string
+				Server string `config:"Host"`
+			}{
+				Server: "tagged",
+			},
+		},
+		{
+			name: "ignorecase",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key:    "timeout",
+						Values: Values(10),
+					},
+					{
+						Key:    "HOSTS",
+						Values: Values("a.example.com"),
+					},
+				},
+			},
+			dst: &struct {
+				Args     string
+				Timeout  int      `config:",ignorecase"`
+				HostList []string `config:"Hosts,ignorecase"`
+			}{},
+			want: &struct {
+				Args     string
+				Timeout  int      `config:",ignorecase"`
+				HostList []string `config:"Hosts,ignorecase"`
+			}{
+				Timeout:  10,
+				HostList: []string{"a.example.com"},
+			},
+		},
+		{
+			name: "exact match takes precedence over ignorecase",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key:    "timeout",
+						Values: Values(10),
+					},
+				},
+			},
+			dst: &struct {
+				Args    string
+				Timeout int `config:",ignorecase"`
+				Other   int `config:"timeout"`
+			}{},
+			want: &struct {
+				Args    string
+				Timeout int `config:",ignorecase"`
+				Other   int `config:"timeout"`
+			}{
+				Other: 10,
+			},
+		},
+		{
+			name: "required field present",
+			src: Block{
+				Key:    "Plugin",
+				Values: Values("test"),
+				Children: []Block{
+					{Key: "Host", Values: Values("example.com")},
+					{Key: "Port", Values: Values(8080)},
+				},
+			},
+			dst: &struct {
+				Args string `required:"true"`
+				Host string `config:"Host" required:"true"`
+				Port int
+			}{},
+			want: &struct {
+				Args string `required:"true"`
+				Host string `config:"Host" required:"true"`
+				Port int
+			}{
+				Args: "test",
+				Host: "example.com",
+				Port: 8080,
+			},
+		},
+		{
+			name: "required field absent",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{Key: "Port", Values: Values(8080)},
+				},
+			},
+			dst: &struct {
+				Args     string
+				HostName string `config:"Host" required:"true"`
+				Port     int
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "required args absent",
+			src: Block{
+				Key: "Plugin",
+			},
+			dst: &struct {
+				Args string `required:"true"`
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "required slice empty",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{Key: "Hosts"},
+				},
+			},
+			dst: &struct {
+				Args  string
+				Hosts []string `required:"true"`
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "required slice of structs",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{Key: "Server", Values: Values("a")},
+					{Key: "Server", Values: Values("b")},
+				},
+			},
+			dst: &struct {
+				Args   string
+				Server []struct{ Args string } `required:"true"`
+			}{},
+			want: &struct {
+				Args   string
+				Server []struct{ Args string } `required:"true"`
+			}{
+				Server: []struct{ Args string }{{Args: "a"}, {Args: "b"}},
+			},
+		},
+		{
+			name: "map of structs keyed by argument",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key:    "Metric",
+						Values: Values("foo"),
+						Children: []Block{
+							{Key: "Type", Values: Values("gauge")},
+						},
+					},
+					{
+						Key:    "Metric",
+						Values: Values("bar", "extra"),
+						Children: []Block{
+							{Key: "Type", Values: Values("derive")},
+						},
+					},
+				},
+			},
+			dst: &struct {
+				Args   string
+				Metric map[string]struct {
+					Args string
+					Type string
+				}
+			}{},
+			want: &struct {
+				Args   string
+				Metric map[string]struct {
+					Args string
+					Type string
+				}
+			}{
+				Metric: map[string]struct {
+					Args string
+					Type string
+				}{
+					"foo": {Type: "gauge"},
+					"bar": {Args: "extra", Type: "derive"},
+				},
+			},
+		},
+		{
+			name: "map of scalars keyed by child key",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key: "Tags",
+						Children: []Block{
+							{Key: "env", Values: Values("prod")},
+							{Key: "region", Values: Values("eu")},
+						},
+					},
+				},
+			},
+			dst: &struct {
+				Args string
+				Tags map[string]string
+			}{},
+			want: &struct {
+				Args string
+				Tags map[string]string
+			}{
+				Tags: map[string]string{
+					"env":    "prod",
+					"region": "eu",
+				},
+			},
+		},
+		{
+			name: "map duplicate key",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{Key: "Metric", Values: Values("foo")},
+					{Key: "Metric", Values: Values("foo")},
+				},
+			},
+			dst: &struct {
+				Args   string
+				Metric map[string]struct{ Args string }
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "map key not a string",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{Key: "Metric", Values: Values(42)},
+				},
+			},
+			dst: &struct {
+				Args   string
+				Metric map[string]struct{ Args string }
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "map with non-string keys",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key: "Tags",
+						Children: []Block{
+							{Key: "env", Values: Values("prod")},
+						},
+					},
+				},
+			},
+			dst: &struct {
+				Args string
+				Tags map[int]string
+			}{},
+			wantErr: true,
+		},
+		{
+			name: "case sensitive by default",
+			src: Block{
+				Key: "Plugin",
+				Children: []Block{
+					{
+						Key:    "timeout",
+						Values: Values(10),
+					},
+				},
+			},
+			dst: &struct {
+				Args    string
+				Timeout int
+			}{},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -509,6 +912,40 @@ func TestValue_Interface(t *testing.T) {
 	}
 }
 
+func TestValue_Int64(t *testing.T) {
+	cases := []struct {
+		v          Value
+		wantInt64  int64
+		okInt64    bool
+		wantUint64 uint64
+		okUint64   bool
+	}{
+		{v: Float64(0), okInt64: true, okUint64: true},
+		{v: Float64(42), wantInt64: 42, okInt64: true, wantUint64: 42, okUint64: true},
+		{v: Float64(-42), wantInt64: -42, okInt64: true},
+		{v: Float64(1 << 53), wantInt64: 1 << 53, okInt64: true, wantUint64: 1 << 53, okUint64: true},
+		{v: Float64(math.MinInt64), wantInt64: math.MinInt64, okInt64: true},
+		{v: Float64(1 << 63), wantUint64: 1 << 63, okUint64: true},
+		{v: Float64(1 << 64)},
+		{v: Float64(-1 << 64)},
+		{v: Float64(1.5)},
+		{v: Float64(-0.5)},
+		{v: Float64(math.NaN())},
+		{v: Float64(math.Inf(1))},
+		{v: String("42")},
+		{v: Bool(true)},
+	}
+
+	for _, tc := range cases {
+		if got, ok := tc.v.Int64(); got != tc.wantInt64 || ok != tc.okInt64 {
+			t.Errorf("%#v.Int64() = (%d, %v), want (%d, %v)", tc.v, got, ok, tc.wantInt64, tc.okInt64)
+		}
+		if got, ok := tc.v.Uint64(); got != tc.wantUint64 || ok != tc.okUint64 {
+			t.Errorf("%#v.Uint64() = (%d, %v), want (%d, %v)", tc.v, got, ok, tc.wantUint64, tc.okUint64)
+		}
+	}
+}
+
 func TestBlock_Merge(t *testing.T) {
 	makeBlock := func(key, value string, children []Block) Block {
 		return Block{
@@ -595,6 +1032,59 @@ func TestBlock_Merge(t *testing.T) {
 	}
 }
 
+func TestBlock_Walk(t *testing.T) {
+	b := Block{
+		Key:    "Plugin",
+		Values: Values("network"),
+		Children: []Block{
+			{
+				Key: "Server",
+				Children: []Block{
+					{Key: "Username", Values: Values("user")},
+					{Key: "Password", Values: Values("secret")},
+				},
+			},
+			{Key: "Forward", Values: Values(false)},
+		},
+	}
+
+	var got [][]string
+	err := b.Walk(func(path []string, _ Block) error {
+		got = append(got, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk() = %v", err)
+	}
+
+	want := [][]string{
+		{"Plugin"},
+		{"Plugin", "Server"},
+		{"Plugin", "Server", "Username"},
+		{"Plugin", "Server", "Password"},
+		{"Plugin", "Forward"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Walk() paths differ (-want/+got):\n%s", diff)
+	}
+
+	wantErr := errors.New("stop")
+	var visited int
+	err = b.Walk(func(path []string, b Block) error {
+		visited++
+		if b.Key == "Username" {
+			return wantErr
+		}
+		return nil
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Walk() = %v, want %v", err, wantErr)
+	}
+	if visited != 3 {
+		t.Errorf("Walk() visited %d blocks, want 3", visited)
+	}
+}
+
 func TestBlock_MarshalText(t *testing.T) {
 	b := Block{
 		Key: "myPlugin",
@@ -627,3 +1117,155 @@ func TestBlock_MarshalText(t *testing.T) {
 		t.Errorf("MarshalText() differs (-got/+want):\n%s", diff)
 	}
 }
+
+func TestBlock_MarshalText_values(t *testing.T) {
+	b := Block{
+		Key:    "Option",
+		Values: Values(`C:\temp "x"`, 1e21, 0.015, -3),
+	}
+
+	data, err := b.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `Option "C:\\temp \"x\"" 1000000000000000000000 0.015 -3` + "\n"
+	if diff := cmp.Diff(want, string(data)); diff != "" {
+		t.Errorf("MarshalText() differs (-got/+want):\n%s", diff)
+	}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		b := Block{Key: "Option", Values: Values(f)}
+		if _, err := b.MarshalText(); err == nil {
+			t.Errorf("MarshalText(%v) succeeded, want error", f)
+		}
+	}
+}
+
+func TestDuration_UnmarshalConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		values  []Value
+		want    Duration
+		wantErr bool
+	}{
+		{name: "seconds", values: Values(10), want: Duration(10 * time.Second)},
+		{name: "fractional seconds", values: Values(0.25), want: Duration(250 * time.Millisecond)},
+		{name: "zero", values: Values(0), want: 0},
+		{name: "duration string", values: Values("1m30s"), want: Duration(90 * time.Second)},
+		{name: "negative seconds", values: Values(-1), wantErr: true},
+		{name: "negative duration string", values: Values("-5s"), wantErr: true},
+		{name: "out of range", values: Values(1e12), wantErr: true},
+		{name: "not a number", values: Values(math.NaN()), wantErr: true},
+		{name: "infinity", values: Values(math.Inf(1)), wantErr: true},
+		{name: "invalid string", values: Values("soon"), wantErr: true},
+		{name: "number as string", values: Values("10"), wantErr: true},
+		{name: "invalid type", values: Values(true), wantErr: true},
+		{name: "multiple values", values: Values(1, 2), wantErr: true},
+		{name: "no values", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got Duration
+			err := got.UnmarshalConfig(Block{Key: "Interval", Values: tc.values})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("UnmarshalConfig() = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got != tc.want {
+				t.Errorf("UnmarshalConfig() = %v, want %v", time.Duration(got), time.Duration(tc.want))
+			}
+		})
+	}
+
+	// Duration must work as a struct field, too.
+	src := Block{
+		Key: "Plugin",
+		Children: []Block{
+			{Key: "Timeout", Values: Values("2s")},
+		},
+	}
+	var dst struct {
+		Args    string
+		Timeout Duration
+	}
+	if err := src.Unmarshal(&dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := dst.Timeout, Duration(2*time.Second); got != want {
+		t.Errorf("Timeout = %v, want %v", time.Duration(got), time.Duration(want))
+	}
+}
+
+func TestDecoder_AllowUnknown(t *testing.T) {
+	src := Block{
+		Key: "Plugin",
+		Children: []Block{
+			{Key: "Host", Values: Values("example.com")},
+			{Key: "NewOption", Values: Values(true)},
+			{
+				Key: "Server",
+				Children: []Block{
+					{Key: "Port", Values: Values(8080)},
+					{Key: "NewServerOption", Values: Values("x")},
+				},
+			},
+		},
+	}
+
+	type server struct {
+		Args string
+		Port int
+	}
+	type conf struct {
+		Args   string
+		Host   string
+		Server []server
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		var got conf
+		if err := src.Unmarshal(&got); err == nil {
+			t.Error("Unmarshal() succeeded, want error")
+		}
+		if err := NewDecoder(src).Decode(&got); err == nil {
+			t.Error("Decode() succeeded, want error")
+		}
+	})
+
+	t.Run("lenient", func(t *testing.T) {
+		want := conf{
+			Host:   "example.com",
+			Server: []server{{Port: 8080}},
+		}
+
+		var got conf
+		if err := NewDecoder(src).AllowUnknown().Decode(&got); err != nil {
+			t.Fatalf("Decode() = %v", err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Decode() result differs (+got/-want):\n%s", diff)
+		}
+	})
+}
+
+func TestUnmarshal_RequiredError(t *testing.T) {
+	var dst struct {
+		Args     string
+		HostName string `config:"Host" required:"true"`
+	}
+
+	b := Block{Key: "Plugin"}
+	err := b.Unmarshal(&dst)
+	if err == nil {
+		t.Fatal("Unmarshal() succeeded, want error")
+	}
+	for _, want := range []string{`"Host"`, "HostName"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Unmarshal() = %q, want error containing %s", err, want)
+		}
+	}
+}
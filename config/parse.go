@@ -0,0 +1,417 @@
+package config // import "collectd.org/config"
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalText parses collectd's configuration syntax into b. The text must
+// contain exactly one top-level statement, i.e. either a single option or a
+// single block. This is the inverse of MarshalText.
+// Implements the "encoding".TextUnmarshaler interface.
+//
+// Quoted strings are unquoted like collectd does: a backslash causes the next
+// character to be taken literally, so "C:\\temp" is read as C:\temp and
+// "a\.b" as a.b. There are no other escape sequences, i.e. "\n" is read as
+// "n". Unquoted values are converted to booleans ("true", "false", "yes",
+// "no", "on", "off", ignoring case), numbers, or strings, in this order. Only
+// collectd's number syntax is recognized: decimal and hexadecimal integers,
+// and floating point numbers with a decimal point, e.g. "-1.5e+3". Other
+// values, such as "NaN" or "Inf", are strings. Comments start with "#" and
+// extend to the end of the line. A backslash at the end of a line continues
+// the statement on the next line.
+func (b *Block) UnmarshalText(text []byte) error {
+	p := &parser{
+		lex: lexer{data: text, line: 1},
+	}
+
+	blocks, err := p.statements("")
+	if err != nil {
+		return err
+	}
+
+	if len(blocks) != 1 {
+		return fmt.Errorf("got %d top-level statements, want exactly one", len(blocks))
+	}
+
+	*b = blocks[0]
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF     tokenKind = iota
+	tokNewline           // end of a statement
+	tokOpen              // "<"
+	tokClose             // "</"
+	tokEnd               // ">"
+	tokString            // quoted string
+	tokWord              // unquoted string
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of input"
+	case tokNewline:
+		return "end of line"
+	case tokOpen:
+		return `"<"`
+	case tokClose:
+		return `"</"`
+	case tokEnd:
+		return `">"`
+	case tokString:
+		return "quoted string"
+	case tokWord:
+		return "unquoted string"
+	}
+	return fmt.Sprintf("tokenKind(%d)", int(k))
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+type lexer struct {
+	data []byte
+	pos  int
+	line int
+}
+
+// next returns the next token. Whitespace, comments and escaped newlines are
+// skipped.
+func (l *lexer) next() (token, error) {
+	l.skip()
+
+	if l.pos >= len(l.data) {
+		return token{kind: tokEOF, line: l.line}, nil
+	}
+
+	tok := token{line: l.line}
+	switch c := l.data[l.pos]; {
+	case c == '\n':
+		l.pos++
+		l.line++
+		tok.kind = tokNewline
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '/':
+		l.pos += 2
+		tok.kind = tokClose
+	case c == '<':
+		l.pos++
+		tok.kind = tokOpen
+	case c == '>':
+		l.pos++
+		tok.kind = tokEnd
+	case c == '"':
+		s, err := l.quoted()
+		if err != nil {
+			return token{}, err
+		}
+		tok.kind = tokString
+		tok.text = s
+	default:
+		start := l.pos
+		for l.pos < len(l.data) && !isDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		tok.kind = tokWord
+		tok.text = string(l.data[start:l.pos])
+	}
+
+	return tok, nil
+}
+
+// skip advances past whitespace (excluding newlines), comments, and
+// backslash-newline sequences.
+func (l *lexer) skip() {
+	for l.pos < len(l.data) {
+		switch c := l.data[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == '\\' && l.continuation():
+			// continuation() has consumed the line break.
+		default:
+			return
+		}
+	}
+}
+
+// continuation consumes a backslash followed by a line break and reports
+// whether it did so.
+func (l *lexer) continuation() bool {
+	rest := l.data[l.pos+1:]
+	switch {
+	case len(rest) >= 1 && rest[0] == '\n':
+		l.pos += 2
+	case len(rest) >= 2 && rest[0] == '\r' && rest[1] == '\n':
+		l.pos += 3
+	default:
+		return false
+	}
+
+	l.line++
+	return true
+}
+
+// quoted reads a double quoted string, starting at the opening quote, and
+// returns its unquoted value. A backslash escapes the following character.
+// Quoted strings may span multiple lines.
+func (l *lexer) quoted() (string, error) {
+	line := l.line
+
+	var b strings.Builder
+	for i := l.pos + 1; i < len(l.data); i++ {
+		switch c := l.data[i]; c {
+		case '\\':
+			i++
+			if i >= len(l.data) || l.data[i] == '\n' {
+				return "", fmt.Errorf("line %d: invalid escape at end of line", l.line)
+			}
+			b.WriteByte(l.data[i])
+		case '"':
+			l.pos = i + 1
+			return b.String(), nil
+		case '\n':
+			l.line++
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", fmt.Errorf("line %d: unterminated string", line)
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '"', '<', '>', '#':
+		return true
+	}
+	return false
+}
+
+type parser struct {
+	lex lexer
+}
+
+// statements parses options and blocks until the closing tag of the block
+// named end. If end is empty, statements parses until the end of input.
+func (p *parser) statements(end string) ([]Block, error) {
+	var blocks []Block
+	for {
+		tok, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.kind {
+		case tokNewline:
+			continue
+		case tokEOF:
+			if end != "" {
+				return nil, fmt.Errorf("line %d: missing </%s>", tok.line, end)
+			}
+			return blocks, nil
+		case tokClose:
+			if err := p.closeTag(end); err != nil {
+				return nil, err
+			}
+			return blocks, nil
+		case tokOpen:
+			b, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, b)
+		case tokWord:
+			values, err := p.values(tokNewline)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, Block{
+				Key:    tok.text,
+				Values: values,
+			})
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %v", tok.line, tok.kind)
+		}
+	}
+}
+
+// block parses a block, starting after the opening "<".
+func (p *parser) block() (Block, error) {
+	tok, err := p.lex.next()
+	if err != nil {
+		return Block{}, err
+	}
+	if tok.kind != tokWord {
+		return Block{}, fmt.Errorf("line %d: got %v, want block name", tok.line, tok.kind)
+	}
+
+	values, err := p.values(tokEnd)
+	if err != nil {
+		return Block{}, err
+	}
+	if err := p.endOfLine(); err != nil {
+		return Block{}, err
+	}
+
+	children, err := p.statements(tok.text)
+	if err != nil {
+		return Block{}, err
+	}
+
+	return Block{
+		Key:      tok.text,
+		Values:   values,
+		Children: children,
+	}, nil
+}
+
+// closeTag parses a closing tag, starting after the "</". Like collectd, the
+// name is compared case-insensitively.
+func (p *parser) closeTag(end string) error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	if tok.kind != tokWord {
+		return fmt.Errorf("line %d: got %v, want block name", tok.line, tok.kind)
+	}
+	if end == "" {
+		return fmt.Errorf("line %d: unexpected </%s>", tok.line, tok.text)
+	}
+	if !strings.EqualFold(tok.text, end) {
+		return fmt.Errorf("line %d: got </%s>, want </%s>", tok.line, tok.text, end)
+	}
+
+	if tok, err = p.lex.next(); err != nil {
+		return err
+	}
+	if tok.kind != tokEnd {
+		return fmt.Errorf("line %d: got %v, want %v", tok.line, tok.kind, tokEnd)
+	}
+
+	return p.endOfLine()
+}
+
+// values parses values until a token of kind end is found. Options, i.e. values
+// terminated by a newline, may also be terminated by the end of input.
+func (p *parser) values(end tokenKind) ([]Value, error) {
+	var values []Value
+	for {
+		tok, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case tok.kind == end, tok.kind == tokEOF && end == tokNewline:
+			return values, nil
+		case tok.kind == tokString:
+			values = append(values, String(tok.text))
+		case tok.kind == tokWord:
+			values = append(values, parseWord(tok.text))
+		default:
+			return nil, fmt.Errorf("line %d: got %v, want %v", tok.line, tok.kind, end)
+		}
+	}
+}
+
+// endOfLine ensures that the remainder of the line is empty.
+func (p *parser) endOfLine() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	if tok.kind != tokNewline && tok.kind != tokEOF {
+		return fmt.Errorf("line %d: got %v, want %v", tok.line, tok.kind, tokNewline)
+	}
+	return nil
+}
+
+// parseWord converts an unquoted value to a Value.
+func parseWord(s string) Value {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on":
+		return Bool(true)
+	case "false", "no", "off":
+		return Bool(false)
+	}
+
+	if isHex(s) {
+		// ParseFloat requires an exponent for hexadecimal numbers.
+		if f, err := strconv.ParseFloat(s+"p0", 64); err == nil {
+			return Float64(f)
+		}
+	}
+	if isDecimal(s) {
+		// Numbers too large for a float64 are reported as ErrRange
+		// and converted to ±Inf, like strtod(3) does.
+		f, _ := strconv.ParseFloat(s, 64)
+		return Float64(f)
+	}
+
+	return String(s)
+}
+
+// isHex reports whether s is a hexadecimal integer, e.g. "0x1F".
+func isHex(s string) bool {
+	if len(s) < 3 || s[0] != '0' || (s[1] != 'x' && s[1] != 'X') {
+		return false
+	}
+	for _, c := range []byte(s[2:]) {
+		if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') && !('A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isDecimal reports whether s is a decimal integer or a floating point number
+// in collectd's syntax. Floating point numbers require a decimal point and at
+// least one digit after it. The exponent, if any, must be signed.
+func isDecimal(s string) bool {
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+
+	intPart, frac, ok := strings.Cut(s, ".")
+	if !ok {
+		return isDigits(s)
+	}
+	if intPart != "" && !isDigits(intPart) {
+		return false
+	}
+
+	mantissa, exp, ok := strings.Cut(strings.ToLower(frac), "e")
+	if !isDigits(mantissa) {
+		return false
+	}
+	if !ok {
+		return true
+	}
+	return len(exp) > 1 && (exp[0] == '+' || exp[0] == '-') && isDigits(exp[1:])
+}
+
+// isDigits reports whether s is a non-empty sequence of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBlock_UnmarshalText(t *testing.T) {
+	cases := []struct {
+		title   string
+		text    string
+		want    Block
+		wantErr bool
+	}{
+		{
+			title: "option",
+			text:  `Interval 10`,
+			want: Block{
+				Key:    "Interval",
+				Values: Values(10),
+			},
+		},
+		{
+			title: "multiple values",
+			text:  "Listen \"localhost\" 8080 true\n",
+			want: Block{
+				Key:    "Listen",
+				Values: Values("localhost", 8080, true),
+			},
+		},
+		{
+			title: "unquoted values",
+			text:  `Option Yes OFF -1.5e+3 eth0 1.2.3`,
+			want: Block{
+				Key:    "Option",
+				Values: Values(true, false, -1500, "eth0", "1.2.3"),
+			},
+		},
+		{
+			title: "numbers",
+			text:  `Option 42 +7 -3 010 0x1F .5 -0.25 1.5E-2`,
+			want: Block{
+				Key:    "Option",
+				Values: Values(42, 7, -3, 10, 31, 0.5, -0.25, 0.015),
+			},
+		},
+		{
+			title: "not numbers",
+			text:  `Option NaN Inf -Inf infinity 1e5 1. 1.5e3 0x 1_000`,
+			want: Block{
+				Key:    "Option",
+				Values: Values("NaN", "Inf", "-Inf", "infinity", "1e5", "1.", "1.5e3", "0x", "1_000"),
+			},
+		},
+		{
+			title: "escaped strings",
+			text:  `Option "say \"hi\"" "C:\\temp" "# not a comment"`,
+			want: Block{
+				Key:    "Option",
+				Values: Values(`say "hi"`, `C:\temp`, "# not a comment"),
+			},
+		},
+		{
+			title: "backslash escapes the next character",
+			text:  `Option "C:\foo" "a\.b" "tab\there" "new\nline" "\q"`,
+			want: Block{
+				Key:    "Option",
+				Values: Values("C:foo", "a.b", "tabthere", "newnline", "q"),
+			},
+		},
+		{
+			title: "multi-line string",
+			text:  "Option \"foo\nbar\"\n",
+			want: Block{
+				Key:    "Option",
+				Values: Values("foo\nbar"),
+			},
+		},
+		{
+			title: "nested blocks",
+			text: `# leading comment
+<Plugin "network">
+  <Server "example.com" "25826"> # trailing comment
+    SecurityLevel "Encrypt"
+
+    Username "user"
+  </Server>
+  Forward false
+</plugin>
+`,
+			want: Block{
+				Key:    "Plugin",
+				Values: Values("network"),
+				Children: []Block{
+					{
+						Key:    "Server",
+						Values: Values("example.com", "25826"),
+						Children: []Block{
+							{Key: "SecurityLevel", Values: Values("Encrypt")},
+							{Key: "Username", Values: Values("user")},
+						},
+					},
+					{Key: "Forward", Values: Values(false)},
+				},
+			},
+		},
+		{
+			title: "empty block",
+			text:  "<Block>\n</Block>",
+			want:  Block{Key: "Block"},
+		},
+		{
+			title: "line continuation",
+			text:  "Option \"a\" \\\n  \"b\" \\\r\n  \"c\"\n",
+			want: Block{
+				Key:    "Option",
+				Values: Values("a", "b", "c"),
+			},
+		},
+		{
+			title: "CRLF line endings",
+			text:  "<Block>\r\n  Option 1\r\n</Block>\r\n",
+			want: Block{
+				Key:      "Block",
+				Children: []Block{{Key: "Option", Values: Values(1)}},
+			},
+		},
+		{
+			title:   "empty",
+			text:    "# only a comment\n",
+			wantErr: true,
+		},
+		{
+			title:   "multiple statements",
+			text:    "A 1\nB 2\n",
+			wantErr: true,
+		},
+		{
+			title:   "missing closing tag",
+			text:    "<Block>\n  Option 1\n",
+			wantErr: true,
+		},
+		{
+			title:   "mismatched closing tag",
+			text:    "<Block>\n</Other>\n",
+			wantErr: true,
+		},
+		{
+			title:   "unexpected closing tag",
+			text:    "</Block>\n",
+			wantErr: true,
+		},
+		{
+			title:   "unterminated string",
+			text:    "Option \"foo\nBar 1\n",
+			wantErr: true,
+		},
+		{
+			title:   "escaped end of input",
+			text:    `Option "foo\`,
+			wantErr: true,
+		},
+		{
+			title:   "escaped newline in string",
+			text:    "Option \"foo\\\nbar\"\n",
+			wantErr: true,
+		},
+		{
+			title:   "statement after opening tag",
+			text:    "<Block> Option 1\n</Block>\n",
+			wantErr: true,
+		},
+		{
+			title:   "missing >",
+			text:    "<Block \"foo\"\n</Block>\n",
+			wantErr: true,
+		},
+		{
+			title:   "value starting with <",
+			text:    "Option <foo>\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			var got Block
+			err := got.UnmarshalText([]byte(tc.text))
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("UnmarshalText() = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(Value{})); diff != "" {
+				t.Errorf("UnmarshalText() differs (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBlock_UnmarshalText_roundtrip(t *testing.T) {
+	want := Block{
+		Key:    "Plugin",
+		Values: Values("test"),
+		Children: []Block{
+			{
+				Key:    "Listen",
+				Values: Values("localhost", 8080, 0.5),
+				Children: []Block{
+					{Key: "KeepAlive", Values: Values(true)},
+					{Key: "Expect", Values: Values("quote\" backslash\\ newline\n ünicode")},
+				},
+			},
+			{Key: "Empty"},
+			{Key: "Large", Values: Values(1e100, -3)},
+		},
+	}
+
+	text, err := want.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Block
+	if err := got.UnmarshalText(text); err != nil {
+		t.Fatalf("UnmarshalText(%q) = %v", text, err)
+	}
+
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(Value{})); diff != "" {
+		t.Errorf("UnmarshalText(MarshalText()) differs (-want/+got):\n%s", diff)
+	}
+
+	text2, err := got.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(text), string(text2)); diff != "" {
+		t.Errorf("MarshalText(UnmarshalText()) differs (-want/+got):\n%s", diff)
+	}
+}
@@ -14,16 +14,41 @@ import (
 	"collectd.org/format"
 )
 
-// Putval is the dispatcher used by the exec package to print ValueLists.
-var Putval api.Writer = format.NewPutval(os.Stdout)
+// Putval is the dispatcher used by the exec package to print ValueLists. Value
+// lists with an empty Host field are printed with the host returned by
+// Hostname.
+var Putval api.Writer = format.NewPutval(os.Stdout, format.WithHostname(Hostname()))
+
+// Notifier is implemented by types that handle notifications, such as
+// *format.Putnotif.
+type Notifier interface {
+	Notify(context.Context, *api.Notification) error
+}
+
+// Putnotif is the notifier used by the exec package to print notifications.
+var Putnotif Notifier = format.NewPutnotif(os.Stdout)
 
 type callback interface {
-	run(context.Context, *sync.WaitGroup)
+	run(context.Context, *sync.WaitGroup, runOpts)
 	stop()
 }
 
+// runOpts holds the Executor settings passed to callbacks.
+type runOpts struct {
+	onError     func(error)
+	immediately bool
+}
+
 // Executor holds one or more callbacks which are called periodically.
 type Executor struct {
+	// OnError, if not nil, is called when printing a value list or
+	// notification fails. If nil, errors printing value lists are ignored
+	// and errors printing notifications are logged.
+	OnError func(error)
+	// RunImmediately, if true, causes each callback to be called once
+	// when Run is called, instead of only after the first interval.
+	RunImmediately bool
+
 	cb    []callback
 	group sync.WaitGroup
 }
@@ -48,7 +73,8 @@ func (e *Executor) ValueCallback(callback func() api.Value, vl *api.ValueList) {
 
 // VoidCallback adds a "complex" callback to the Executor. While the functions
 // prototype is simpler, all the work has to be done by the callback, i.e. the
-// callback needs to format and print the appropriate lines to "STDOUT".
+// callback needs to format and print the appropriate lines to "STDOUT", for
+// example using Putval.
 // However, this allows cases in which the number of values reported varies,
 // e.g. depending on the system the code is running on.
 func (e *Executor) VoidCallback(callback func(context.Context, time.Duration), interval time.Duration) {
@@ -59,11 +85,27 @@ func (e *Executor) VoidCallback(callback func(context.Context, time.Duration), i
 	})
 }
 
+// NotificationCallback adds a "notification" callback to the Executor. The
+// callback is called every interval and returns a notification, which is
+// printed as a PUTNOTIF command by the executor. If the callback returns nil,
+// nothing is printed. Empty Host and Time fields are filled in with Hostname()
+// and the current time, respectively.
+func (e *Executor) NotificationCallback(callback func(context.Context) *api.Notification, interval time.Duration) {
+	e.cb = append(e.cb, &notificationCallback{
+		callback: callback,
+		interval: interval,
+		done:     make(chan struct{}),
+	})
+}
+
 // Run starts calling all callbacks periodically and blocks.
 func (e *Executor) Run(ctx context.Context) {
 	for _, cb := range e.cb {
 		e.group.Add(1)
-		go cb.run(ctx, &e.group)
+		go cb.run(ctx, &e.group, runOpts{
+			onError:     e.OnError,
+			immediately: e.RunImmediately,
+		})
 	}
 
 	e.group.Wait()
@@ -83,7 +125,7 @@ type valueCallback struct {
 	done     chan struct{}
 }
 
-func (cb *valueCallback) run(ctx context.Context, g *sync.WaitGroup) {
+func (cb *valueCallback) run(ctx context.Context, g *sync.WaitGroup, opts runOpts) {
 	defer g.Done()
 
 	if cb.vl.Host == "" {
@@ -91,19 +133,13 @@ func (cb *valueCallback) run(ctx context.Context, g *sync.WaitGroup) {
 	}
 	cb.vl.Interval = sanitizeInterval(cb.vl.Interval)
 
-	ticker := time.NewTicker(cb.vl.Interval)
-	for {
-		select {
-		case <-ticker.C:
-			cb.vl.Values = []api.Value{cb.callback()}
-			cb.vl.Time = time.Now()
-			Putval.Write(ctx, &cb.vl)
-		case <-cb.done:
-			return
-		case <-ctx.Done():
-			return
+	loop(ctx, cb.done, cb.vl.Interval, opts.immediately, func() {
+		cb.vl.Values = []api.Value{cb.callback()}
+		cb.vl.Time = time.Now()
+		if err := Putval.Write(ctx, &cb.vl); err != nil && opts.onError != nil {
+			opts.onError(err)
 		}
-	}
+	})
 }
 
 func (cb *valueCallback) stop() {
@@ -116,16 +152,67 @@ type voidCallback struct {
 	done     chan struct{}
 }
 
-func (cb voidCallback) run(ctx context.Context, g *sync.WaitGroup) {
+func (cb voidCallback) run(ctx context.Context, g *sync.WaitGroup, opts runOpts) {
+	defer g.Done()
+
+	loop(ctx, cb.done, sanitizeInterval(cb.interval), opts.immediately, func() {
+		cb.callback(ctx, cb.interval)
+	})
+}
+
+func (cb voidCallback) stop() {
+	close(cb.done)
+}
+
+type notificationCallback struct {
+	callback func(context.Context) *api.Notification
+	interval time.Duration
+	done     chan struct{}
+}
+
+func (cb *notificationCallback) run(ctx context.Context, g *sync.WaitGroup, opts runOpts) {
 	defer g.Done()
 
-	ticker := time.NewTicker(sanitizeInterval(cb.interval))
+	loop(ctx, cb.done, sanitizeInterval(cb.interval), opts.immediately, func() {
+		n := cb.callback(ctx)
+		if n == nil {
+			return
+		}
+		if n.Host == "" {
+			n.Host = Hostname()
+		}
+		if n.Time.IsZero() {
+			n.Time = time.Now()
+		}
+		if err := Putnotif.Notify(ctx, n); err != nil {
+			if opts.onError == nil {
+				log.Printf("unable to print notification: %v", err)
+				return
+			}
+			opts.onError(err)
+		}
+	})
+}
+
+func (cb *notificationCallback) stop() {
+	close(cb.done)
+}
+
+// loop calls fn every interval until done is closed or ctx is canceled. If
+// immediately is true, fn is also called once before the first tick.
+func loop(ctx context.Context, done <-chan struct{}, interval time.Duration, immediately bool, fn func()) {
+	if immediately {
+		fn()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			cb.callback(ctx, cb.interval)
-		case <-cb.done:
+			fn()
+		case <-done:
 			return
 		case <-ctx.Done():
 			return
@@ -133,10 +220,6 @@ func (cb voidCallback) run(ctx context.Context, g *sync.WaitGroup) {
 	}
 }
 
-func (cb voidCallback) stop() {
-	close(cb.done)
-}
-
 // Interval determines the default interval from the "COLLECTD_INTERVAL"
 // environment variable. It falls back to 10s if the environment variable is
 // unset or cannot be parsed.
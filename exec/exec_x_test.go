@@ -1,17 +1,21 @@
 package exec_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"log"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"collectd.org/api"
 	"collectd.org/exec"
+	"collectd.org/format"
+	"collectd.org/internal/apitest"
 	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 type testWriter struct {
@@ -93,7 +97,7 @@ func TestValueCallback_ExecutorStop(t *testing.T) {
 				Values:   []api.Value{api.Derive(42)},
 				DSNames:  []string{"value"},
 			}
-			if diff := cmp.Diff(want, w.vl, cmpopts.IgnoreFields(api.ValueList{}, "Time")); diff != "" {
+			if diff := cmp.Diff(want, w.vl, apitest.IgnoreTime()); diff != "" {
 				t.Errorf("received value lists differ (+got/-want):\n%s", diff)
 			}
 		})
@@ -148,3 +152,172 @@ func TestVoidCallback(t *testing.T) {
 		})
 	}
 }
+
+func TestNotificationCallback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := os.Setenv("COLLECTD_HOSTNAME", "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		os.Unsetenv("COLLECTD_HOSTNAME")
+	}()
+
+	savedPutnotif := exec.Putnotif
+	defer func() {
+		exec.Putnotif = savedPutnotif
+	}()
+
+	var b strings.Builder
+	exec.Putnotif = format.NewPutnotif(&b)
+
+	e := exec.NewExecutor()
+
+	var calls int
+	e.NotificationCallback(func(_ context.Context) *api.Notification {
+		calls++
+		switch calls {
+		case 1:
+			// Returning nil must not print anything.
+			return nil
+		case 2:
+			return &api.Notification{
+				Identifier: api.Identifier{
+					Plugin: "go-exec",
+				},
+				Time:     time.Unix(1587671455, 0),
+				Severity: api.Warning,
+				Message:  `Value "x" is out of range`,
+			}
+		default:
+			e.Stop()
+			return nil
+		}
+	}, time.Millisecond)
+
+	// e.Run() blocks until e.Stop() is called by the callback above.
+	e.Run(ctx)
+
+	want := `PUTNOTIF severity=warning time=1587671455.000 host="example.com" plugin="go-exec" message="Value \"x\" is out of range"` + "\n"
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("printed notifications differ (+got/-want):\n%s", diff)
+	}
+}
+
+type errorWriter struct {
+	err error
+}
+
+func (w errorWriter) Write(context.Context, *api.ValueList) error {
+	return w.err
+}
+
+func TestExecutor_OnError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	savedPutval := exec.Putval
+	defer func() {
+		exec.Putval = savedPutval
+	}()
+
+	wantErr := errors.New("write failed")
+	exec.Putval = errorWriter{err: wantErr}
+
+	e := exec.NewExecutor()
+
+	var gotErrs []error
+	e.OnError = func(err error) {
+		gotErrs = append(gotErrs, err)
+		e.Stop()
+	}
+
+	e.ValueCallback(func() api.Value {
+		return api.Gauge(42)
+	}, &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "go-exec",
+			Type:   "gauge",
+		},
+		Interval: time.Millisecond,
+		DSNames:  []string{"value"},
+	})
+
+	// e.Run() blocks until e.Stop() is called by the error handler above.
+	e.Run(ctx)
+
+	if len(gotErrs) != 1 || !errors.Is(gotErrs[0], wantErr) {
+		t.Errorf("OnError called with %v, want [%v]", gotErrs, wantErr)
+	}
+}
+
+type errorNotifier struct {
+	err error
+}
+
+func (n errorNotifier) Notify(context.Context, *api.Notification) error {
+	return n.err
+}
+
+func TestExecutor_NotifyErrorLogged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	savedPutnotif := exec.Putnotif
+	defer func() {
+		exec.Putnotif = savedPutnotif
+	}()
+	exec.Putnotif = errorNotifier{err: errors.New("notify failed")}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	e := exec.NewExecutor()
+	e.NotificationCallback(func(context.Context) *api.Notification {
+		e.Stop()
+		return &api.Notification{
+			Severity: api.Warning,
+			Message:  "TestExecutor_NotifyErrorLogged",
+		}
+	}, time.Millisecond)
+
+	// e.Run() blocks until e.Stop() is called by the callback above.
+	e.Run(ctx)
+
+	if want := "unable to print notification: notify failed"; !strings.Contains(buf.String(), want) {
+		t.Errorf("log output = %q, want it to contain %q", buf.String(), want)
+	}
+}
+
+func TestExecutor_RunImmediately(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	e := exec.NewExecutor()
+	e.RunImmediately = true
+
+	start := time.Now()
+	var (
+		called bool
+		first  time.Duration
+	)
+	e.VoidCallback(func(context.Context, time.Duration) {
+		called = true
+		first = time.Since(start)
+		e.Stop()
+	}, time.Hour)
+
+	// e.Run() blocks until e.Stop() is called by the callback above, or
+	// until the context times out.
+	e.Run(ctx)
+
+	if !called {
+		t.Fatal("callback was not called")
+	}
+	if max := 100 * time.Millisecond; first > max {
+		t.Errorf("first call after %v, want at most %v", first, max)
+	}
+}
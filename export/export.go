@@ -8,11 +8,14 @@ other existing metric collection frameworks with it. This package differs in
 that it has an explicitly cumulative type, Derive.
 
 The intended usage pattern of this package is as follows: First, global
-variables are initialized with NewDerive(), NewGauge(), NewDeriveString() or
-NewGaugeString(). The Run() function is called as a separate goroutine as part
-of your program's initialization and, last but not least, the variables are
-updated with their respective update functions, Add() for Derive and Set() for
-Gauge.
+variables are initialized with NewDerive(), NewCounter(), NewGauge() or their
+*String() variants. The Run() function is called as a separate goroutine as
+part of your program's initialization and, last but not least, the variables
+are updated with their respective update functions, Add() for Derive and
+Counter and Set() for Gauge.
+
+The package-level functions operate on a default registry. Use NewRegistry()
+to create independent sets of metrics, e.g. in libraries or tests.
 
   // Initialize global variable.
   var requestCounter = export.NewDeriveString("example.com/golang/total_requests")
@@ -45,6 +48,7 @@ package export // import "collectd.org/export"
 import (
 	"context"
 	"expvar"
+	"fmt"
 	"log"
 	"math"
 	"strconv"
@@ -52,11 +56,7 @@ import (
 	"time"
 
 	"collectd.org/api"
-)
-
-var (
-	mutex sync.RWMutex
-	vars  []Var
+	"go.uber.org/multierr"
 )
 
 // Var is an abstract type for metrics exported by this package.
@@ -64,44 +64,178 @@ type Var interface {
 	ValueList() *api.ValueList
 }
 
-// Publish adds v to the internal list of exported metrics.
-func Publish(v Var) {
-	mutex.Lock()
-	defer mutex.Unlock()
+// Registry holds a set of exported metrics. Metrics are identified by the
+// string representation of their identifier. The zero value is not usable;
+// use NewRegistry to create a Registry.
+//
+// Unlike the package-level functions, which operate on a default registry,
+// metrics created with a Registry's methods are not registered with the
+// "expvar" package. This allows multiple independent registries to export
+// metrics with the same name.
+type Registry struct {
+	mu sync.RWMutex
+	// vars holds the metrics in the order they were published. index maps
+	// names to positions in vars.
+	vars  []Var
+	index map[string]int
+}
 
-	vars = append(vars, v)
+// NewRegistry returns a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		index: make(map[string]int),
+	}
 }
 
-// Options holds options for the Run() function.
-type Options struct {
-	Interval time.Duration
+var defaultRegistry = NewRegistry()
+
+// Publish adds v to the registry. A previously published Var with the same
+// name is replaced and keeps its position.
+func (r *Registry) Publish(v Var) {
+	name := v.ValueList().Identifier.String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i, ok := r.index[name]; ok {
+		r.vars[i] = v
+		return
+	}
+	r.index[name] = len(r.vars)
+	r.vars = append(r.vars, v)
 }
 
-// Run periodically calls the ValueList function of each Var, sets the Time and
-// Interval fields and passes it w.Write(). This function blocks until the
-// context is cancelled.
-func Run(ctx context.Context, w api.Writer, opts Options) error {
+// Get returns the Var with the given name, or nil if there is no such Var.
+func (r *Registry) Get(name string) Var {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.index[name]
+	if !ok {
+		return nil
+	}
+	return r.vars[i]
+}
+
+// Unregister removes the Var with the given name from the registry. It is not
+// an error if there is no such Var.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.index[name]
+	if !ok {
+		return
+	}
+	r.vars = append(r.vars[:i], r.vars[i+1:]...)
+	delete(r.index, name)
+	for name, j := range r.index {
+		if j > i {
+			r.index[name] = j - 1
+		}
+	}
+}
+
+// NewDerive initializes a new Derive, adds it to the registry and returns it.
+// The initial value is zero.
+func (r *Registry) NewDerive(id api.Identifier) *Derive {
+	d := &Derive{
+		id:    id,
+		value: 0,
+	}
+
+	r.Publish(d)
+	return d
+}
+
+// NewCounter initializes a new Counter, adds it to the registry and returns
+// it. The initial value is zero.
+func (r *Registry) NewCounter(id api.Identifier) *Counter {
+	c := &Counter{
+		id:    id,
+		value: 0,
+	}
+
+	r.Publish(c)
+	return c
+}
+
+// NewGauge initializes a new Gauge, adds it to the registry and returns it.
+// The initial value is NaN.
+func (r *Registry) NewGauge(id api.Identifier) *Gauge {
+	g := &Gauge{
+		id:    id,
+		value: api.Gauge(math.NaN()),
+	}
+
+	r.Publish(g)
+	return g
+}
+
+// WriteAll calls the ValueList function of each Var, sets the Time and
+// Interval fields and passes it to w.Write(). Vars are written in the order
+// they were published. Errors returned by w are
+// combined and returned after all metrics have been written.
+func (r *Registry) WriteAll(ctx context.Context, w api.Writer, interval time.Duration) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs error
+	now := time.Now()
+	for _, v := range r.vars {
+		vl := v.ValueList()
+		vl.Time = now
+		vl.Interval = interval
+		if err := w.Write(ctx, vl); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("%T.Write(): %w", w, err))
+		}
+	}
+
+	return errs
+}
+
+// Run periodically calls WriteAll. Errors are logged. This function blocks
+// until the context is cancelled.
+func (r *Registry) Run(ctx context.Context, w api.Writer, opts Options) error {
 	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			mutex.RLock()
-			for _, v := range vars {
-				vl := v.ValueList()
-				vl.Time = time.Now()
-				vl.Interval = opts.Interval
-				if err := w.Write(ctx, vl); err != nil {
-					log.Printf("%T.Write(): %v", w, err)
-				}
+			if err := r.WriteAll(ctx, w, opts.Interval); err != nil {
+				log.Print(err)
 			}
-			mutex.RUnlock()
 		case <-ctx.Done():
 			return ctx.Err()
 		}
 	}
 }
 
+// Publish adds v to the default registry.
+func Publish(v Var) {
+	defaultRegistry.Publish(v)
+}
+
+// Unregister removes the Var with the given name from the default registry.
+// The Var stays registered with the "expvar" package, which does not support
+// removal.
+func Unregister(name string) {
+	defaultRegistry.Unregister(name)
+}
+
+// Options holds options for the Run() function.
+type Options struct {
+	Interval time.Duration
+}
+
+// Run periodically calls the ValueList function of each Var in the default
+// registry, sets the Time and Interval fields and passes it w.Write(). This
+// function blocks until the context is cancelled.
+func Run(ctx context.Context, w api.Writer, opts Options) error {
+	return defaultRegistry.Run(ctx, w, opts)
+}
+
 // Derive represents a cumulative integer data type, for example "requests
 // served since server start". It implements the Var and expvar.Var interfaces.
 type Derive struct {
@@ -110,15 +244,11 @@ type Derive struct {
 	value api.Derive
 }
 
-// NewDerive initializes a new Derive, registers it with the "expvar" package
-// and returns it. The initial value is zero.
+// NewDerive initializes a new Derive, adds it to the default registry,
+// registers it with the "expvar" package and returns it. The initial value is
+// zero.
 func NewDerive(id api.Identifier) *Derive {
-	d := &Derive{
-		id:    id,
-		value: 0,
-	}
-
-	Publish(d)
+	d := defaultRegistry.NewDerive(id)
 	expvar.Publish(id.String(), d)
 	return d
 }
@@ -160,6 +290,61 @@ func (d *Derive) ValueList() *api.ValueList {
 	}
 }
 
+// Counter represents a monotonically increasing unsigned integer data type,
+// for example "bytes sent since server start". It implements the Var and
+// expvar.Var interfaces.
+type Counter struct {
+	mu    sync.RWMutex
+	id    api.Identifier
+	value api.Counter
+}
+
+// NewCounter initializes a new Counter, adds it to the default registry,
+// registers it with the "expvar" package and returns it. The initial value is
+// zero.
+func NewCounter(id api.Identifier) *Counter {
+	c := defaultRegistry.NewCounter(id)
+	expvar.Publish(id.String(), c)
+	return c
+}
+
+// NewCounterString parses s as an Identifier and returns a new Counter. If
+// parsing s fails, it will panic. This simplifies initializing global
+// variables.
+func NewCounterString(s string) *Counter {
+	id, err := api.ParseIdentifier(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return NewCounter(id)
+}
+
+// Add adds delta to c.
+func (c *Counter) Add(delta uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.value += api.Counter(delta)
+}
+
+// String returns the string representation of c.
+func (c *Counter) String() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return strconv.FormatUint(uint64(c.value), 10)
+}
+
+// ValueList returns the ValueList representation of c. Both, Time and Interval
+// are set to zero.
+func (c *Counter) ValueList() *api.ValueList {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return &api.ValueList{
+		Identifier: c.id,
+		Values:     []api.Value{c.value},
+	}
+}
+
 // Gauge represents an absolute floating point data type, for example "heap
 // memory used". It implements the Var and expvar.Var interfaces.
 type Gauge struct {
@@ -168,15 +353,10 @@ type Gauge struct {
 	value api.Gauge
 }
 
-// NewGauge initializes a new Gauge, registers it with the "expvar" package and
-// returns it. The initial value is NaN.
+// NewGauge initializes a new Gauge, adds it to the default registry, registers
+// it with the "expvar" package and returns it. The initial value is NaN.
 func NewGauge(id api.Identifier) *Gauge {
-	g := &Gauge{
-		id:    id,
-		value: api.Gauge(math.NaN()),
-	}
-
-	Publish(g)
+	g := defaultRegistry.NewGauge(id)
 	expvar.Publish(id.String(), g)
 	return g
 }
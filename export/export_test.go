@@ -9,15 +9,14 @@ import (
 	"time"
 
 	"collectd.org/api"
+	"collectd.org/internal/apitest"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestDerive(t *testing.T) {
 	// clean up shared resource after testing
-	defer func() {
-		vars = nil
-	}()
+	defer Unregister("example.com/TestDerive/derive")
 
 	d := NewDeriveString("example.com/TestDerive/derive")
 	for i := 0; i < 10; i++ {
@@ -44,11 +43,38 @@ func TestDerive(t *testing.T) {
 	}
 }
 
+func TestCounter(t *testing.T) {
+	// clean up shared resource after testing
+	defer Unregister("example.com/TestCounter/counter")
+
+	c := NewCounterString("example.com/TestCounter/counter")
+	for i := 0; i < 10; i++ {
+		c.Add(uint64(i))
+	}
+
+	want := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestCounter",
+			Type:   "counter",
+		},
+		Values: []api.Value{api.Counter(45)},
+	}
+	got := c.ValueList()
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Counter.ValueList() differs (+got/-want):\n%s", diff)
+	}
+
+	s := expvar.Get("example.com/TestCounter/counter").String()
+	if s != "45" {
+		t.Errorf("got %q, want %q", s, "45")
+	}
+}
+
 func TestGauge(t *testing.T) {
 	// clean up shared resource after testing
-	defer func() {
-		vars = nil
-	}()
+	defer Unregister("example.com/TestGauge/gauge")
 
 	g := NewGaugeString("example.com/TestGauge/gauge")
 	g.Set(42.0)
@@ -91,9 +117,8 @@ func TestRun(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	// clean up shared resource after testing
-	defer func() {
-		vars = nil
-	}()
+	defer Unregister("example.com/TestRun/derive")
+	defer Unregister("example.com/TestRun/gauge")
 
 	d := NewDeriveString("example.com/TestRun/derive")
 	d.Add(23)
@@ -148,15 +173,129 @@ func TestRun(t *testing.T) {
 	ignoreOrder := cmpopts.SortSlices(func(a, b *api.ValueList) bool {
 		return a.Identifier.String() < b.Identifier.String()
 	})
-	approximateTime := cmp.Comparer(func(t0, t1 time.Time) bool {
-		diff := t0.Sub(t1)
-		if t1.After(t0) {
-			diff = t1.Sub(t0)
+	for i, vl := range w.got {
+		if diff := time.Since(vl.Time); diff < -2*time.Second || diff > 2*time.Second {
+			t.Errorf("got[%d].Time = %v, want approximately %v", i, vl.Time, time.Now())
 		}
+	}
+	if diff := cmp.Diff(want, w.got, ignoreOrder, apitest.IgnoreTime()); diff != "" {
+		t.Errorf("received value lists differ (+got/-want):\n%s", diff)
+	}
+}
 
-		return diff < 2*time.Second
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	d := r.NewDerive(api.Identifier{
+		Host:   "example.com",
+		Plugin: "TestRegistry",
+		Type:   "derive",
 	})
-	if diff := cmp.Diff(want, w.got, ignoreOrder, approximateTime); diff != "" {
-		t.Errorf("received value lists differ (+got/-want):\n%s", diff)
+	d.Add(23)
+
+	g := r.NewGauge(api.Identifier{
+		Host:   "example.com",
+		Plugin: "TestRegistry",
+		Type:   "gauge",
+	})
+	g.Set(42)
+
+	if got := r.Get("example.com/TestRegistry/derive"); got != d {
+		t.Errorf("Get(%q) = %v, want %v", "example.com/TestRegistry/derive", got, d)
+	}
+	if got := r.Get("example.com/TestRegistry/invalid"); got != nil {
+		t.Errorf("Get(%q) = %v, want nil", "example.com/TestRegistry/invalid", got)
+	}
+
+	// Registries are independent of each other and of the default
+	// registry.
+	if got := NewRegistry().Get("example.com/TestRegistry/derive"); got != nil {
+		t.Errorf("new registry: Get() = %v, want nil", got)
+	}
+	if got := defaultRegistry.Get("example.com/TestRegistry/derive"); got != nil {
+		t.Errorf("default registry: Get() = %v, want nil", got)
+	}
+
+	r.Unregister("example.com/TestRegistry/gauge")
+	if got := r.Get("example.com/TestRegistry/gauge"); got != nil {
+		t.Errorf("Get(%q) = %v after Unregister, want nil", "example.com/TestRegistry/gauge", got)
+	}
+	// Unregistering unknown metrics is a no-op.
+	r.Unregister("example.com/TestRegistry/invalid")
+
+	var w recorder
+	if err := r.WriteAll(context.Background(), &w, 10*time.Second); err != nil {
+		t.Fatalf("WriteAll() = %v", err)
+	}
+
+	want := []*api.ValueList{
+		{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "TestRegistry",
+				Type:   "derive",
+			},
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Derive(23)},
+		},
+	}
+	if len(w.got) != 0 && w.got[0].Time.IsZero() {
+		t.Error("WriteAll() did not set Time")
+	}
+	if diff := cmp.Diff(want, w.got, apitest.IgnoreTime()); diff != "" {
+		t.Errorf("WriteAll() differs (+got/-want):\n%s", diff)
+	}
+}
+
+type recorder struct {
+	got []*api.ValueList
+	err error
+}
+
+func (w *recorder) Write(_ context.Context, vl *api.ValueList) error {
+	w.got = append(w.got, vl)
+	return w.err
+}
+
+func TestRegistry_WriteAll_order(t *testing.T) {
+	r := NewRegistry()
+	for _, typ := range []string{"gauge", "derive", "counter", "absolute"} {
+		r.NewGauge(api.Identifier{Host: "example.com", Plugin: "TestRegistry", Type: typ})
+	}
+	r.Unregister("example.com/TestRegistry/derive")
+	// Re-publishing keeps the position.
+	r.NewGauge(api.Identifier{Host: "example.com", Plugin: "TestRegistry", Type: "gauge"})
+	r.NewGauge(api.Identifier{Host: "example.com", Plugin: "TestRegistry", Type: "derive"})
+
+	for i := 0; i < 10; i++ {
+		var w recorder
+		if err := r.WriteAll(context.Background(), &w, time.Second); err != nil {
+			t.Fatalf("WriteAll() = %v", err)
+		}
+
+		var got []string
+		for _, vl := range w.got {
+			got = append(got, vl.Type)
+		}
+		want := []string{"gauge", "counter", "absolute", "derive"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("WriteAll() order differs (+got/-want):\n%s", diff)
+		}
+	}
+}
+
+func TestRegistry_WriteAll_error(t *testing.T) {
+	r := NewRegistry()
+	r.NewDerive(api.Identifier{Host: "example.com", Plugin: "TestRegistry", Type: "derive"})
+	r.NewGauge(api.Identifier{Host: "example.com", Plugin: "TestRegistry", Type: "gauge"})
+
+	wantErr := errors.New("write failed")
+	w := recorder{err: wantErr}
+	err := r.WriteAll(context.Background(), &w, time.Second)
+	if !errors.Is(err, wantErr) {
+		t.Errorf("WriteAll() = %v, want %v", err, wantErr)
+	}
+	if got, want := len(w.got), 2; got != want {
+		t.Errorf("WriteAll() wrote %d value lists, want %d", got, want)
 	}
 }
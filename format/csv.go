@@ -0,0 +1,91 @@
+package format // import "collectd.org/format"
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"collectd.org/api"
+)
+
+// csvHeader holds the column names written by CSV.
+var csvHeader = []string{"time", "host", "plugin", "plugin_instance", "type", "type_instance", "dsname", "value"}
+
+// CSV implements the Writer interface and writes value lists as comma
+// separated values. A header row is written before the first value list. Each
+// value is written as a separate row; the time is formatted according to
+// RFC 3339 with sub-second precision.
+type CSV struct {
+	mu          sync.Mutex
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSV returns a new CSV object writing to the provided io.Writer.
+func NewCSV(w io.Writer) *CSV {
+	return &CSV{
+		w: csv.NewWriter(w),
+	}
+}
+
+// Write formats the ValueList as CSV and writes it to the associated
+// io.Writer. It is safe to call Write concurrently.
+func (c *CSV) Write(_ context.Context, vl *api.ValueList) error {
+	rows := make([][]string, 0, len(vl.Values))
+	for i, v := range vl.Values {
+		value, err := csvValue(v)
+		if err != nil {
+			return err
+		}
+
+		rows = append(rows, []string{
+			csvTime(vl.Time),
+			vl.Host,
+			vl.Plugin,
+			vl.PluginInstance,
+			vl.Type,
+			vl.TypeInstance,
+			vl.DSName(i),
+			value,
+		})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.wroteHeader {
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+
+	if err := c.w.WriteAll(rows); err != nil {
+		return err
+	}
+	return c.w.Error()
+}
+
+func csvValue(v api.Value) (string, error) {
+	switch v := v.(type) {
+	case api.Counter:
+		return fmt.Sprintf("%d", v), nil
+	case api.Absolute:
+		return fmt.Sprintf("%d", v), nil
+	case api.Gauge:
+		return fmt.Sprintf("%.15g", v), nil
+	case api.Derive:
+		return fmt.Sprintf("%d", v), nil
+	}
+	return "", fmt.Errorf("unexpected type %T", v)
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
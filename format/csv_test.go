@@ -0,0 +1,89 @@
+package format_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/format"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCSV(t *testing.T) {
+	vls := []*api.ValueList{
+		{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "TestCSV",
+				Type:   "gauge",
+			},
+			Time:     time.Unix(1588087972, 987654321).UTC(),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(42.5)},
+		},
+		{
+			Identifier: api.Identifier{
+				Host:           "example.com",
+				Plugin:         "interface",
+				PluginInstance: "eth0",
+				Type:           "if_octets",
+			},
+			Time:     time.Unix(1588087972, 0).UTC(),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Derive(1), api.Derive(2)},
+			DSNames:  []string{"rx", "tx"},
+		},
+		{
+			Identifier: api.Identifier{
+				Host:         "example.com",
+				Plugin:       "TestCSV",
+				Type:         "counter",
+				TypeInstance: `a,b "c"`,
+			},
+			Values: []api.Value{api.Counter(31337)},
+		},
+	}
+
+	want := strings.Join([]string{
+		"time,host,plugin,plugin_instance,type,type_instance,dsname,value",
+		"2020-04-28T15:32:52.987654321Z,example.com,TestCSV,,gauge,,value,42.5",
+		"2020-04-28T15:32:52Z,example.com,interface,eth0,if_octets,,rx,1",
+		"2020-04-28T15:32:52Z,example.com,interface,eth0,if_octets,,tx,2",
+		`,example.com,TestCSV,,counter,"a,b ""c""",value,31337`,
+		"",
+	}, "\n")
+
+	var b strings.Builder
+	c := format.NewCSV(&b)
+	for _, vl := range vls {
+		if err := c.Write(context.Background(), vl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("CSV output differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestCSV_error(t *testing.T) {
+	var b strings.Builder
+	c := format.NewCSV(&b)
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestCSV",
+			Type:   "gauge",
+		},
+		Values: []api.Value{nil},
+	}
+	if err := c.Write(context.Background(), vl); err == nil {
+		t.Error("Write() succeeded, want error")
+	}
+	if b.Len() != 0 {
+		t.Errorf("Write() wrote %q, want nothing", b.String())
+	}
+}
@@ -0,0 +1,182 @@
+package format // import "collectd.org/format"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/meta"
+)
+
+// DefaultElasticBatchSize is the number of value lists buffered by
+// ElasticBulk if no other batch size is specified.
+const DefaultElasticBatchSize = 100
+
+// ElasticBulk implements the Writer interface for Elasticsearch's bulk API.
+//
+// Each value list is written as two lines of JSON: an "index" action and the
+// document itself. The document has an "@timestamp" field with the value
+// list's time, the identifier fields, the interval in seconds, a "values"
+// object mapping data source names to values, and the value list's meta data,
+// if any. Per-value meta data is written as a "value_meta" object, mapping
+// data source names to meta data. NaN values are written as null.
+//
+// Output is buffered and written to the io.Writer in batches, so that each
+// Write call on the io.Writer can be sent as one bulk request. Call Flush to
+// write buffered value lists.
+type ElasticBulk struct {
+	w         io.Writer
+	index     string
+	batchSize int
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+	n   int
+}
+
+// ElasticBulkOption is an option for the NewElasticBulk function.
+type ElasticBulkOption func(e *ElasticBulk)
+
+// WithElasticBatchSize sets the number of value lists to buffer before
+// writing them to the io.Writer. If n is less than one, every value list is
+// written immediately.
+func WithElasticBatchSize(n int) ElasticBulkOption {
+	return func(e *ElasticBulk) {
+		e.batchSize = n
+	}
+}
+
+// NewElasticBulk returns a new ElasticBulk object writing documents for the
+// given index to the provided io.Writer.
+func NewElasticBulk(w io.Writer, index string, opts ...ElasticBulkOption) *ElasticBulk {
+	e := &ElasticBulk{
+		w:         w,
+		index:     index,
+		batchSize: DefaultElasticBatchSize,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+type elasticAction struct {
+	Index struct {
+		Index string `json:"_index"`
+	} `json:"index"`
+}
+
+type elasticDocument struct {
+	Timestamp      string                 `json:"@timestamp,omitempty"`
+	Host           string                 `json:"host"`
+	Plugin         string                 `json:"plugin"`
+	PluginInstance string                 `json:"plugin_instance,omitempty"`
+	Type           string                 `json:"type"`
+	TypeInstance   string                 `json:"type_instance,omitempty"`
+	Interval       float64                `json:"interval"`
+	Values         map[string]interface{} `json:"values"`
+	Meta           meta.Data              `json:"meta,omitempty"`
+	ValueMeta      map[string]meta.Data   `json:"value_meta,omitempty"`
+}
+
+// Write formats the ValueList as a bulk index request and adds it to the
+// buffer. When the buffer holds the configured number of value lists, it is
+// written to the associated io.Writer.
+func (e *ElasticBulk) Write(_ context.Context, vl *api.ValueList) error {
+	doc := elasticDocument{
+		Host:           vl.Host,
+		Plugin:         vl.Plugin,
+		PluginInstance: vl.PluginInstance,
+		Type:           vl.Type,
+		TypeInstance:   vl.TypeInstance,
+		Interval:       vl.Interval.Seconds(),
+		Values:         make(map[string]interface{}, len(vl.Values)),
+		Meta:           vl.Meta,
+	}
+	if !vl.Time.IsZero() {
+		doc.Timestamp = vl.Time.UTC().Format(time.RFC3339Nano)
+	}
+	for i, md := range vl.ValueMeta {
+		if len(md) == 0 || i >= len(vl.Values) {
+			continue
+		}
+		if doc.ValueMeta == nil {
+			doc.ValueMeta = make(map[string]meta.Data)
+		}
+		doc.ValueMeta[vl.DSName(i)] = md
+	}
+
+	for i, v := range vl.Values {
+		switch v := v.(type) {
+		case api.Gauge:
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				doc.Values[vl.DSName(i)] = nil
+			} else {
+				doc.Values[vl.DSName(i)] = float64(v)
+			}
+		case api.Derive:
+			doc.Values[vl.DSName(i)] = int64(v)
+		case api.Counter:
+			doc.Values[vl.DSName(i)] = uint64(v)
+		case api.Absolute:
+			doc.Values[vl.DSName(i)] = uint64(v)
+		default:
+			return fmt.Errorf("unexpected type %T", v)
+		}
+	}
+
+	var action elasticAction
+	action.Index.Index = e.index
+
+	actionJSON, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.buf.Write(actionJSON)
+	e.buf.WriteByte('\n')
+	e.buf.Write(docJSON)
+	e.buf.WriteByte('\n')
+	e.n++
+
+	if e.n < e.batchSize {
+		return nil
+	}
+	return e.flush()
+}
+
+// Flush writes all buffered value lists to the associated io.Writer.
+func (e *ElasticBulk) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.flush()
+}
+
+func (e *ElasticBulk) flush() error {
+	if e.n == 0 {
+		return nil
+	}
+
+	// The buffer is reset even if writing fails, so that a broken
+	// io.Writer doesn't cause unbounded growth.
+	_, err := e.w.Write(e.buf.Bytes())
+	e.buf.Reset()
+	e.n = 0
+	return err
+}
@@ -0,0 +1,187 @@
+package format_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/format"
+	"collectd.org/meta"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestElasticBulk(t *testing.T) {
+	baseVL := api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestElasticBulk",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588087972, 987654321),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42.5)},
+	}
+
+	const action = `{"index":{"_index":"collectd"}}` + "\n"
+
+	cases := []struct {
+		title   string
+		modify  func(*api.ValueList)
+		want    string
+		wantErr bool
+	}{
+		{
+			title: "gauge",
+			want:  action + `{"@timestamp":"2020-04-28T15:32:52.987654321Z","host":"example.com","plugin":"TestElasticBulk","type":"gauge","interval":10,"values":{"value":42.5}}` + "\n",
+		},
+		{
+			title: "multiple values",
+			modify: func(vl *api.ValueList) {
+				vl.PluginInstance = "eth0"
+				vl.Type = "if_octets"
+				vl.TypeInstance = "total"
+				vl.Values = []api.Value{api.Derive(-1), api.Counter(2)}
+				vl.DSNames = []string{"rx", "tx"}
+			},
+			want: action + `{"@timestamp":"2020-04-28T15:32:52.987654321Z","host":"example.com","plugin":"TestElasticBulk","plugin_instance":"eth0","type":"if_octets","type_instance":"total","interval":10,"values":{"rx":-1,"tx":2}}` + "\n",
+		},
+		{
+			title: "NaN",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{api.Gauge(math.NaN())}
+			},
+			want: action + `{"@timestamp":"2020-04-28T15:32:52.987654321Z","host":"example.com","plugin":"TestElasticBulk","type":"gauge","interval":10,"values":{"value":null}}` + "\n",
+		},
+		{
+			title: "meta data",
+			modify: func(vl *api.ValueList) {
+				vl.Meta = meta.Data{
+					"bool":   meta.Bool(true),
+					"string": meta.String("foo"),
+				}
+			},
+			want: action + `{"@timestamp":"2020-04-28T15:32:52.987654321Z","host":"example.com","plugin":"TestElasticBulk","type":"gauge","interval":10,"values":{"value":42.5},"meta":{"bool":true,"string":"foo"}}` + "\n",
+		},
+		{
+			title: "value meta data",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "if_octets"
+				vl.Values = []api.Value{api.Derive(1), api.Derive(2)}
+				vl.DSNames = []string{"rx", "tx"}
+				vl.ValueMeta = []meta.Data{
+					{"unit": meta.String("bytes")},
+					nil,
+				}
+			},
+			want: action + `{"@timestamp":"2020-04-28T15:32:52.987654321Z","host":"example.com","plugin":"TestElasticBulk","type":"if_octets","interval":10,"values":{"rx":1,"tx":2},"value_meta":{"rx":{"unit":"bytes"}}}` + "\n",
+		},
+		{
+			title: "without time",
+			modify: func(vl *api.ValueList) {
+				vl.Time = time.Time{}
+			},
+			want: action + `{"host":"example.com","plugin":"TestElasticBulk","type":"gauge","interval":10,"values":{"value":42.5}}` + "\n",
+		},
+		{
+			title: "invalid type",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{nil}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ctx := context.Background()
+
+			vl := baseVL
+			if tc.modify != nil {
+				tc.modify(&vl)
+			}
+
+			var b strings.Builder
+			e := format.NewElasticBulk(&b, "collectd")
+			err := e.Write(ctx, &vl)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("ElasticBulk.Write(%#v) = %v, want error %v", &vl, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if b.Len() != 0 {
+				t.Errorf("ElasticBulk.Write() wrote %q before Flush()", b.String())
+			}
+			if err := e.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("ElasticBulk.Write(%#v) differs (+got/-want):\n%s", &vl, diff)
+			}
+		})
+	}
+}
+
+// countingWriter counts the calls to Write.
+type countingWriter struct {
+	strings.Builder
+	calls int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.calls++
+	return w.Builder.Write(b)
+}
+
+func TestElasticBulk_Batch(t *testing.T) {
+	ctx := context.Background()
+
+	var w countingWriter
+	e := format.NewElasticBulk(&w, "collectd", format.WithElasticBatchSize(2))
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestElasticBulk",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588087972, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := e.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := w.calls, 2; got != want {
+		t.Errorf("got %d Write calls, want %d", got, want)
+	}
+	if got, want := strings.Count(w.String(), "\n"), 8; got != want {
+		t.Errorf("got %d lines, want %d", got, want)
+	}
+
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.calls, 3; got != want {
+		t.Errorf("got %d Write calls after Flush(), want %d", got, want)
+	}
+	if got, want := strings.Count(w.String(), "\n"), 10; got != want {
+		t.Errorf("got %d lines after Flush(), want %d", got, want)
+	}
+
+	// Flushing an empty buffer must not call Write.
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.calls, 3; got != want {
+		t.Errorf("got %d Write calls after second Flush(), want %d", got, want)
+	}
+}
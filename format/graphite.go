@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"strings"
+	"sync"
 	"time"
 
 	"collectd.org/api"
@@ -17,8 +19,52 @@ type Graphite struct {
 	Prefix, Suffix    string
 	EscapeChar        string
 	SeparateInstances bool
-	AlwaysAppendDS    bool // TODO(octo): Implement support.
-	replacer          *strings.Replacer
+	AlwaysAppendDS    bool
+	// StoreRates converts Derive, Counter and Absolute values to a rate
+	// (per second) before writing them. Since a rate can only be
+	// calculated from two consecutive values, the first value of each
+	// metric is not written. Rates are calculated by api.RateTracker.
+	StoreRates bool
+
+	replacer *strings.Replacer
+
+	mu    sync.Mutex
+	rates *api.RateTracker
+}
+
+// GraphiteOptions holds options for NewGraphite.
+type GraphiteOptions struct {
+	// Prefix and Suffix are added before and after the host name,
+	// respectively.
+	Prefix, Suffix string
+	// EscapeChar replaces dots and other special characters in the
+	// components of the metric name. Defaults to "_".
+	EscapeChar string
+	// SeparateInstances separates plugin and type instances from the
+	// plugin and type names with a dot instead of a hyphen.
+	SeparateInstances bool
+	// AlwaysAppendDS appends the data source name to the metric name even
+	// if the value list only has a single value.
+	AlwaysAppendDS bool
+	// StoreRates converts Derive, Counter and Absolute values to rates.
+	StoreRates bool
+}
+
+// NewGraphite returns a new Graphite object writing to w.
+func NewGraphite(w io.Writer, opts GraphiteOptions) *Graphite {
+	if opts.EscapeChar == "" {
+		opts.EscapeChar = "_"
+	}
+
+	return &Graphite{
+		W:                 w,
+		Prefix:            opts.Prefix,
+		Suffix:            opts.Suffix,
+		EscapeChar:        opts.EscapeChar,
+		SeparateInstances: opts.SeparateInstances,
+		AlwaysAppendDS:    opts.AlwaysAppendDS,
+		StoreRates:        opts.StoreRates,
+	}
 }
 
 func (g *Graphite) escape(in string) string {
@@ -68,16 +114,40 @@ func (g *Graphite) formatValue(v api.Value) (string, error) {
 	switch v := v.(type) {
 	case api.Gauge:
 		return fmt.Sprintf("%.15g", v), nil
-	case api.Derive, api.Counter:
+	case api.Derive, api.Counter, api.Absolute:
 		return fmt.Sprintf("%v", v), nil
 	default:
 		return "", fmt.Errorf("unexpected type %T", v)
 	}
 }
 
-// Write formats the ValueList in the PUTVAL format and writes it to the
+// rateTracker returns the RateTracker used to calculate rates, creating it
+// if necessary.
+func (g *Graphite) rateTracker() *api.RateTracker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.rates == nil {
+		g.rates = api.NewRateTracker()
+	}
+	return g.rates
+}
+
+// Write formats the ValueList in the Graphite format and writes it to the
 // assiciated io.Writer.
 func (g *Graphite) Write(_ context.Context, vl *api.ValueList) error {
+	t := vl.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	var rates []api.Gauge
+	if g.StoreRates {
+		withTime := *vl
+		withTime.Time = t
+		rates, _ = g.rateTracker().Rate(&withTime)
+	}
+
 	for i, v := range vl.Values {
 		dsName := ""
 		if g.AlwaysAppendDS || len(vl.Values) != 1 {
@@ -86,16 +156,23 @@ func (g *Graphite) Write(_ context.Context, vl *api.ValueList) error {
 
 		name := g.formatName(vl.Identifier, dsName)
 
+		if g.StoreRates {
+			switch v.(type) {
+			case api.Derive, api.Counter, api.Absolute:
+				// Rates are NaN if they can't be calculated, e.g.
+				// after a Derive reset.
+				if rates == nil || math.IsNaN(float64(rates[i])) {
+					continue
+				}
+				v = rates[i]
+			}
+		}
+
 		val, err := g.formatValue(v)
 		if err != nil {
 			return err
 		}
 
-		t := vl.Time
-		if t.IsZero() {
-			t = time.Now()
-		}
-
 		fmt.Fprintf(g.W, "%s %s %d\r\n", name, val, t.Unix())
 	}
 
@@ -3,6 +3,7 @@ package format
 import (
 	"bytes"
 	"context"
+	"math"
 	"testing"
 	"time"
 
@@ -98,3 +99,119 @@ func TestWrite(t *testing.T) {
 		}
 	}
 }
+
+func TestNewGraphite(t *testing.T) {
+	ctx := context.Background()
+
+	cases := []struct {
+		title string
+		id    api.Identifier
+		opts  GraphiteOptions
+		want  string
+	}{
+		{
+			title: "without instances",
+			id: api.Identifier{
+				Host:   "example.com",
+				Plugin: "golang",
+				Type:   "gauge",
+			},
+			want: "example_com.golang.gauge 42 1426975989\r\n",
+		},
+		{
+			title: "with instances",
+			id: api.Identifier{
+				Host:           "example.com",
+				Plugin:         "golang",
+				PluginInstance: "example.org",
+				Type:           "gauge",
+				TypeInstance:   "answer",
+			},
+			want: "example_com.golang-example_org.gauge-answer 42 1426975989\r\n",
+		},
+		{
+			title: "separate instances",
+			id: api.Identifier{
+				Host:           "example.com",
+				Plugin:         "golang",
+				PluginInstance: "example",
+				Type:           "gauge",
+				TypeInstance:   "answer",
+			},
+			opts: GraphiteOptions{
+				Prefix:            "collectd.",
+				EscapeChar:        "-",
+				SeparateInstances: true,
+			},
+			want: "collectd.example-com.golang.example.gauge.answer 42 1426975989\r\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			g := NewGraphite(buf, tc.opts)
+
+			vl := &api.ValueList{
+				Identifier: tc.id,
+				Time:       time.Unix(1426975989, 0),
+				Interval:   10 * time.Second,
+				Values:     []api.Value{api.Gauge(42)},
+			}
+			if err := g.Write(ctx, vl); err != nil {
+				t.Fatalf("Graphite.Write() = %v", err)
+			}
+
+			if got := buf.String(); got != tc.want {
+				t.Errorf("Graphite.Write() wrote %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGraphite_StoreRates(t *testing.T) {
+	ctx := context.Background()
+
+	buf := &bytes.Buffer{}
+	g := NewGraphite(buf, GraphiteOptions{
+		StoreRates: true,
+	})
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "golang",
+			Type:   "if_octets",
+		},
+		Time:     time.Unix(1426975989, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Derive(100), api.Counter(math.MaxUint32 - 9), api.Gauge(1)},
+		DSNames:  []string{"rx", "tx", "gauge"},
+	}
+	if err := g.Write(ctx, vl); err != nil {
+		t.Fatalf("Graphite.Write() = %v", err)
+	}
+
+	vl.Time = vl.Time.Add(vl.Interval)
+	vl.Values = []api.Value{api.Derive(200), api.Counter(10), api.Gauge(2)}
+	if err := g.Write(ctx, vl); err != nil {
+		t.Fatalf("Graphite.Write() = %v", err)
+	}
+
+	// A Derive reset results in a negative rate, which is discarded.
+	vl.Time = vl.Time.Add(vl.Interval)
+	vl.Values = []api.Value{api.Derive(0), api.Counter(30), api.Gauge(3)}
+	if err := g.Write(ctx, vl); err != nil {
+		t.Fatalf("Graphite.Write() = %v", err)
+	}
+
+	want := "example_com.golang.if_octets.gauge 1 1426975989\r\n" +
+		"example_com.golang.if_octets.rx 10 1426975999\r\n" +
+		"example_com.golang.if_octets.tx 2 1426975999\r\n" +
+		"example_com.golang.if_octets.gauge 2 1426975999\r\n" +
+		"example_com.golang.if_octets.tx 2 1426976009\r\n" +
+		"example_com.golang.if_octets.gauge 3 1426976009\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Graphite.Write() wrote %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,104 @@
+package format // import "collectd.org/format"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"collectd.org/api"
+)
+
+// Influx implements the Writer interface for the InfluxDB line protocol.
+//
+// The measurement is the value list's type. Host, plugin, plugin instance and
+// type instance are added as tags, omitting empty instances. Each value is
+// added as a field named after its data source. Gauges are written as floats,
+// Derives as signed integers and Counters and Absolutes as unsigned integers.
+// NaN values are omitted, because the line protocol has no representation for
+// them.
+type Influx struct {
+	w io.Writer
+}
+
+// NewInflux returns a new Influx object writing to the provided io.Writer.
+func NewInflux(w io.Writer) *Influx {
+	return &Influx{
+		w: w,
+	}
+}
+
+var (
+	influxMeasurementReplacer = strings.NewReplacer(
+		",", `\,`,
+		" ", `\ `,
+	)
+	influxTagReplacer = strings.NewReplacer(
+		",", `\,`,
+		"=", `\=`,
+		" ", `\ `,
+	)
+)
+
+// Write formats the ValueList in the InfluxDB line protocol and writes it to
+// the associated io.Writer.
+func (i *Influx) Write(_ context.Context, vl *api.ValueList) error {
+	var fields []string
+	for idx, v := range vl.Values {
+		s, ok, err := influxValue(v)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		fields = append(fields, influxTagReplacer.Replace(vl.DSName(idx))+"="+s)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString(influxMeasurementReplacer.Replace(vl.Type))
+	for _, tag := range []struct{ key, value string }{
+		{"host", vl.Host},
+		{"plugin", vl.Plugin},
+		{"plugin_instance", vl.PluginInstance},
+		{"type_instance", vl.TypeInstance},
+	} {
+		if tag.value == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", tag.key, influxTagReplacer.Replace(tag.value))
+	}
+
+	b.WriteString(" ")
+	b.WriteString(strings.Join(fields, ","))
+
+	if !vl.Time.IsZero() {
+		fmt.Fprintf(&b, " %d", vl.Time.UnixNano())
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(i.w, b.String())
+	return err
+}
+
+func influxValue(v api.Value) (string, bool, error) {
+	switch v := v.(type) {
+	case api.Gauge:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return "", false, nil
+		}
+		return fmt.Sprintf("%.15g", v), true, nil
+	case api.Derive:
+		return fmt.Sprintf("%di", v), true, nil
+	case api.Counter:
+		return fmt.Sprintf("%du", v), true, nil
+	case api.Absolute:
+		return fmt.Sprintf("%du", v), true, nil
+	default:
+		return "", false, fmt.Errorf("unexpected type %T", v)
+	}
+}
@@ -0,0 +1,117 @@
+package format_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/format"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInflux(t *testing.T) {
+	baseVL := api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestInflux",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588087972, 987654321),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42.5)},
+	}
+
+	cases := []struct {
+		title   string
+		modify  func(*api.ValueList)
+		want    string
+		wantErr bool
+	}{
+		{
+			title: "gauge",
+			want:  "gauge,host=example.com,plugin=TestInflux value=42.5 1588087972987654321\n",
+		},
+		{
+			title: "multiple values",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "if_octets"
+				vl.Values = []api.Value{api.Derive(1), api.Derive(2)}
+				vl.DSNames = []string{"rx", "tx"}
+			},
+			want: "if_octets,host=example.com,plugin=TestInflux rx=1i,tx=2i 1588087972987654321\n",
+		},
+		{
+			title: "counter",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "counter"
+				vl.Values = []api.Value{api.Counter(31337)}
+			},
+			want: "counter,host=example.com,plugin=TestInflux value=31337u 1588087972987654321\n",
+		},
+		{
+			title: "instances",
+			modify: func(vl *api.ValueList) {
+				vl.PluginInstance = "foo bar"
+				vl.TypeInstance = "a,b=c"
+			},
+			want: `gauge,host=example.com,plugin=TestInflux,plugin_instance=foo\ bar,type_instance=a\,b\=c value=42.5 1588087972987654321` + "\n",
+		},
+		{
+			title: "NaN",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "if_octets"
+				vl.Values = []api.Value{api.Gauge(math.NaN()), api.Gauge(2)}
+				vl.DSNames = []string{"rx", "tx"}
+			},
+			want: "if_octets,host=example.com,plugin=TestInflux tx=2 1588087972987654321\n",
+		},
+		{
+			title: "only NaN",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{api.Gauge(math.NaN())}
+			},
+			want: "",
+		},
+		{
+			title: "without time",
+			modify: func(vl *api.ValueList) {
+				vl.Time = time.Time{}
+			},
+			want: "gauge,host=example.com,plugin=TestInflux value=42.5\n",
+		},
+		{
+			title: "invalid type",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{nil}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ctx := context.Background()
+
+			vl := baseVL
+			if tc.modify != nil {
+				tc.modify(&vl)
+			}
+
+			var b strings.Builder
+			err := format.NewInflux(&b).Write(ctx, &vl)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Influx.Write(%#v) = %v, want error %v", &vl, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("Influx.Write(%#v) differs (+got/-want):\n%s", &vl, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package format // import "collectd.org/format"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"collectd.org/api"
+)
+
+// JSON implements the Writer interface for JSON formatted output. The format
+// is the same as used by collectd's "write_http" plugin, i.e. each value list
+// is written as a JSON array with a single element, followed by a newline.
+type JSON struct {
+	w io.Writer
+}
+
+// NewJSON returns a new JSON object writing to the provided io.Writer.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{
+		w: w,
+	}
+}
+
+// Write formats the ValueList in the JSON format and writes it to the
+// associated io.Writer.
+func (j *JSON) Write(_ context.Context, vl *api.ValueList) error {
+	data, err := json.Marshal([]*api.ValueList{vl})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(j.w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,103 @@
+package format_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/format"
+	"collectd.org/meta"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSON(t *testing.T) {
+	baseVL := api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestJSON",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588087972, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+		DSNames:  []string{"value"},
+	}
+
+	cases := []struct {
+		title   string
+		modify  func(*api.ValueList)
+		want    string
+		wantErr bool
+	}{
+		{
+			title: "gauge",
+			want:  `[{"values":[42],"dstypes":["gauge"],"dsnames":["value"],"time":1588087972.000,"interval":10.000,"host":"example.com","plugin":"TestJSON","type":"gauge"}]` + "\n",
+		},
+		{
+			title: "NaN",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{api.Gauge(math.NaN())}
+			},
+			want: `[{"values":[null],"dstypes":["gauge"],"dsnames":["value"],"time":1588087972.000,"interval":10.000,"host":"example.com","plugin":"TestJSON","type":"gauge"}]` + "\n",
+		},
+		{
+			title: "multiple values",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "if_octets"
+				vl.Values = []api.Value{api.Derive(1), api.Derive(2)}
+				vl.DSNames = []string{"rx", "tx"}
+			},
+			want: `[{"values":[1,2],"dstypes":["derive","derive"],"dsnames":["rx","tx"],"time":1588087972.000,"interval":10.000,"host":"example.com","plugin":"TestJSON","type":"if_octets"}]` + "\n",
+		},
+		{
+			title: "empty meta data",
+			modify: func(vl *api.ValueList) {
+				vl.Meta = meta.Data{}
+			},
+			want: `[{"values":[42],"dstypes":["gauge"],"dsnames":["value"],"time":1588087972.000,"interval":10.000,"host":"example.com","plugin":"TestJSON","type":"gauge"}]` + "\n",
+		},
+		{
+			title: "meta data",
+			modify: func(vl *api.ValueList) {
+				vl.Meta = meta.Data{
+					"key": meta.String("value"),
+				}
+			},
+			want: `[{"values":[42],"dstypes":["gauge"],"dsnames":["value"],"time":1588087972.000,"interval":10.000,"host":"example.com","plugin":"TestJSON","type":"gauge","meta":{"key":"value"}}]` + "\n",
+		},
+		{
+			title: "invalid type",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{nil}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ctx := context.Background()
+
+			vl := baseVL
+			if tc.modify != nil {
+				tc.modify(&vl)
+			}
+
+			var b strings.Builder
+			err := format.NewJSON(&b).Write(ctx, &vl)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("JSON.Write(%#v) = %v, want error %v", &vl, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("JSON.Write(%#v) differs (+got/-want):\n%s", &vl, diff)
+			}
+		})
+	}
+}
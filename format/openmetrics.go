@@ -0,0 +1,223 @@
+package format // import "collectd.org/format"
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"collectd.org/api"
+)
+
+// OpenMetricsContentType is the HTTP content type of the OpenMetrics text
+// exposition format.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// OpenMetrics implements the Writer interface and accumulates value lists in
+// the OpenMetrics (Prometheus) text exposition format.
+//
+// The metric name is "<plugin>_<type>", followed by "_<data source>" if the
+// value list has more than one value. Characters not allowed in metric names
+// are replaced by underscores. Derive and Counter values are exposed as
+// counters, i.e. their samples have a "_total" suffix; all other values are
+// exposed as gauges. Host, plugin instance and type instance are added as
+// labels, omitting empty instances.
+//
+// Write only stores the most recent value of each series. Call Flush to write
+// all series to the associated io.Writer, or WriteHTTP to serve them to a
+// scraper. Series are never removed.
+type OpenMetrics struct {
+	w io.Writer
+
+	mu       sync.Mutex
+	families map[string]*openMetricsFamily
+}
+
+type openMetricsFamily struct {
+	typ     string
+	samples map[string]openMetricsSample
+}
+
+type openMetricsSample struct {
+	value string
+	time  time.Time
+}
+
+// NewOpenMetrics returns a new OpenMetrics object writing to the provided
+// io.Writer.
+func NewOpenMetrics(w io.Writer) *OpenMetrics {
+	return &OpenMetrics{
+		w:        w,
+		families: make(map[string]*openMetricsFamily),
+	}
+}
+
+var openMetricsLabelReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+)
+
+// Write stores the values of vl, replacing previously stored values of the
+// same series.
+func (o *OpenMetrics) Write(_ context.Context, vl *api.ValueList) error {
+	var labels []string
+	for _, l := range []struct{ name, value string }{
+		{"host", vl.Host},
+		{"plugin_instance", vl.PluginInstance},
+		{"type_instance", vl.TypeInstance},
+	} {
+		if l.value == "" {
+			continue
+		}
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, l.name, openMetricsLabelReplacer.Replace(l.value)))
+	}
+	var labelSet string
+	if len(labels) != 0 {
+		labelSet = "{" + strings.Join(labels, ",") + "}"
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i, v := range vl.Values {
+		typ, value, err := openMetricsValue(v)
+		if err != nil {
+			return err
+		}
+
+		name := vl.Plugin + "_" + vl.Type
+		if len(vl.Values) > 1 {
+			name += "_" + vl.DSName(i)
+		}
+		name = sanitizeOpenMetricsName(name)
+
+		f, ok := o.families[name]
+		if !ok || f.typ != typ {
+			f = &openMetricsFamily{
+				typ:     typ,
+				samples: make(map[string]openMetricsSample),
+			}
+			o.families[name] = f
+		}
+		f.samples[labelSet] = openMetricsSample{
+			value: value,
+			time:  vl.Time,
+		}
+	}
+
+	return nil
+}
+
+func openMetricsValue(v api.Value) (typ, value string, err error) {
+	switch v := v.(type) {
+	case api.Gauge:
+		return "gauge", openMetricsFloat(float64(v)), nil
+	case api.Absolute:
+		return "gauge", strconv.FormatUint(uint64(v), 10), nil
+	case api.Derive:
+		return "counter", strconv.FormatInt(int64(v), 10), nil
+	case api.Counter:
+		return "counter", strconv.FormatUint(uint64(v), 10), nil
+	}
+	return "", "", fmt.Errorf("unexpected type %T", v)
+}
+
+func openMetricsFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// sanitizeOpenMetricsName replaces all characters not allowed in a metric name
+// with underscores. Names must not start with a digit, so an underscore is
+// prepended if necessary.
+func sanitizeOpenMetricsName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// Flush writes all stored series to the associated io.Writer.
+func (o *OpenMetrics) Flush() error {
+	var buf bytes.Buffer
+	o.writeTo(&buf)
+
+	_, err := o.w.Write(buf.Bytes())
+	return err
+}
+
+// WriteHTTP writes all stored series to w, setting the appropriate content
+// type. It can be used to implement an http.Handler for scrapers.
+func (o *OpenMetrics) WriteHTTP(w http.ResponseWriter) {
+	var buf bytes.Buffer
+	o.writeTo(&buf)
+
+	w.Header().Set("Content-Type", OpenMetricsContentType)
+	w.Write(buf.Bytes())
+}
+
+// writeTo writes the exposition of all stored series, sorted by metric name
+// and labels, to buf.
+func (o *OpenMetrics) writeTo(buf *bytes.Buffer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	names := make([]string, 0, len(o.families))
+	for name := range o.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := o.families[name]
+		fmt.Fprintf(buf, "# TYPE %s %s\n", name, f.typ)
+
+		sampleName := name
+		if f.typ == "counter" {
+			sampleName += "_total"
+		}
+
+		labelSets := make([]string, 0, len(f.samples))
+		for ls := range f.samples {
+			labelSets = append(labelSets, ls)
+		}
+		sort.Strings(labelSets)
+
+		for _, ls := range labelSets {
+			s := f.samples[ls]
+			fmt.Fprintf(buf, "%s%s %s", sampleName, ls, s.value)
+			if !s.time.IsZero() {
+				buf.WriteString(" " + formatTime(s.time))
+			}
+			buf.WriteByte('\n')
+		}
+	}
+
+	buf.WriteString("# EOF\n")
+}
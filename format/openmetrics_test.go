@@ -0,0 +1,186 @@
+package format_test
+
+import (
+	"context"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/format"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOpenMetrics(t *testing.T) {
+	baseVL := api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestOpenMetrics",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588087972, 987000000),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42.5)},
+	}
+
+	cases := []struct {
+		title   string
+		modify  func(*api.ValueList)
+		want    string
+		wantErr bool
+	}{
+		{
+			title: "gauge",
+			want: `# TYPE TestOpenMetrics_gauge gauge
+TestOpenMetrics_gauge{host="example.com"} 42.5 1588087972.987
+# EOF
+`,
+		},
+		{
+			title: "derive",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "derive"
+				vl.Values = []api.Value{api.Derive(-1)}
+			},
+			want: `# TYPE TestOpenMetrics_derive counter
+TestOpenMetrics_derive_total{host="example.com"} -1 1588087972.987
+# EOF
+`,
+		},
+		{
+			title: "multiple values",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "if_octets"
+				vl.Values = []api.Value{api.Counter(1), api.Counter(2)}
+				vl.DSNames = []string{"rx", "tx"}
+			},
+			want: `# TYPE TestOpenMetrics_if_octets_rx counter
+TestOpenMetrics_if_octets_rx_total{host="example.com"} 1 1588087972.987
+# TYPE TestOpenMetrics_if_octets_tx counter
+TestOpenMetrics_if_octets_tx_total{host="example.com"} 2 1588087972.987
+# EOF
+`,
+		},
+		{
+			title: "name sanitization",
+			modify: func(vl *api.ValueList) {
+				vl.Plugin = "2go-plugin"
+				vl.Type = "bytes.used/sec"
+			},
+			want: `# TYPE _2go_plugin_bytes_used_sec gauge
+_2go_plugin_bytes_used_sec{host="example.com"} 42.5 1588087972.987
+# EOF
+`,
+		},
+		{
+			title: "label escaping",
+			modify: func(vl *api.ValueList) {
+				vl.PluginInstance = `C:\temp`
+				vl.TypeInstance = "say \"hi\"\n"
+			},
+			want: `# TYPE TestOpenMetrics_gauge gauge
+TestOpenMetrics_gauge{host="example.com",plugin_instance="C:\\temp",type_instance="say \"hi\"\n"} 42.5 1588087972.987
+# EOF
+`,
+		},
+		{
+			title: "NaN",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{api.Gauge(math.NaN())}
+			},
+			want: `# TYPE TestOpenMetrics_gauge gauge
+TestOpenMetrics_gauge{host="example.com"} NaN 1588087972.987
+# EOF
+`,
+		},
+		{
+			title: "without time and labels",
+			modify: func(vl *api.ValueList) {
+				vl.Host = ""
+				vl.Time = time.Time{}
+			},
+			want: `# TYPE TestOpenMetrics_gauge gauge
+TestOpenMetrics_gauge 42.5
+# EOF
+`,
+		},
+		{
+			title: "invalid type",
+			modify: func(vl *api.ValueList) {
+				vl.Values = []api.Value{nil}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ctx := context.Background()
+
+			vl := baseVL
+			if tc.modify != nil {
+				tc.modify(&vl)
+			}
+
+			var b strings.Builder
+			om := format.NewOpenMetrics(&b)
+			err := om.Write(ctx, &vl)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("OpenMetrics.Write(%#v) = %v, want error %v", &vl, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if err := om.Flush(); err != nil {
+				t.Fatalf("OpenMetrics.Flush() = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("OpenMetrics.Write(%#v) differs (+got/-want):\n%s", &vl, diff)
+			}
+		})
+	}
+}
+
+func TestOpenMetrics_WriteHTTP(t *testing.T) {
+	ctx := context.Background()
+	om := format.NewOpenMetrics(nil)
+
+	for _, vl := range []*api.ValueList{
+		{
+			Identifier: api.Identifier{Host: "b.example.com", Plugin: "load", Type: "load"},
+			Values:     []api.Value{api.Gauge(1)},
+		},
+		{
+			Identifier: api.Identifier{Host: "a.example.com", Plugin: "load", Type: "load"},
+			Values:     []api.Value{api.Gauge(2)},
+		},
+		// replaces the first value list
+		{
+			Identifier: api.Identifier{Host: "b.example.com", Plugin: "load", Type: "load"},
+			Values:     []api.Value{api.Gauge(3)},
+		},
+	} {
+		if err := om.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	om.WriteHTTP(rec)
+
+	if got, want := rec.Header().Get("Content-Type"), format.OpenMetricsContentType; got != want {
+		t.Errorf("Content-Type = %q, want %q", got, want)
+	}
+
+	want := `# TYPE load_load gauge
+load_load{host="a.example.com"} 2
+load_load{host="b.example.com"} 3
+# EOF
+`
+	if diff := cmp.Diff(want, rec.Body.String()); diff != "" {
+		t.Errorf("OpenMetrics.WriteHTTP() differs (+got/-want):\n%s", diff)
+	}
+}
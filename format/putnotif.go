@@ -0,0 +1,67 @@
+package format // import "collectd.org/format"
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/internal/quote"
+)
+
+// Putnotif implements PUTNOTIF formatted output of notifications, as accepted
+// by collectd's exec and unixsock plugins.
+type Putnotif struct {
+	w io.Writer
+}
+
+// NewPutnotif returns a new Putnotif object writing to the provided io.Writer.
+func NewPutnotif(w io.Writer) *Putnotif {
+	return &Putnotif{
+		w: w,
+	}
+}
+
+// Notify formats the Notification in the PUTNOTIF format and writes it to the
+// associated io.Writer. If n.Time is zero, the current time is used. Meta data
+// is not supported by the PUTNOTIF command and is ignored.
+func (p *Putnotif) Notify(_ context.Context, n *api.Notification) error {
+	switch n.Severity {
+	case api.Failure, api.Warning, api.Okay:
+	default:
+		return fmt.Errorf("invalid severity %v", n.Severity)
+	}
+
+	t := n.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	fields := []string{
+		"PUTNOTIF",
+		"severity=" + n.Severity.String(),
+		"time=" + formatTime(t),
+	}
+
+	for _, f := range []struct {
+		name, value string
+	}{
+		{"host", n.Host},
+		{"plugin", n.Plugin},
+		{"plugin_instance", n.PluginInstance},
+		{"type", n.Type},
+		{"type_instance", n.TypeInstance},
+	} {
+		if f.value != "" {
+			fields = append(fields, f.name+"="+quote.String(f.value))
+		}
+	}
+
+	// The message is mandatory and conventionally the last option.
+	fields = append(fields, "message="+quote.String(n.Message))
+
+	_, err := fmt.Fprintln(p.w, strings.Join(fields, " "))
+	return err
+}
@@ -0,0 +1,112 @@
+package format_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/format"
+	"collectd.org/meta"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPutnotif(t *testing.T) {
+	baseN := api.Notification{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestPutnotif",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1587671455, 0),
+		Severity: api.Warning,
+		Message:  "Value out of range",
+	}
+
+	cases := []struct {
+		title   string
+		modify  func(*api.Notification)
+		want    string
+		wantErr bool
+	}{
+		{
+			title: "warning",
+			want:  `PUTNOTIF severity=warning time=1587671455.000 host="example.com" plugin="TestPutnotif" type="gauge" message="Value out of range"` + "\n",
+		},
+		{
+			title: "failure",
+			modify: func(n *api.Notification) {
+				n.Severity = api.Failure
+			},
+			want: `PUTNOTIF severity=failure time=1587671455.000 host="example.com" plugin="TestPutnotif" type="gauge" message="Value out of range"` + "\n",
+		},
+		{
+			title: "instances",
+			modify: func(n *api.Notification) {
+				n.Severity = api.Okay
+				n.PluginInstance = "0"
+				n.TypeInstance = "idle"
+			},
+			want: `PUTNOTIF severity=okay time=1587671455.000 host="example.com" plugin="TestPutnotif" plugin_instance="0" type="gauge" type_instance="idle" message="Value out of range"` + "\n",
+		},
+		{
+			title: "message quoting",
+			modify: func(n *api.Notification) {
+				n.Message = `Value "x" is \ 42`
+			},
+			want: `PUTNOTIF severity=warning time=1587671455.000 host="example.com" plugin="TestPutnotif" type="gauge" message="Value \"x\" is \\ 42"` + "\n",
+		},
+		{
+			title: "no Go escapes",
+			modify: func(n *api.Notification) {
+				n.TypeInstance = "\u00fcber"
+				n.Message = "temperature\tabove 30\u00b0C"
+			},
+			want: "PUTNOTIF severity=warning time=1587671455.000 host=\"example.com\" plugin=\"TestPutnotif\" type=\"gauge\" type_instance=\"\u00fcber\" message=\"temperature\tabove 30\u00b0C\"\n",
+		},
+		{
+			title: "empty identifier",
+			modify: func(n *api.Notification) {
+				n.Identifier = api.Identifier{}
+			},
+			want: `PUTNOTIF severity=warning time=1587671455.000 message="Value out of range"` + "\n",
+		},
+		{
+			title: "meta data is ignored",
+			modify: func(n *api.Notification) {
+				n.Meta = meta.Data{"key": meta.String("value")}
+			},
+			want: `PUTNOTIF severity=warning time=1587671455.000 host="example.com" plugin="TestPutnotif" type="gauge" message="Value out of range"` + "\n",
+		},
+		{
+			title: "invalid severity",
+			modify: func(n *api.Notification) {
+				n.Severity = 0
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			n := baseN
+			if tc.modify != nil {
+				tc.modify(&n)
+			}
+
+			var b strings.Builder
+			err := format.NewPutnotif(&b).Notify(context.Background(), &n)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Putnotif.Notify(%#v) = %v, want error %v", &n, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("Putnotif.Notify(%#v) differs (+got/-want):\n%s", &n, diff)
+			}
+		})
+	}
+}
@@ -12,34 +12,118 @@ import (
 	"time"
 
 	"collectd.org/api"
+	"collectd.org/internal/quote"
 	"collectd.org/meta"
 )
 
 // Putval implements the Writer interface for PUTVAL formatted output.
+//
+// PUTVAL has no notion of per-value meta data. Entries of ValueMeta are
+// written as list-level meta data, with the data source name and a dot
+// prepended to the key, e.g. "meta:rx.unit=...".
+//
+// The identifier is written the way collectd's parser expects it, i.e. without
+// the escaping applied by api.Identifier.String. Since collectd has no way to
+// escape slashes, fields containing them can't be represented.
 type Putval struct {
-	w io.Writer
+	w         io.Writer
+	dsNames   bool
+	typedMeta bool
+	hostname  string
+}
+
+// PutvalOption is an option for the NewPutval function.
+type PutvalOption func(p *Putval)
+
+// WithDSNames appends the names of the data sources to each line, in the
+// same order as the values. The names are added as a trailing comment, e.g.
+// "# dsnames=rx:tx". This is intended to make the output easier to read for
+// humans; collectd may not accept the resulting lines.
+func WithDSNames() PutvalOption {
+	return func(p *Putval) {
+		p.dsNames = true
+	}
+}
+
+// WithTypedMeta writes meta data entries of all types, e.g. "meta:key=42" or
+// "meta:key=true". Only string values are quoted. This requires collectd 5.12
+// or later; by default, only string meta data is written, which is what older
+// versions of collectd support.
+func WithTypedMeta() PutvalOption {
+	return func(p *Putval) {
+		p.typedMeta = true
+	}
+}
+
+// WithHostname sets the host of value lists with an empty Host field to
+// hostname. This is what collectd's exec plugin expects, see
+// "collectd.org/exec".Hostname.
+func WithHostname(hostname string) PutvalOption {
+	return func(p *Putval) {
+		p.hostname = hostname
+	}
 }
 
 // NewPutval returns a new Putval object writing to the provided io.Writer.
-func NewPutval(w io.Writer) *Putval {
-	return &Putval{
+func NewPutval(w io.Writer, opts ...PutvalOption) *Putval {
+	p := &Putval{
 		w: w,
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
 // Write formats the ValueList in the PUTVAL format and writes it to the
 // assiciated io.Writer.
 func (p *Putval) Write(_ context.Context, vl *api.ValueList) error {
+	if vl.Host == "" && p.hostname != "" {
+		// Don't modify the argument.
+		vl = vl.Clone()
+		vl.Host = p.hostname
+	}
+
 	s, err := formatValues(vl)
 	if err != nil {
 		return err
 	}
 
-	_, err = fmt.Fprintf(p.w, "PUTVAL %q interval=%.3f %s%s\n",
-		vl.Identifier.String(), vl.Interval.Seconds(), formatMeta(vl.Meta), s)
+	var comment string
+	if p.dsNames {
+		comment = " # dsnames=" + strings.Join(vl.ResolvedDSNames(), ":")
+	}
+
+	m := formatMeta("", vl.Meta, p.typedMeta)
+	for i, md := range vl.ValueMeta {
+		if i >= len(vl.Values) {
+			break
+		}
+		m += formatMeta(vl.DSName(i)+".", md, p.typedMeta)
+	}
+
+	_, err = fmt.Fprintf(p.w, "PUTVAL %s interval=%.3f %s%s%s\n",
+		quote.String(putvalIdentifier(vl.Identifier)), vl.Interval.Seconds(), m, s, comment)
 	return err
 }
 
+// putvalIdentifier formats id as
+// "host/plugin[-plugin_instance]/type[-type_instance]" without escaping any
+// characters.
+func putvalIdentifier(id api.Identifier) string {
+	s := id.Host + "/" + id.Plugin
+	if id.PluginInstance != "" {
+		s += "-" + id.PluginInstance
+	}
+	s += "/" + id.Type
+	if id.TypeInstance != "" {
+		s += "-" + id.TypeInstance
+	}
+	return s
+}
+
 func formatValues(vl *api.ValueList) (string, error) {
 	fields := make([]string, 1+len(vl.Values))
 
@@ -49,6 +133,8 @@ func formatValues(vl *api.ValueList) (string, error) {
 		switch v := v.(type) {
 		case api.Counter:
 			fields[i+1] = fmt.Sprintf("%d", v)
+		case api.Absolute:
+			fields[i+1] = fmt.Sprintf("%d", v)
 		case api.Gauge:
 			fields[i+1] = fmt.Sprintf("%.15g", v)
 		case api.Derive:
@@ -71,21 +157,29 @@ func formatTime(t time.Time) string {
 
 var stringWarning sync.Once
 
-func formatMeta(m meta.Data) string {
+// formatMeta formats m as "meta:<prefix>key=value " pairs, sorted by key.
+// Unless typed is true, non-string entries are skipped.
+func formatMeta(prefix string, m meta.Data, typed bool) string {
 	if len(m) == 0 {
 		return ""
 	}
 
 	var values []string
-	for k, v := range m {
-		// collectd only supports string meta data values as of 5.11.
-		if !v.IsString() {
+	for _, k := range m.Keys() {
+		v := m[k]
+		if v.IsString() {
+			values = append(values, fmt.Sprintf("meta:%s%s=%s ", prefix, k, quote.String(v.String())))
+			continue
+		}
+
+		// collectd before 5.12 only supports string meta data values.
+		if !typed {
 			stringWarning.Do(func() {
-				log.Printf("Non-string metadata not supported yet")
+				log.Printf("Non-string metadata not supported without WithTypedMeta()")
 			})
 			continue
 		}
-		values = append(values, fmt.Sprintf("meta:%s=%q ", k, v.String()))
+		values = append(values, fmt.Sprintf("meta:%s%s=%s ", prefix, k, v.String()))
 	}
 
 	return strings.Join(values, "")
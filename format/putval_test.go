@@ -27,6 +27,7 @@ func TestPutval(t *testing.T) {
 	cases := []struct {
 		title   string
 		modify  func(*api.ValueList)
+		opts    []format.PutvalOption
 		want    string
 		wantErr bool
 	}{
@@ -50,6 +51,14 @@ func TestPutval(t *testing.T) {
 			},
 			want: `PUTVAL "example.com/TestPutval/counter" interval=10.000 N:31337` + "\n",
 		},
+		{
+			title: "absolute",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "absolute"
+				vl.Values = []api.Value{api.Absolute(65535)}
+			},
+			want: `PUTVAL "example.com/TestPutval/absolute" interval=10.000 N:65535` + "\n",
+		},
 		{
 			title: "multiple values",
 			modify: func(vl *api.ValueList) {
@@ -59,6 +68,24 @@ func TestPutval(t *testing.T) {
 			},
 			want: `PUTVAL "example.com/TestPutval/if_octets" interval=10.000 N:1:2` + "\n",
 		},
+		{
+			title: "DS names",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "if_octets"
+				vl.Values = []api.Value{api.Derive(1), api.Derive(2)}
+				vl.DSNames = []string{"rx", "tx"}
+			},
+			opts: []format.PutvalOption{format.WithDSNames()},
+			want: `PUTVAL "example.com/TestPutval/if_octets" interval=10.000 N:1:2 # dsnames=rx:tx` + "\n",
+		},
+		{
+			title: "default DS names",
+			opts:  []format.PutvalOption{format.WithDSNames()},
+			modify: func(vl *api.ValueList) {
+				vl.DSNames = nil
+			},
+			want: `PUTVAL "example.com/TestPutval/derive" interval=10.000 N:42 # dsnames=value` + "\n",
+		},
 		{
 			title: "invalid type",
 			modify: func(vl *api.ValueList) {
@@ -90,6 +117,81 @@ func TestPutval(t *testing.T) {
 			},
 			want: `PUTVAL "example.com/TestPutval/derive" interval=10.000 meta:key="value" N:42` + "\n",
 		},
+		{
+			title: "meta_data quoting",
+			modify: func(vl *api.ValueList) {
+				vl.Meta = meta.Data{
+					"path": meta.String(`C:\Temp`),
+					"tab":  meta.String("a\tb"),
+				}
+			},
+			// collectd has no escape sequences besides a backslash
+			// taking the next character literally.
+			want: "PUTVAL \"example.com/TestPutval/derive\" interval=10.000 meta:path=\"C:\\\\Temp\" meta:tab=\"a\tb\" N:42\n",
+		},
+		{
+			title: "typed meta_data",
+			modify: func(vl *api.ValueList) {
+				vl.Meta = meta.Data{
+					"bool":   meta.Bool(true),
+					"float":  meta.Float64(1.5),
+					"int":    meta.Int64(-42),
+					"string": meta.String(`say "hi"`),
+					"uint":   meta.UInt64(42),
+				}
+			},
+			opts: []format.PutvalOption{format.WithTypedMeta()},
+			want: `PUTVAL "example.com/TestPutval/derive" interval=10.000 meta:bool=true meta:float=1.5 meta:int=-42 meta:string="say \"hi\"" meta:uint=42 N:42` + "\n",
+		},
+		{
+			title: "legacy meta_data",
+			modify: func(vl *api.ValueList) {
+				vl.Meta = meta.Data{
+					"bool":   meta.Bool(true),
+					"float":  meta.Float64(1.5),
+					"int":    meta.Int64(-42),
+					"string": meta.String("value"),
+					"uint":   meta.UInt64(42),
+				}
+			},
+			want: `PUTVAL "example.com/TestPutval/derive" interval=10.000 meta:string="value" N:42` + "\n",
+		},
+		{
+			title: "value meta_data",
+			modify: func(vl *api.ValueList) {
+				vl.Type = "if_octets"
+				vl.Values = []api.Value{api.Derive(1), api.Derive(2)}
+				vl.DSNames = []string{"rx", "tx"}
+				vl.Meta = meta.Data{"key": meta.String("value")}
+				vl.ValueMeta = []meta.Data{
+					{"unit": meta.String("bytes"), "scale": meta.Float64(8)},
+					{"unit": meta.String("packets")},
+				}
+			},
+			opts: []format.PutvalOption{format.WithTypedMeta()},
+			want: `PUTVAL "example.com/TestPutval/if_octets" interval=10.000 meta:key="value" meta:rx.scale=8 meta:rx.unit="bytes" meta:tx.unit="packets" N:1:2` + "\n",
+		},
+		{
+			title: "identifier is not escaped",
+			modify: func(vl *api.ValueList) {
+				vl.Host = `DOMAIN\host`
+				vl.PluginInstance = "foo-bar"
+			},
+			want: `PUTVAL "DOMAIN\\host/TestPutval-foo-bar/derive" interval=10.000 N:42` + "\n",
+		},
+		{
+			title: "hostname fills empty host",
+			modify: func(vl *api.ValueList) {
+				vl.Host = ""
+			},
+			opts: []format.PutvalOption{format.WithHostname("default.example.com")},
+			want: `PUTVAL "default.example.com/TestPutval/derive" interval=10.000 N:42` + "\n",
+		},
+		{
+			title: "hostname preserves host",
+			opts:  []format.PutvalOption{format.WithHostname("default.example.com")},
+			want:  `PUTVAL "example.com/TestPutval/derive" interval=10.000 N:42` + "\n",
+		},
 	}
 
 	for _, tc := range cases {
@@ -102,7 +204,7 @@ func TestPutval(t *testing.T) {
 			}
 
 			var b strings.Builder
-			err := format.NewPutval(&b).Write(ctx, &vl)
+			err := format.NewPutval(&b, tc.opts...).Write(ctx, &vl)
 			if gotErr := err != nil; gotErr != tc.wantErr {
 				t.Errorf("Putval.Write(%#v) = %v, want error %v", &vl, err, tc.wantErr)
 			}
@@ -113,6 +215,14 @@ func TestPutval(t *testing.T) {
 			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
 				t.Errorf("Putval.Write(%#v) differs (+got/-want):\n%s", &vl, diff)
 			}
+
+			want := baseVL
+			if tc.modify != nil {
+				tc.modify(&want)
+			}
+			if vl.Host != want.Host {
+				t.Errorf("Putval.Write() modified its argument: Host = %q, want %q", vl.Host, want.Host)
+			}
 		})
 	}
 }
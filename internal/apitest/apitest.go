@@ -0,0 +1,26 @@
+// Package apitest provides helpers for testing code using the api package.
+package apitest // import "collectd.org/internal/apitest"
+
+import (
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// IgnoreTime returns a cmp.Option that ignores the Time field of value lists.
+//
+// api.ValueList implements an Equal method, which makes cmp skip options
+// targeting its fields, e.g. cmpopts.IgnoreFields. This option transforms
+// value lists into copies with a zero Time before they are compared instead.
+func IgnoreTime() cmp.Option {
+	return cmpopts.AcyclicTransformer("IgnoreTime", func(vl *api.ValueList) *api.ValueList {
+		if vl == nil {
+			return nil
+		}
+		c := vl.Clone()
+		c.Time = time.Time{}
+		return c
+	})
+}
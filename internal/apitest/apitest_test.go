@@ -0,0 +1,40 @@
+package apitest_test
+
+import (
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"collectd.org/internal/apitest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIgnoreTime(t *testing.T) {
+	a := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestIgnoreTime",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1587671455, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+	b := a.Clone()
+	b.Time = time.Now()
+
+	if cmp.Equal(a, b) {
+		t.Fatal("cmp.Equal() = true, want false without IgnoreTime")
+	}
+	if diff := cmp.Diff(a, b, apitest.IgnoreTime()); diff != "" {
+		t.Errorf("value lists differ (+got/-want):\n%s", diff)
+	}
+	if diff := cmp.Diff([]*api.ValueList{a, nil}, []*api.ValueList{b, nil}, apitest.IgnoreTime()); diff != "" {
+		t.Errorf("slices differ (+got/-want):\n%s", diff)
+	}
+
+	b.Values = []api.Value{api.Gauge(23)}
+	if cmp.Equal(a, b, apitest.IgnoreTime()) {
+		t.Error("cmp.Equal() = true, want false for different values")
+	}
+}
@@ -0,0 +1,19 @@
+// Package quote implements quoting of strings for collectd's text based
+// protocols, such as the PUTVAL and PUTNOTIF commands, and its config syntax.
+package quote // import "collectd.org/internal/quote"
+
+import "strings"
+
+// replacer escapes backslashes and double quotes. collectd takes the character
+// following a backslash literally and has no other escape sequences, so Go's
+// quoting rules, e.g. strconv.Quote, don't apply.
+var replacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+)
+
+// String returns s enclosed in double quotes, with backslashes and double
+// quotes escaped the way collectd expects.
+func String(s string) string {
+	return `"` + replacer.Replace(s) + `"`
+}
@@ -0,0 +1,23 @@
+package quote
+
+import "testing"
+
+func TestString(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"", `""`},
+		{"foo", `"foo"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`DOMAIN\host`, `"DOMAIN\\host"`},
+		{"multi\nline\ttab", "\"multi\nline\ttab\""},
+		{"ünïcödé", `"ünïcödé"`},
+	}
+
+	for _, tc := range cases {
+		if got := String(tc.in); got != tc.want {
+			t.Errorf("String(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package meta
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Type codes of meta data entries, as used by collectd's meta_data_t
+// (MD_TYPE_*) and the binary network protocol.
+const (
+	TypeString  = 1
+	TypeInt64   = 2
+	TypeUInt64  = 3
+	TypeFloat64 = 4
+	TypeBool    = 5
+)
+
+// EncodeEntry returns the type code and the binary representation of e. The
+// encoding is the one used by the binary network protocol: strings are null
+// terminated, integers are in big endian and floating point numbers are in
+// little endian byte order, and booleans are encoded as a single byte.
+func EncodeEntry(e Entry) (typ byte, data []byte, err error) {
+	switch e.typ {
+	case metaStringType:
+		return TypeString, append([]byte(e.s), 0), nil
+	case metaInt64Type:
+		return TypeInt64, binary.BigEndian.AppendUint64(nil, uint64(e.i)), nil
+	case metaUInt64Type:
+		return TypeUInt64, binary.BigEndian.AppendUint64(nil, e.u), nil
+	case metaFloat64Type:
+		return TypeFloat64, binary.LittleEndian.AppendUint64(nil, math.Float64bits(e.f)), nil
+	case metaBoolType:
+		if e.b {
+			return TypeBool, []byte{1}, nil
+		}
+		return TypeBool, []byte{0}, nil
+	default:
+		return 0, nil, errors.New("meta: unknown entry type")
+	}
+}
+
+// DecodeEntry is the inverse of EncodeEntry. It returns an error if typ is
+// unknown or data has an invalid length for typ.
+func DecodeEntry(typ byte, data []byte) (Entry, error) {
+	switch {
+	case typ == TypeString && len(data) > 0 && data[len(data)-1] == 0:
+		return String(string(data[:len(data)-1])), nil
+	case typ == TypeInt64 && len(data) == 8:
+		return Int64(int64(binary.BigEndian.Uint64(data))), nil
+	case typ == TypeUInt64 && len(data) == 8:
+		return UInt64(binary.BigEndian.Uint64(data)), nil
+	case typ == TypeFloat64 && len(data) == 8:
+		return Float64(math.Float64frombits(binary.LittleEndian.Uint64(data))), nil
+	case typ == TypeBool && len(data) == 1:
+		return Bool(data[0] != 0), nil
+	}
+
+	return Entry{}, fmt.Errorf("meta: invalid entry of type %d with %d bytes of data", typ, len(data))
+}
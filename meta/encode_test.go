@@ -0,0 +1,91 @@
+package meta_test
+
+import (
+	"testing"
+
+	"collectd.org/meta"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEncodeEntry(t *testing.T) {
+	cases := []struct {
+		entry    meta.Entry
+		wantType byte
+		wantData []byte
+	}{
+		{
+			entry:    meta.String("foo"),
+			wantType: meta.TypeString,
+			wantData: []byte{'f', 'o', 'o', 0},
+		},
+		{
+			entry:    meta.Int64(-2),
+			wantType: meta.TypeInt64,
+			wantData: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe},
+		},
+		{
+			entry:    meta.UInt64(258),
+			wantType: meta.TypeUInt64,
+			wantData: []byte{0, 0, 0, 0, 0, 0, 1, 2},
+		},
+		{
+			entry:    meta.Float64(1.0),
+			wantType: meta.TypeFloat64,
+			wantData: []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f},
+		},
+		{
+			entry:    meta.Bool(true),
+			wantType: meta.TypeBool,
+			wantData: []byte{1},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.entry.String(), func(t *testing.T) {
+			gotType, gotData, err := meta.EncodeEntry(tc.entry)
+			if err != nil {
+				t.Fatalf("EncodeEntry(%v) = %v", tc.entry, err)
+			}
+			if gotType != tc.wantType {
+				t.Errorf("EncodeEntry(%v) type = %d, want %d", tc.entry, gotType, tc.wantType)
+			}
+			if diff := cmp.Diff(tc.wantData, gotData); diff != "" {
+				t.Errorf("EncodeEntry(%v) data differs (+got/-want):\n%s", tc.entry, diff)
+			}
+
+			got, err := meta.DecodeEntry(gotType, gotData)
+			if err != nil {
+				t.Fatalf("DecodeEntry(%d, %v) = %v", gotType, gotData, err)
+			}
+			if got.Interface() != tc.entry.Interface() {
+				t.Errorf("DecodeEntry(%d, %v) = %#v, want %#v", gotType, gotData, got.Interface(), tc.entry.Interface())
+			}
+		})
+	}
+
+	if _, _, err := meta.EncodeEntry(meta.Entry{}); err == nil {
+		t.Error("EncodeEntry(meta.Entry{}) succeeded, want error")
+	}
+}
+
+func TestDecodeEntry_invalid(t *testing.T) {
+	cases := []struct {
+		typ  byte
+		data []byte
+	}{
+		{meta.TypeString, nil},
+		{meta.TypeString, []byte{'f', 'o', 'o'}},
+		{meta.TypeInt64, []byte{0, 0, 0, 0}},
+		{meta.TypeUInt64, make([]byte, 9)},
+		{meta.TypeFloat64, nil},
+		{meta.TypeBool, []byte{0, 1}},
+		{0, []byte{0}},
+		{42, make([]byte, 8)},
+	}
+
+	for _, tc := range cases {
+		if got, err := meta.DecodeEntry(tc.typ, tc.data); err == nil {
+			t.Errorf("DecodeEntry(%d, %v) = %v, want error", tc.typ, tc.data, got)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 )
 
 type entryType int
@@ -39,6 +40,80 @@ func (d Data) Clone() Data {
 	return cpy
 }
 
+// Merge returns a new Data containing the entries of both d and other. If a key
+// is present in both, the entry from other is used. Neither d nor other is
+// modified. If both are nil, Merge returns nil.
+func (d Data) Merge(other Data) Data {
+	if d == nil && other == nil {
+		return nil
+	}
+
+	merged := make(Data, len(d)+len(other))
+	for k, v := range d {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Keys returns the keys of d in sorted order.
+func (d Data) Keys() []string {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// BoolOr returns the bool value stored under key. If key is not present or
+// holds a value of a different type, def is returned.
+func (d Data) BoolOr(key string, def bool) bool {
+	if v, ok := d[key].Bool(); ok {
+		return v
+	}
+	return def
+}
+
+// Float64Or returns the float64 value stored under key. If key is not present
+// or holds a value of a different type, def is returned.
+func (d Data) Float64Or(key string, def float64) float64 {
+	if v, ok := d[key].Float64(); ok {
+		return v
+	}
+	return def
+}
+
+// Int64Or returns the int64 value stored under key. If key is not present or
+// holds a value of a different type, def is returned.
+func (d Data) Int64Or(key string, def int64) int64 {
+	if v, ok := d[key].Int64(); ok {
+		return v
+	}
+	return def
+}
+
+// UInt64Or returns the uint64 value stored under key. If key is not present or
+// holds a value of a different type, def is returned.
+func (d Data) UInt64Or(key string, def uint64) uint64 {
+	if v, ok := d[key].UInt64(); ok {
+		return v
+	}
+	return def
+}
+
+// StringOr returns the string value stored under key. If key is not present or
+// holds a value of a different type, def is returned. Unlike Entry.String,
+// other types are not converted to strings.
+func (d Data) StringOr(key string, def string) string {
+	if e, ok := d[key]; ok && e.IsString() {
+		return e.String()
+	}
+	return def
+}
+
 // Entry is an entry in the metadata set. The typed value may be bool, float64,
 // int64, uint64, or string.
 type Entry struct {
@@ -153,6 +153,16 @@ func TestMarshalJSON(t *testing.T) {
 		{meta.Data{"foo": meta.UInt64(42)}, `{"foo":42}`},
 		{meta.Data{"foo": meta.String(`Hello "World"!`)}, `{"foo":"Hello \"World\"!"}`},
 		{meta.Data{"foo": meta.Entry{}}, `{"foo":null}`},
+		// Keys are sorted, making the encoding stable.
+		{
+			meta.Data{
+				"c": meta.Int64(3),
+				"a": meta.String("1"),
+				"d": meta.Bool(false),
+				"b": meta.UInt64(2),
+			},
+			`{"a":"1","b":2,"c":3,"d":false}`,
+		},
 	}
 
 	for _, tc := range cases {
@@ -317,6 +327,21 @@ func TestEntry(t *testing.T) {
 	}
 }
 
+func TestData_Keys(t *testing.T) {
+	d := meta.Data{
+		"c": meta.Int64(3),
+		"a": meta.String("1"),
+		"b": meta.UInt64(2),
+	}
+
+	if diff := cmp.Diff([]string{"a", "b", "c"}, d.Keys()); diff != "" {
+		t.Errorf("Data.Keys() differs (+got/-want):\n%s", diff)
+	}
+	if got := (meta.Data{}).Keys(); len(got) != 0 {
+		t.Errorf("Data{}.Keys() = %v, want empty", got)
+	}
+}
+
 func TestData_Clone(t *testing.T) {
 	want := meta.Data{
 		"bool":   meta.Bool(false),
@@ -341,3 +366,97 @@ func TestData_Clone(t *testing.T) {
 		t.Errorf("Data(nil).Clone() = %v, want %v", got, nil)
 	}
 }
+
+func TestData_Merge(t *testing.T) {
+	cases := []struct {
+		title string
+		d     meta.Data
+		other meta.Data
+		want  meta.Data
+	}{
+		{
+			title: "override existing key",
+			d:     meta.Data{"host": meta.String("a"), "rack": meta.Int64(1)},
+			other: meta.Data{"host": meta.String("b")},
+			want:  meta.Data{"host": meta.String("b"), "rack": meta.Int64(1)},
+		},
+		{
+			title: "add new keys",
+			d:     meta.Data{"host": meta.String("a")},
+			other: meta.Data{"rack": meta.Int64(1), "dc": meta.String("fra")},
+			want:  meta.Data{"host": meta.String("a"), "rack": meta.Int64(1), "dc": meta.String("fra")},
+		},
+		{
+			title: "merge into nil",
+			other: meta.Data{"host": meta.String("b")},
+			want:  meta.Data{"host": meta.String("b")},
+		},
+		{
+			title: "merge nil",
+			d:     meta.Data{"host": meta.String("a")},
+			want:  meta.Data{"host": meta.String("a")},
+		},
+		{
+			title: "both nil",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			dBefore := tc.d.Clone()
+			otherBefore := tc.other.Clone()
+
+			got := tc.d.Merge(tc.other)
+
+			opt := cmp.AllowUnexported(meta.Entry{})
+			if diff := cmp.Diff(tc.want, got, opt); diff != "" {
+				t.Errorf("Data.Merge() differs (+got/-want):\n%s", diff)
+			}
+			if diff := cmp.Diff(dBefore, tc.d, opt); diff != "" {
+				t.Errorf("Data.Merge() modified the receiver (+got/-want):\n%s", diff)
+			}
+			if diff := cmp.Diff(otherBefore, tc.other, opt); diff != "" {
+				t.Errorf("Data.Merge() modified its argument (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestData_Or(t *testing.T) {
+	d := meta.Data{
+		"bool":   meta.Bool(true),
+		"float":  meta.Float64(1.5),
+		"int":    meta.Int64(-42),
+		"uint":   meta.UInt64(42),
+		"string": meta.String("foo"),
+	}
+
+	cases := []struct {
+		title string
+		got   interface{}
+		want  interface{}
+	}{
+		{"BoolOr present", d.BoolOr("bool", false), true},
+		{"BoolOr wrong type", d.BoolOr("int", false), false},
+		{"BoolOr missing", d.BoolOr("missing", true), true},
+		{"Float64Or present", d.Float64Or("float", 0), 1.5},
+		{"Float64Or wrong type", d.Float64Or("int", 2.5), 2.5},
+		{"Float64Or missing", d.Float64Or("missing", 2.5), 2.5},
+		{"Int64Or present", d.Int64Or("int", 0), int64(-42)},
+		{"Int64Or wrong type", d.Int64Or("uint", 7), int64(7)},
+		{"Int64Or missing", d.Int64Or("missing", 7), int64(7)},
+		{"UInt64Or present", d.UInt64Or("uint", 0), uint64(42)},
+		{"UInt64Or wrong type", d.UInt64Or("int", 7), uint64(7)},
+		{"UInt64Or missing", d.UInt64Or("missing", 7), uint64(7)},
+		{"StringOr present", d.StringOr("string", ""), "foo"},
+		{"StringOr wrong type", d.StringOr("int", "default"), "default"},
+		{"StringOr missing", d.StringOr("missing", "default"), "default"},
+		{"nil Data", meta.Data(nil).StringOr("string", "default"), "default"},
+	}
+
+	for _, tc := range cases {
+		if diff := cmp.Diff(tc.want, tc.got); diff != "" {
+			t.Errorf("%s: differs (+got/-want):\n%s", tc.title, diff)
+		}
+	}
+}
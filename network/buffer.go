@@ -12,6 +12,7 @@ import (
 
 	"collectd.org/api"
 	"collectd.org/cdtime"
+	"collectd.org/meta"
 )
 
 // ErrNotEnoughSpace is returned when adding a ValueList would exeed the buffer
@@ -31,6 +32,8 @@ type Buffer struct {
 	size               int
 	username, password string
 	securityLevel      SecurityLevel
+	legacyTime         bool
+	sendMeta           bool
 }
 
 // NewBuffer initializes a new Buffer. If "size" is 0, DefaultBufferSize will
@@ -61,6 +64,34 @@ func (b *Buffer) Encrypt(username, password string) {
 	b.securityLevel = Encrypt
 }
 
+// LegacyTime enables second resolution for times and intervals. They are
+// rounded to whole seconds and written using the "time" and "interval" parts
+// understood by collectd versions before 5.0, instead of the high resolution
+// variants. LegacyTime must be called before writing to the buffer.
+func (b *Buffer) LegacyTime() {
+	b.legacyTime = true
+}
+
+// SendMeta enables sending meta data. Meta data is not part of collectd's
+// binary protocol: it is sent using non-standard part types (0x000a and
+// 0x000b) that are only understood by this package. collectd ignores these
+// parts, but other implementations of the protocol may reject the packet.
+// SendMeta must be called before writing to the buffer.
+func (b *Buffer) SendMeta() {
+	b.sendMeta = true
+}
+
+// Used returns the number of bytes currently held in the buffer. Used and
+// Available add up to the buffer size, minus the space reserved for the
+// signature or encryption header when signing or encryption is enabled.
+// Clients can use this to flush a buffer before it is full.
+func (b *Buffer) Used() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buffer.Len()
+}
+
 // Available returns the number of bytes still available in the buffer.
 func (b *Buffer) Available() int {
 	var overhead int
@@ -143,10 +174,24 @@ func (b *Buffer) readEncrypted(out []byte) (int, error) {
 	return copy(out, ciphertext), nil
 }
 
+// scratchPool holds byte slices used by WriteTo, so that flushing a Buffer
+// doesn't allocate a new slice every time.
+var scratchPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, DefaultBufferSize)
+		return &buf
+	},
+}
+
 // WriteTo writes the buffer contents to "w". It implements the io.WriteTo
 // interface.
 func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
-	tmp := make([]byte, b.size)
+	scratch := scratchPool.Get().(*[]byte)
+	defer scratchPool.Put(scratch)
+	if len(*scratch) < b.size {
+		*scratch = make([]byte, b.size)
+	}
+	tmp := (*scratch)[:b.size]
 
 	n, err := b.Read(tmp)
 	if err != nil {
@@ -177,6 +222,26 @@ func (b *Buffer) Write(_ context.Context, vl *api.ValueList) error {
 	return nil
 }
 
+// WriteNotification adds a Notification to the buffer. Returns
+// ErrNotEnoughSpace if not enough space in the buffer is available to add this
+// notification. In that case, call Read() to empty the buffer and try again.
+func (b *Buffer) WriteNotification(_ context.Context, n *api.Notification) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// remember the original buffer size so we can truncate all potentially
+	// written data in case of an error.
+	l := b.buffer.Len()
+
+	if err := b.writeNotification(n); err != nil {
+		if l != 0 {
+			b.buffer.Truncate(l)
+		}
+		return err
+	}
+	return nil
+}
+
 func (b *Buffer) writeValueList(vl *api.ValueList) error {
 	if err := b.writeIdentifier(vl.Identifier); err != nil {
 		return err
@@ -190,6 +255,16 @@ func (b *Buffer) writeValueList(vl *api.ValueList) error {
 		return err
 	}
 
+	if b.sendMeta {
+		if err := b.writeMeta(vl.Meta); err != nil {
+			return err
+		}
+
+		if err := b.writeValueMeta(vl.ValueMeta); err != nil {
+			return err
+		}
+	}
+
 	if err := b.writeValues(vl.Values); err != nil {
 		return err
 	}
@@ -197,6 +272,28 @@ func (b *Buffer) writeValueList(vl *api.ValueList) error {
 	return nil
 }
 
+func (b *Buffer) writeNotification(n *api.Notification) error {
+	if err := b.writeIdentifier(n.Identifier); err != nil {
+		return err
+	}
+
+	if err := b.writeTime(n.Time); err != nil {
+		return err
+	}
+
+	if err := b.writeInt(typeSeverity, uint64(n.Severity)); err != nil {
+		return err
+	}
+
+	// The message part must come last: receivers dispatch the notification
+	// when they encounter it.
+	if err := b.writeString(typeMessage, n.Message); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (b *Buffer) writeIdentifier(id api.Identifier) error {
 	if id.Host != b.state.Host {
 		if err := b.writeString(typeHost, id.Host); err != nil {
@@ -233,20 +330,38 @@ func (b *Buffer) writeIdentifier(id api.Identifier) error {
 }
 
 func (b *Buffer) writeTime(t time.Time) error {
+	if b.legacyTime {
+		t = t.Round(time.Second)
+	}
+
 	if b.state.Time == t {
 		return nil
 	}
 	b.state.Time = t
 
+	if b.legacyTime {
+		return b.writeInt(typeTime, uint64(t.Unix()))
+	}
 	return b.writeInt(typeTimeHR, uint64(cdtime.New(t)))
 }
 
 func (b *Buffer) writeInterval(d time.Duration) error {
+	if b.legacyTime {
+		// Don't round short, non-zero intervals down to zero.
+		if d > 0 && d < time.Second {
+			d = time.Second
+		}
+		d = d.Round(time.Second)
+	}
+
 	if b.state.Interval == d {
 		return nil
 	}
 	b.state.Interval = d
 
+	if b.legacyTime {
+		return b.writeInt(typeInterval, uint64(d/time.Second))
+	}
 	return b.writeInt(typeIntervalHR, uint64(cdtime.NewDuration(d)))
 }
 
@@ -256,8 +371,7 @@ func (b *Buffer) writeValues(values []api.Value) error {
 		return ErrNotEnoughSpace
 	}
 
-	binary.Write(b.buffer, binary.BigEndian, uint16(typeValues))
-	binary.Write(b.buffer, binary.BigEndian, uint16(size))
+	b.writeHeader(typeValues, size)
 	binary.Write(b.buffer, binary.BigEndian, uint16(len(values)))
 
 	for _, v := range values {
@@ -268,6 +382,8 @@ func (b *Buffer) writeValues(values []api.Value) error {
 			binary.Write(b.buffer, binary.BigEndian, uint8(dsTypeDerive))
 		case api.Counter:
 			binary.Write(b.buffer, binary.BigEndian, uint8(dsTypeCounter))
+		case api.Absolute:
+			binary.Write(b.buffer, binary.BigEndian, uint8(dsTypeAbsolute))
 		default:
 			return ErrUnknownType
 		}
@@ -286,6 +402,8 @@ func (b *Buffer) writeValues(values []api.Value) error {
 			binary.Write(b.buffer, binary.BigEndian, int64(v))
 		case api.Counter:
 			binary.Write(b.buffer, binary.BigEndian, uint64(v))
+		case api.Absolute:
+			binary.Write(b.buffer, binary.BigEndian, uint64(v))
 		default:
 			return ErrUnknownType
 		}
@@ -294,20 +412,103 @@ func (b *Buffer) writeValues(values []api.Value) error {
 	return nil
 }
 
-func (b *Buffer) writeString(typ uint16, s string) error {
-	encoded := bytes.NewBufferString(s)
-	encoded.Write([]byte{0})
+// writeMeta writes one part per meta data entry. Unlike the identifier, meta
+// data is not part of the buffer's state, i.e. it is written for every value
+// list and applies to the following values part only.
+func (b *Buffer) writeMeta(md meta.Data) error {
+	for _, k := range md.Keys() {
+		if err := b.writeMetaEntry(k, md[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	// Because s is a Unicode string, encoded.Len() may be larger than
-	// len(s).
-	size := 4 + encoded.Len()
+func (b *Buffer) writeMetaEntry(key string, e meta.Entry) error {
+	payload := bytes.NewBufferString(key)
+	payload.WriteByte(0)
+
+	if err := appendMetaValue(payload, e); err != nil {
+		return err
+	}
+
+	return b.writePart(typeMeta, payload.Bytes())
+}
+
+// writeValueMeta writes one part per meta data entry of each value. The
+// payload is prefixed with the index of the value it applies to, otherwise it
+// is identical to the meta data part.
+func (b *Buffer) writeValueMeta(vm []meta.Data) error {
+	for i, md := range vm {
+		if i > math.MaxUint16 {
+			return ErrInvalid
+		}
+
+		for _, k := range md.Keys() {
+			var payload bytes.Buffer
+			binary.Write(&payload, binary.BigEndian, uint16(i))
+			payload.WriteString(k)
+			payload.WriteByte(0)
+
+			if err := appendMetaValue(&payload, md[k]); err != nil {
+				return err
+			}
+
+			if err := b.writePart(typeValueMeta, payload.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// appendMetaValue appends the type and value of e to payload.
+func appendMetaValue(payload *bytes.Buffer, e meta.Entry) error {
+	typ, data, err := meta.EncodeEntry(e)
+	if err != nil {
+		return ErrUnknownType
+	}
+
+	payload.WriteByte(typ)
+	payload.Write(data)
+	return nil
+}
+
+// writePart writes a part with the given type and payload.
+func (b *Buffer) writePart(typ uint16, payload []byte) error {
+	size := 4 + len(payload)
 	if size > b.Available() {
 		return ErrNotEnoughSpace
 	}
 
-	binary.Write(b.buffer, binary.BigEndian, typ)
-	binary.Write(b.buffer, binary.BigEndian, uint16(size))
-	b.buffer.Write(encoded.Bytes())
+	b.writeHeader(typ, size)
+	b.buffer.Write(payload)
+
+	return nil
+}
+
+// writeHeader writes the type and length of a part. It uses the
+// encoding/binary byte order functions instead of binary.Write(), which
+// allocates.
+func (b *Buffer) writeHeader(typ uint16, size int) {
+	var hdr [4]byte
+	binary.BigEndian.PutUint16(hdr[0:2], typ)
+	binary.BigEndian.PutUint16(hdr[2:4], uint16(size))
+	b.buffer.Write(hdr[:])
+}
+
+func (b *Buffer) writeString(typ uint16, s string) error {
+	// len(s) is the number of bytes, not runes, plus the null byte.
+	size := 4 + len(s) + 1
+	if size > b.Available() {
+		return ErrNotEnoughSpace
+	}
+
+	b.writeHeader(typ, size)
+	b.buffer.WriteString(s)
+	b.buffer.WriteByte(0)
 
 	return nil
 }
@@ -318,9 +519,11 @@ func (b *Buffer) writeInt(typ uint16, n uint64) error {
 		return ErrNotEnoughSpace
 	}
 
-	binary.Write(b.buffer, binary.BigEndian, typ)
-	binary.Write(b.buffer, binary.BigEndian, uint16(size))
-	binary.Write(b.buffer, binary.BigEndian, n)
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], n)
+
+	b.writeHeader(typ, size)
+	b.buffer.Write(v[:])
 
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"io"
 	"math"
 	"reflect"
 	"testing"
@@ -74,6 +75,153 @@ func TestWriteValueList(t *testing.T) {
 	}
 }
 
+func TestBuffer_LegacyTime(t *testing.T) {
+	ctx := context.Background()
+	b := NewBuffer(0)
+	b.LegacyTime()
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "golang",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1426076671, 623000000),
+		Interval: 2500 * time.Millisecond,
+		Values:   []api.Value{api.Derive(1)},
+	}
+	if err := b.Write(ctx, vl); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rounds to the same second as the previous time, i.e. the time is
+	// not written again.
+	vl2 := vl.Clone()
+	vl2.Time = time.Unix(1426076672, 100000000)
+	vl2.Interval = 100 * time.Millisecond
+	if err := b.Write(ctx, vl2); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0, 0, 0, 16, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', 0,
+		0, 2, 0, 11, 'g', 'o', 'l', 'a', 'n', 'g', 0,
+		0, 4, 0, 10, 'g', 'a', 'u', 'g', 'e', 0,
+		// 1426076672 = 0x55003400
+		0, 1, 0, 12, 0, 0, 0, 0, 0x55, 0x00, 0x34, 0x00,
+		0, 7, 0, 12, 0, 0, 0, 0, 0, 0, 0, 3,
+		0, 6, 0, 15, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 1,
+		// vl2
+		0, 7, 0, 12, 0, 0, 0, 0, 0, 0, 0, 1,
+		0, 6, 0, 15, 0, 1, 2, 0, 0, 0, 0, 0, 0, 0, 1,
+	}
+	got := b.buffer.Bytes()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuffer_Used(t *testing.T) {
+	ctx := context.Background()
+
+	for _, sl := range []SecurityLevel{None, Sign, Encrypt} {
+		t.Run(sl.String(), func(t *testing.T) {
+			b := NewBuffer(0)
+			switch sl {
+			case Sign:
+				b.Sign("user", "secret")
+			case Encrypt:
+				b.Encrypt("user", "secret")
+			}
+			overhead := DefaultBufferSize - b.Available()
+
+			if got := b.Used(); got != 0 {
+				t.Errorf("Used() = %d, want 0", got)
+			}
+
+			vl := &api.ValueList{
+				Identifier: api.Identifier{
+					Host:   "example.com",
+					Plugin: "golang",
+					Type:   "gauge",
+				},
+				Time:     time.Unix(1426076671, 0),
+				Interval: 10 * time.Second,
+				Values:   []api.Value{api.Gauge(42)},
+			}
+			if err := b.Write(ctx, vl); err != nil {
+				t.Fatal(err)
+			}
+
+			used := b.Used()
+			if used == 0 {
+				t.Error("Used() = 0 after Write()")
+			}
+			if got, want := used+b.Available()+overhead, DefaultBufferSize; got != want {
+				t.Errorf("Used() + Available() + overhead = %d, want %d", got, want)
+			}
+
+			if _, err := b.Bytes(); err != nil {
+				t.Fatal(err)
+			}
+			if got := b.Used(); got != 0 {
+				t.Errorf("Used() = %d after Bytes(), want 0", got)
+			}
+		})
+	}
+}
+
+func TestWriteNotification(t *testing.T) {
+	ctx := context.Background()
+	b := NewBuffer(0)
+
+	n := &api.Notification{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "golang",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1426076671, 123000000), // Wed Mar 11 13:24:31 CET 2015
+		Severity: api.Warning,
+		Message:  "too hot",
+	}
+
+	if err := b.WriteNotification(ctx, n); err != nil {
+		t.Errorf("WriteNotification got %v, want nil", err)
+		return
+	}
+
+	want := []byte{
+		0, 0, 0, 16, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm', 0,
+		0, 2, 0, 11, 'g', 'o', 'l', 'a', 'n', 'g', 0,
+		0, 4, 0, 10, 'g', 'a', 'u', 'g', 'e', 0,
+		// 1426076671.123 * 2^30 = 1531238166015458148.352
+		// 1531238166015458148 = 0x15400cffc7df3b64
+		0, 8, 0, 12, 0x15, 0x40, 0x0c, 0xff, 0xc7, 0xdf, 0x3b, 0x64,
+		1, 1, 0, 12, 0, 0, 0, 0, 0, 0, 0, 2,
+		1, 0, 0, 12, 't', 'o', 'o', ' ', 'h', 'o', 't', 0,
+	}
+	got := b.buffer.Bytes()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var parsed []*api.Notification
+	if _, err := Parse(got, ParseOpts{
+		Notification: func(n *api.Notification) {
+			parsed = append(parsed, n)
+		},
+	}); err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	if len(parsed) != 1 || !reflect.DeepEqual(parsed[0].Identifier, n.Identifier) ||
+		parsed[0].Severity != n.Severity || parsed[0].Message != n.Message {
+		t.Errorf("Parse() = %+v, want %+v", parsed, n)
+	}
+}
+
 func TestWriteTime(t *testing.T) {
 	b := &Buffer{buffer: new(bytes.Buffer), size: DefaultBufferSize}
 	b.writeTime(time.Unix(1426083986, 314000000)) // Wed Mar 11 15:26:26 CET 2015
@@ -98,15 +246,17 @@ func TestWriteValues(t *testing.T) {
 		api.Gauge(42),
 		api.Derive(31337),
 		api.Gauge(math.NaN()),
+		api.Absolute(23),
 	})
 
 	want := []byte{0, 6, // pkg type
-		0, 33, // pkg len
-		0, 3, // num values
-		1, 2, 1, // gauge, derive, gauge
+		0, 42, // pkg len
+		0, 4, // num values
+		1, 2, 1, 3, // gauge, derive, gauge, absolute
 		0, 0, 0, 0, 0, 0, 0x45, 0x40, // 42.0
 		0, 0, 0, 0, 0, 0, 0x7a, 0x69, // 31337
 		0, 0, 0, 0, 0, 0, 0xf8, 0x7f, // NaN
+		0, 0, 0, 0, 0, 0, 0, 23, // 23
 	}
 	got := b.buffer.Bytes()
 
@@ -176,3 +326,30 @@ func TestUnknownType(t *testing.T) {
 	}
 
 }
+
+func BenchmarkBufferWriteFlush(b *testing.B) {
+	ctx := context.Background()
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "golang",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1426076671, 123000000),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+
+	buf := NewBuffer(0)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := buf.Write(ctx, vl); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := buf.WriteTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
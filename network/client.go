@@ -3,9 +3,17 @@ package network // import "collectd.org/network"
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"net"
+	"reflect"
+	"sync"
+	"time"
 
 	"collectd.org/api"
+	"go.uber.org/multierr"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // ClientOptions holds configuration options for Client.
@@ -17,6 +25,29 @@ type ClientOptions struct {
 	Username, Password string
 	// Size of the send buffer. When zero, DefaultBufferSize is used.
 	BufferSize int
+	// Interface is the name of the interface to send multicast packets
+	// on. If empty, the system chooses an interface. Has no effect when
+	// sending to a unicast address.
+	Interface string
+	// LegacyTime rounds times and intervals to whole seconds and sends
+	// them in the format used before collectd 5.0. See Buffer.LegacyTime.
+	LegacyTime bool
+	// SendMeta sends the value lists' meta data using non-standard part
+	// types. Only enable it if all receivers use this package. See
+	// Buffer.SendMeta.
+	SendMeta bool
+	// TypesDB, if not nil, is used to check value lists before sending
+	// them. The network protocol only transmits the type's name and the
+	// kind of each value; receivers drop value lists whose type they
+	// don't know or whose values don't match their types.db. Checking
+	// against the receiver's types.db catches this common cause of
+	// silently lost metrics. Value lists failing the check are still
+	// sent.
+	TypesDB *api.TypesDB
+	// TypeWarning, if not nil, is called for every value list that
+	// doesn't match TypesDB. If TypeWarning is nil, a warning is logged
+	// once per type.
+	TypeWarning func(vl *api.ValueList, err error)
 }
 
 // Client is a connection to a collectd server. It implements the
@@ -25,6 +56,9 @@ type Client struct {
 	udp    net.Conn
 	buffer *Buffer
 	opts   ClientOptions
+
+	// warned holds the types a warning has been logged for.
+	warned sync.Map
 }
 
 // Dial connects to the collectd server at address. "address" must be a network
@@ -35,12 +69,25 @@ func Dial(address string, opts ClientOptions) (*Client, error) {
 		return nil, err
 	}
 
+	if opts.Interface != "" {
+		if err := setMulticastInterface(c, opts.Interface); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
 	b := NewBuffer(opts.BufferSize)
 	if opts.SecurityLevel == Sign {
 		b.Sign(opts.Username, opts.Password)
 	} else if opts.SecurityLevel == Encrypt {
 		b.Encrypt(opts.Username, opts.Password)
 	}
+	if opts.LegacyTime {
+		b.LegacyTime()
+	}
+	if opts.SendMeta {
+		b.SendMeta()
+	}
 
 	return &Client{
 		udp:    c,
@@ -49,23 +96,154 @@ func Dial(address string, opts ClientOptions) (*Client, error) {
 	}, nil
 }
 
+// setMulticastInterface sets the outgoing interface of c, if c's remote
+// address is a multicast group.
+func setMulticastInterface(c net.Conn, name string) error {
+	raddr, ok := c.RemoteAddr().(*net.UDPAddr)
+	if !ok || !raddr.IP.IsMulticast() {
+		return nil
+	}
+
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return fmt.Errorf("multicast interface %q: %w", name, err)
+	}
+
+	pc := c.(net.PacketConn)
+	if raddr.IP.To4() != nil {
+		err = ipv4.NewPacketConn(pc).SetMulticastInterface(ifi)
+	} else {
+		err = ipv6.NewPacketConn(pc).SetMulticastInterface(ifi)
+	}
+	if err != nil {
+		return fmt.Errorf("multicast interface %q: %w", name, err)
+	}
+	return nil
+}
+
+// Send connects to the collectd server at address, writes all value lists in
+// vls and closes the connection.
+// This is a convenience function for programs that send a fixed set of metrics
+// and exit. If you need more control, see the "Client" type above.
+func Send(ctx context.Context, address string, opts ClientOptions, vls []api.ValueList) error {
+	c, err := Dial(address, opts)
+	if err != nil {
+		return err
+	}
+
+	for i := range vls {
+		if err := c.Write(ctx, &vls[i]); err != nil {
+			return multierr.Append(err, c.Close())
+		}
+	}
+
+	return c.Close()
+}
+
 // Write adds a ValueList to the internal buffer. Data is only written to
-// the network when the buffer is full.
+// the network when the buffer is full. If ctx is cancelled, Write returns the
+// context's error without adding vl to the buffer. If ctx has a deadline, it
+// is used as the write deadline when data is written to the network.
 func (c *Client) Write(ctx context.Context, vl *api.ValueList) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c.opts.TypesDB != nil {
+		c.checkType(vl)
+	}
+
 	if err := c.buffer.Write(ctx, vl); !errors.Is(err, ErrNotEnoughSpace) {
 		return err
 	}
 
-	if err := c.Flush(); err != nil {
+	if err := c.flush(ctx); err != nil {
 		return err
 	}
 
 	return c.buffer.Write(ctx, vl)
 }
 
+// checkType checks vl against c.opts.TypesDB and reports mismatches.
+func (c *Client) checkType(vl *api.ValueList) {
+	err := checkType(c.opts.TypesDB, vl)
+	if err == nil {
+		return
+	}
+
+	if c.opts.TypeWarning != nil {
+		c.opts.TypeWarning(vl, err)
+		return
+	}
+	if _, loaded := c.warned.LoadOrStore(vl.Type, true); !loaded {
+		log.Printf("network.Client: %v: %v; the server will likely drop these metrics", vl.Identifier, err)
+	}
+}
+
+// checkType returns an error if vl's type is not in db or if its values don't
+// match the type's data sources.
+func checkType(db *api.TypesDB, vl *api.ValueList) error {
+	ds, ok := db.DataSet(vl.Type)
+	if !ok {
+		return fmt.Errorf("type %q not found in types.db", vl.Type)
+	}
+
+	if len(ds.Sources) != len(vl.Values) {
+		return fmt.Errorf("type %q has %d data sources, got %d values", vl.Type, len(ds.Sources), len(vl.Values))
+	}
+
+	for i, dsrc := range ds.Sources {
+		if reflect.TypeOf(vl.Values[i]) != dsrc.Type {
+			return fmt.Errorf("value %d is a %T, but data source %q is a %s", i, vl.Values[i], dsrc.Name, dsrc.Type.Name())
+		}
+	}
+
+	return nil
+}
+
+// Notify sends a Notification to the server. Since notifications are
+// typically time sensitive, the buffer is flushed immediately. Like Write,
+// Notify honors ctx's cancellation and deadline.
+func (c *Client) Notify(ctx context.Context, n *api.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := c.buffer.WriteNotification(ctx, n)
+	if errors.Is(err, ErrNotEnoughSpace) {
+		if err := c.flush(ctx); err != nil {
+			return err
+		}
+		err = c.buffer.WriteNotification(ctx, n)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.flush(ctx)
+}
+
 // Flush writes the contents of the underlying buffer to the network
 // immediately.
 func (c *Client) Flush() error {
+	return c.flush(context.Background())
+}
+
+// flush writes the contents of the underlying buffer to the network, unless
+// ctx has been cancelled. The context's deadline, if any, is used as the write
+// deadline.
+func (c *Client) flush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := c.udp.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+		defer c.udp.SetWriteDeadline(time.Time{})
+	}
+
 	_, err := c.buffer.WriteTo(c.udp)
 	return err
 }
@@ -2,11 +2,15 @@ package network // import "collectd.org/network"
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
+	"strings"
+	"testing"
 	"time"
 
 	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
 )
 
 func ExampleClient() {
@@ -32,3 +36,234 @@ func ExampleClient() {
 		log.Fatal(err)
 	}
 }
+
+func TestClient_TypesDB(t *testing.T) {
+	db, err := api.NewTypesDB(strings.NewReader(
+		"gauge value:GAUGE:U:U\nif_octets rx:DERIVE:0:U, tx:DERIVE:0:U\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.ListenPacket("udp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var got []string
+	c, err := Dial(conn.LocalAddr().String(), ClientOptions{
+		TypesDB: db,
+		TypeWarning: func(vl *api.ValueList, err error) {
+			got = append(got, vl.Type)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	vls := []*api.ValueList{
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "test", Type: "gauge"},
+			Values:     []api.Value{api.Gauge(42)},
+		},
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "test", Type: "unknown"},
+			Values:     []api.Value{api.Gauge(42)},
+		},
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "test", Type: "if_octets"},
+			Values:     []api.Value{api.Derive(1)},
+		},
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "test", Type: "if_octets"},
+			Values:     []api.Value{api.Derive(1), api.Gauge(2)},
+		},
+		{
+			Identifier: api.Identifier{Host: "example.com", Plugin: "test", Type: "if_octets"},
+			Values:     []api.Value{api.Derive(1), api.Derive(2)},
+		},
+	}
+
+	ctx := context.Background()
+	for _, vl := range vls {
+		if err := c.Write(ctx, vl); err != nil {
+			t.Errorf("Write(%v) = %v", vl.Identifier, err)
+		}
+	}
+
+	want := []string{"unknown", "if_octets", "if_octets"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TypeWarning calls differ (+got/-want):\n%s", diff)
+	}
+}
+
+func TestClient_WriteContext(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	c, err := Dial(conn.LocalAddr().String(), ClientOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestClient_WriteContext",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Unix(1588164686, 0))
+	defer cancel()
+
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		want error
+	}{
+		{"cancelled", cancelled, context.Canceled},
+		{"deadline exceeded", expired, context.DeadlineExceeded},
+	} {
+		if err := c.Write(tc.ctx, vl); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Write() = %v, want %v", tc.name, err, tc.want)
+		}
+		if err := c.Notify(tc.ctx, &api.Notification{Severity: api.Okay, Message: "test"}); !errors.Is(err, tc.want) {
+			t.Errorf("%s: Notify() = %v, want %v", tc.name, err, tc.want)
+		}
+		if got := c.buffer.Used(); got != 0 {
+			t.Errorf("%s: buffer.Used() = %d, want 0", tc.name, got)
+		}
+	}
+
+	// A context with a deadline in the future sets the write deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Write(ctx, vl); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	if err := c.flush(ctx); err != nil {
+		t.Fatalf("flush() = %v", err)
+	}
+
+	buf := make([]byte, DefaultBufferSize)
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(buf[:n], ParseOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*api.ValueList{vl}, got); diff != "" {
+		t.Errorf("received value lists differ (+got/-want):\n%s", diff)
+	}
+}
+
+func TestDial_SecurityLevel(t *testing.T) {
+	ctx := context.Background()
+	lookup := PasswordLookupMap{"user": "secret"}
+
+	for _, sl := range []SecurityLevel{None, Sign, Encrypt} {
+		t.Run(sl.String(), func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "localhost:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			c, err := Dial(conn.LocalAddr().String(), ClientOptions{
+				SecurityLevel: sl,
+				Username:      "user",
+				Password:      "secret",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vl := &api.ValueList{
+				Identifier: api.Identifier{
+					Host:         "example.com",
+					Plugin:       "TestDial_SecurityLevel",
+					Type:         "gauge",
+					TypeInstance: sl.String(),
+				},
+				Time:     time.Unix(1588164686, 0),
+				Interval: 10 * time.Second,
+				Values:   []api.Value{api.Gauge(42)},
+			}
+			if err := c.Write(ctx, vl); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			buf := make([]byte, DefaultBufferSize)
+			if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+				t.Fatal(err)
+			}
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The packet must satisfy exactly the requested security
+			// level.
+			got, err := Parse(buf[:n], ParseOpts{
+				PasswordLookup: lookup,
+				SecurityLevel:  sl,
+			})
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			if diff := cmp.Diff([]*api.ValueList{vl}, got); diff != "" {
+				t.Errorf("received value lists differ (+got/-want):\n%s", diff)
+			}
+
+			if sl == Encrypt {
+				return
+			}
+			got, err = Parse(buf[:n], ParseOpts{
+				PasswordLookup: lookup,
+				SecurityLevel:  sl + 1,
+			})
+			if err != nil || len(got) != 0 {
+				t.Errorf("Parse(SecurityLevel: %v) = (%v, %v), want (nil, nil)", sl+1, got, err)
+			}
+		})
+	}
+}
+
+func TestDial_Interface(t *testing.T) {
+	// The interface only applies to multicast destinations.
+	c, err := Dial("localhost:"+DefaultService, ClientOptions{
+		Interface: "does-not-exist0",
+	})
+	if err != nil {
+		t.Errorf("Dial(unicast) = %v, want success", err)
+	} else {
+		c.Close()
+	}
+
+	c, err = Dial(net.JoinHostPort(DefaultIPv4Address, DefaultService), ClientOptions{
+		Interface: "does-not-exist0",
+	})
+	if err == nil {
+		c.Close()
+		t.Error("Dial(multicast) with unknown interface succeeded, want error")
+	}
+}
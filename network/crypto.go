@@ -12,7 +12,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"strings"
@@ -26,14 +25,63 @@ type PasswordLookup interface {
 	Password(user string) (string, error)
 }
 
+// PasswordLookupMap implements the PasswordLookup interface using a static
+// mapping from usernames to passwords.
+type PasswordLookupMap map[string]string
+
+// Password returns the password of user. An error is returned if the user is
+// unknown.
+func (m PasswordLookupMap) Password(user string) (string, error) {
+	pwd, ok := m[user]
+	if !ok {
+		return "", fmt.Errorf("no such user: %q", user)
+	}
+
+	return pwd, nil
+}
+
+// LookupPolicy determines how signed and encrypted data is handled when the
+// password of the sending user can't be determined, e.g. because the user is
+// unknown or PasswordLookup returns an error.
+type LookupPolicy int
+
+// Predefined lookup policies.
+const (
+	// FailClosed rejects the packet with an error. This is the default.
+	FailClosed LookupPolicy = iota
+	// FallThrough ignores the signature of signed data, i.e. the data is
+	// treated as if it was sent in plain text. It is only returned if
+	// ParseOpts.SecurityLevel is None. Encrypted data is skipped.
+	FallThrough
+)
+
+// lookupError is returned when the password of a user can't be determined.
+type lookupError struct {
+	user string
+	err  error
+}
+
+func (e lookupError) Error() string {
+	return fmt.Sprintf("password lookup for user %q: %v", e.user, e.err)
+}
+
+func (e lookupError) Unwrap() error {
+	return e.err
+}
+
 // AuthFile implements the PasswordLookup interface in the same way the
 // collectd network plugin implements it, i.e. by stat'ing and reading a file.
 //
 // The file has a very simple syntax with one username / password mapping per
-// line, separated by a colon. For example:
+// line, separated by a colon. Whitespace around usernames and passwords is
+// ignored, as are lines starting with "#" and lines without a colon. For
+// example:
 //
+//   # comment
 //   alice: w0nderl4nd
 //   bob:   bu1|der
+//
+// The file is read again when its modification time changes.
 type AuthFile struct {
 	name string
 	last time.Time
@@ -89,16 +137,15 @@ func (a *AuthFile) update() error {
 
 	newData := make(map[string]string)
 
-	r := bufio.NewReader(file)
-	for {
-		line, err := r.ReadString('\n')
-		if err != nil && err != io.EOF {
-			return err
-		} else if err == io.EOF {
-			break
+	// Like collectd, lines without a colon, with an empty username, or
+	// starting with "#" are ignored.
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		line := strings.Trim(s.Text(), " \r\t\v")
+		if strings.HasPrefix(line, "#") {
+			continue
 		}
 
-		line = strings.Trim(line, " \r\n\t\v")
 		fields := strings.SplitN(line, ":", 2)
 		if len(fields) != 2 {
 			continue
@@ -106,12 +153,15 @@ func (a *AuthFile) update() error {
 
 		user := strings.TrimSpace(fields[0])
 		pass := strings.TrimSpace(fields[1])
-		if strings.HasPrefix(user, "#") {
+		if user == "" {
 			continue
 		}
 
 		newData[user] = pass
 	}
+	if err := s.Err(); err != nil {
+		return err
+	}
 
 	a.data = newData
 	a.last = fi.ModTime()
@@ -140,7 +190,7 @@ func signSHA256(payload []byte, username, password string) []byte {
 
 func verifySHA256(part, payload []byte, lookup PasswordLookup) (bool, error) {
 	if lookup == nil {
-		return false, errors.New("no PasswordLookup available")
+		return false, lookupError{err: errors.New("no PasswordLookup available")}
 	}
 
 	if len(part) <= 32 {
@@ -152,7 +202,7 @@ func verifySHA256(part, payload []byte, lookup PasswordLookup) (bool, error) {
 
 	password, err := lookup.Password(user)
 	if err != nil {
-		return false, err
+		return false, lookupError{user: user, err: err}
 	}
 
 	mac := hmac.New(sha256.New, bytes.NewBufferString(password).Bytes())
@@ -209,7 +259,7 @@ func encryptAES256(plaintext []byte, username, password string) ([]byte, error)
 
 func decryptAES256(ciphertext []byte, lookup PasswordLookup) ([]byte, error) {
 	if lookup == nil {
-		return nil, errors.New("no PasswordLookup available")
+		return nil, lookupError{err: errors.New("no PasswordLookup available")}
 	}
 	if len(ciphertext) < 2 {
 		return nil, errors.New("buffer too short")
@@ -224,7 +274,7 @@ func decryptAES256(ciphertext []byte, lookup PasswordLookup) ([]byte, error) {
 
 	password, err := lookup.Password(user)
 	if err != nil {
-		return nil, err
+		return nil, lookupError{user: user, err: err}
 	}
 
 	iv := make([]byte, 16)
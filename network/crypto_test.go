@@ -2,21 +2,16 @@ package network // import "collectd.org/network"
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
-)
-
-type mockPasswordLookup map[string]string
-
-func (l mockPasswordLookup) Password(user string) (string, error) {
-	pass, ok := l[user]
-	if !ok {
-		return "", errors.New("not found")
-	}
+	"time"
 
-	return pass, nil
-}
+	"collectd.org/api"
+)
 
 func TestSign(t *testing.T) {
 	want := []byte{
@@ -34,7 +29,7 @@ func TestSign(t *testing.T) {
 		t.Errorf("got %v, want %v", got, want)
 	}
 
-	passwords := mockPasswordLookup{
+	passwords := PasswordLookupMap{
 		"admin": "admin",
 	}
 	ok, err := verifySHA256(want[4:41], want[41:], passwords)
@@ -74,7 +69,7 @@ func TestEncrypt(t *testing.T) {
 		t.Errorf("got (%v, %v), want (%v, nil)", ciphertext[:11], err, want)
 	}
 
-	passwords := mockPasswordLookup{
+	passwords := PasswordLookupMap{
 		"admin": "admin",
 	}
 	if got, err := decryptAES256(ciphertext[4:], passwords); !bytes.Equal(got, plaintext) || err != nil {
@@ -92,3 +87,233 @@ func TestEncrypt(t *testing.T) {
 		t.Errorf("got (%v, %v), want (nil, \"no such user\")", got, err)
 	}
 }
+
+func TestParse_LookupPolicy(t *testing.T) {
+	cases := []struct {
+		title         string
+		securityLevel SecurityLevel // used for sending
+		policy        LookupPolicy
+		minLevel      SecurityLevel // used for parsing
+		wantCount     int
+		wantErr       bool
+	}{
+		{"signed/fail closed", Sign, FailClosed, None, 0, true},
+		{"signed/fall through", Sign, FallThrough, None, 1, false},
+		{"signed/fall through/sign required", Sign, FallThrough, Sign, 0, false},
+		{"encrypted/fail closed", Encrypt, FailClosed, None, 0, true},
+		{"encrypted/fall through", Encrypt, FallThrough, None, 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			b := NewBuffer(0)
+			switch tc.securityLevel {
+			case Sign:
+				b.Sign("unknown", "secret")
+			case Encrypt:
+				b.Encrypt("unknown", "secret")
+			}
+
+			if err := b.Write(context.Background(), &api.ValueList{
+				Identifier: api.Identifier{
+					Host:   "example.com",
+					Plugin: "TestParse",
+					Type:   "gauge",
+				},
+				Time:     time.Unix(1588164686, 0),
+				Interval: 10 * time.Second,
+				Values:   []api.Value{api.Gauge(42)},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := b.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vls, err := Parse(data, ParseOpts{
+				PasswordLookup: PasswordLookupMap{"admin": "admin"},
+				SecurityLevel:  tc.minLevel,
+				LookupPolicy:   tc.policy,
+			})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Parse() = %v, want error %v", err, tc.wantErr)
+			}
+			if len(vls) != tc.wantCount {
+				t.Errorf("len(Parse()) = %d, want %d", len(vls), tc.wantCount)
+			}
+		})
+	}
+}
+
+func TestParse_Security(t *testing.T) {
+	cases := []struct {
+		title         string
+		securityLevel SecurityLevel // used for sending
+		password      string
+		minLevel      SecurityLevel // used for parsing
+		wantCount     int
+		wantErr       bool
+	}{
+		{"plain", None, "", None, 1, false},
+		{"plain/sign required", None, "", Sign, 0, false},
+		{"signed", Sign, "admin", None, 1, false},
+		{"signed/sign required", Sign, "admin", Sign, 1, false},
+		{"signed/encrypt required", Sign, "admin", Encrypt, 0, false},
+		{"signed/wrong password", Sign, "wrong", None, 0, true},
+		{"encrypted", Encrypt, "admin", None, 1, false},
+		{"encrypted/encrypt required", Encrypt, "admin", Encrypt, 1, false},
+		{"encrypted/wrong password", Encrypt, "wrong", None, 0, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			b := NewBuffer(0)
+			switch tc.securityLevel {
+			case Sign:
+				b.Sign("admin", tc.password)
+			case Encrypt:
+				b.Encrypt("admin", tc.password)
+			}
+
+			if err := b.Write(context.Background(), &api.ValueList{
+				Identifier: api.Identifier{
+					Host:   "example.com",
+					Plugin: "TestParse",
+					Type:   "gauge",
+				},
+				Time:     time.Unix(1588164686, 0),
+				Interval: 10 * time.Second,
+				Values:   []api.Value{api.Gauge(42)},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := b.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vls, err := Parse(data, ParseOpts{
+				PasswordLookup: PasswordLookupMap{"admin": "admin"},
+				SecurityLevel:  tc.minLevel,
+			})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("Parse() = %v, want error %v", err, tc.wantErr)
+			}
+			if len(vls) != tc.wantCount {
+				t.Errorf("len(Parse()) = %d, want %d", len(vls), tc.wantCount)
+			}
+		})
+	}
+}
+
+func TestPasswordLookupMap(t *testing.T) {
+	m := PasswordLookupMap{"admin": "secret"}
+
+	if got, err := m.Password("admin"); err != nil || got != "secret" {
+		t.Errorf("Password(%q) = (%q, %v), want (%q, nil)", "admin", got, err, "secret")
+	}
+	if got, err := m.Password("unknown"); err == nil {
+		t.Errorf("Password(%q) = (%q, nil), want error", "unknown", got)
+	}
+
+	// Parsing data from an unknown user must report the lookup error.
+	for _, sl := range []SecurityLevel{Sign, Encrypt} {
+		b := NewBuffer(0)
+		if sl == Sign {
+			b.Sign("unknown", "secret")
+		} else {
+			b.Encrypt("unknown", "secret")
+		}
+		if err := b.Write(context.Background(), &api.ValueList{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "TestPasswordLookupMap",
+				Type:   "gauge",
+			},
+			Time:     time.Unix(1588164686, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(42)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := b.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vls, err := Parse(data, ParseOpts{PasswordLookup: m})
+		var lerr lookupError
+		if !errors.As(err, &lerr) || lerr.user != "unknown" {
+			t.Errorf("%v: Parse() = %v, want lookup error for user %q", sl, err, "unknown")
+		}
+		if len(vls) != 0 {
+			t.Errorf("%v: Parse() returned %d value lists, want 0", sl, len(vls))
+		}
+	}
+}
+
+func TestAuthFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "users")
+	content := "# comment\n" +
+		"alice: w0nderl4nd\n" +
+		"  bob :\tbu1|der:colon  \r\n" +
+		"malformed line\n" +
+		": no user\n" +
+		"   # indented comment: ignored\n" +
+		"\n" +
+		"carol:last line without newline"
+	if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuthFile(name)
+
+	cases := []struct {
+		user    string
+		want    string
+		wantErr bool
+	}{
+		{user: "alice", want: "w0nderl4nd"},
+		{user: "bob", want: "bu1|der:colon"},
+		{user: "carol", want: "last line without newline"},
+		{user: "malformed line", wantErr: true},
+		{user: "", wantErr: true},
+		{user: "# indented comment", wantErr: true},
+		{user: "unknown", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := a.Password(tc.user)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("Password(%q) = (%q, %v), want error %v", tc.user, got, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Password(%q) = %q, want %q", tc.user, got, tc.want)
+		}
+	}
+
+	// The file is re-read when its modification time changes.
+	if err := os.WriteFile(name, []byte("alice: changed\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(name, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := a.Password("alice"); err != nil || got != "changed" {
+		t.Errorf("Password(%q) = (%q, %v) after update, want (%q, nil)", "alice", got, err, "changed")
+	}
+	if got, err := a.Password("bob"); err == nil {
+		t.Errorf("Password(%q) = (%q, nil) after update, want error", "bob", got)
+	}
+
+	// A missing file is an error.
+	if got, err := NewAuthFile(filepath.Join(t.TempDir(), "missing")).Password("alice"); err == nil {
+		t.Errorf("Password() = (%q, nil) for missing file, want error", got)
+	}
+}
@@ -0,0 +1,101 @@
+package network // import "collectd.org/network"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"collectd.org/api"
+)
+
+// Decoder reads value lists in the binary network format from an input
+// stream, e.g. a TCP connection or a file of concatenated packets. Unlike
+// Parse, it does not require the entire input to be held in memory and
+// returns value lists one at a time.
+//
+// Since a stream has no packet boundaries, the identifier, time and interval
+// of the previous part carry over to following packets. Signed parts cover
+// the remainder of a packet and are therefore not supported; encrypted parts
+// are.
+type Decoder struct {
+	// Opts holds the options used for parsing. They must not be changed
+	// after the first call to Decode.
+	Opts ParseOpts
+
+	r       io.Reader
+	p       *partParser
+	pending []*api.ValueList
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r: r,
+	}
+}
+
+// Decode returns the next value list from the input stream. Notifications are
+// passed to Opts.Notification, if set. At the end of the input, Decode returns
+// io.EOF. If the input ends within a part, io.ErrUnexpectedEOF is returned.
+//
+// Like with Parse, errors in a part's content are recoverable and Decode may
+// be called again to continue with the next part. Structural errors, such as
+// an invalid part length or io.ErrUnexpectedEOF, are fatal.
+func (d *Decoder) Decode() (*api.ValueList, error) {
+	if d.p == nil {
+		d.p = &partParser{
+			sl:   None,
+			opts: d.Opts,
+		}
+	}
+
+	for len(d.pending) == 0 {
+		partType, payload, err := d.readPart()
+		if errors.Is(err, io.EOF) {
+			d.p.logDropped()
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if partType == typeSignSHA256 {
+			return nil, errors.New("signed data is not supported by Decoder")
+		}
+
+		// Value lists parsed despite an error are returned by the
+		// following calls.
+		if d.pending, err = d.p.part(partType, payload); err != nil {
+			return nil, fmt.Errorf("part of type %#x: %w", partType, err)
+		}
+	}
+
+	vl := d.pending[0]
+	d.pending = d.pending[1:]
+	return vl, nil
+}
+
+// readPart reads the next part and returns its type and payload.
+func (d *Decoder) readPart() (uint16, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	partType := binary.BigEndian.Uint16(header[0:2])
+	partLength := int(binary.BigEndian.Uint16(header[2:4]))
+	if partLength < 5 {
+		return 0, nil, fmt.Errorf("invalid length %d", partLength)
+	}
+
+	payload := make([]byte, partLength-4)
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+
+	return partType, payload, nil
+}
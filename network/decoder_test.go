@@ -0,0 +1,150 @@
+package network // import "collectd.org/network"
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecoder(t *testing.T) {
+	var (
+		stream []byte
+		want   []*api.ValueList
+	)
+	for _, file := range []string{"testdata/packet1.bin", "testdata/packet2.bin"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vls, err := Parse(data, ParseOpts{})
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", file, err)
+		}
+
+		stream = append(stream, data...)
+		want = append(want, vls...)
+	}
+
+	// Read the stream one byte at a time to ensure value lists are decoded
+	// incrementally.
+	d := NewDecoder(iotest.OneByteReader(bytes.NewReader(stream)))
+
+	var got []*api.ValueList
+	for {
+		vl, err := d.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decode() = %v", err)
+		}
+		got = append(got, vl)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Decode() differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestDecoder_Truncated(t *testing.T) {
+	data, err := os.ReadFile("testdata/packet1.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Parse(data, ParseOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A complete packet followed by a truncated one.
+	stream := append(append([]byte{}, data...), data[:len(data)-3]...)
+	d := NewDecoder(bytes.NewReader(stream))
+
+	for i := range want {
+		vl, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Decode() = %v", err)
+		}
+		if diff := cmp.Diff(want[i], vl); diff != "" {
+			t.Errorf("Decode() differs (+got/-want):\n%s", diff)
+		}
+	}
+
+	if _, err := d.Decode(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Decode() = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecoder_Security(t *testing.T) {
+	ctx := context.Background()
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestDecoder",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+	lookup := PasswordLookupMap{"user": "secret"}
+
+	t.Run("encrypted", func(t *testing.T) {
+		b := NewBuffer(0)
+		b.Encrypt("user", "secret")
+		if err := b.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+		data, err := b.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := NewDecoder(bytes.NewReader(data))
+		d.Opts = ParseOpts{
+			PasswordLookup: lookup,
+			SecurityLevel:  Encrypt,
+		}
+
+		got, err := d.Decode()
+		if err != nil {
+			t.Fatalf("Decode() = %v", err)
+		}
+		if diff := cmp.Diff(vl, got); diff != "" {
+			t.Errorf("Decode() differs (+got/-want):\n%s", diff)
+		}
+		if _, err := d.Decode(); !errors.Is(err, io.EOF) {
+			t.Errorf("Decode() = %v, want %v", err, io.EOF)
+		}
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		b := NewBuffer(0)
+		b.Sign("user", "secret")
+		if err := b.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+		data, err := b.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := NewDecoder(bytes.NewReader(data))
+		d.Opts = ParseOpts{
+			PasswordLookup: lookup,
+		}
+
+		if got, err := d.Decode(); err == nil {
+			t.Errorf("Decode() = (%v, nil), want error", got)
+		}
+	})
+}
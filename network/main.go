@@ -1,8 +1,17 @@
 /*
 Package network implements collectd's binary network protocol.
+
+The protocol transmits the name of a value list's type and the kind of each
+value (gauge, derive, …), but not the names of the data sources. Receivers look
+up the type in their types.db and drop value lists with an unknown type or a
+mismatching number of values. When sending custom types, make sure they are
+registered with the receiving collectd, e.g. using the TypesDB config option.
+ClientOptions.TypesDB can be used to detect such problems on the client.
 */
 package network // import "collectd.org/network"
 
+import "fmt"
+
 // Well-known addresses and port.
 const (
 	DefaultIPv4Address = "239.192.74.66"
@@ -18,9 +27,10 @@ const DefaultBufferSize = 1452
 
 // Numeric data source type identifiers.
 const (
-	dsTypeCounter = 0
-	dsTypeGauge   = 1
-	dsTypeDerive  = 2
+	dsTypeCounter  = 0
+	dsTypeGauge    = 1
+	dsTypeDerive   = 2
+	dsTypeAbsolute = 3
 )
 
 // IDs of the various "parts", i.e. subcomponents of a packet.
@@ -35,12 +45,20 @@ const (
 	typeValues         = 0x0006
 	typeInterval       = 0x0007
 	typeIntervalHR     = 0x0009
+	typeMeta           = 0x000a // Non-standard, see Buffer.SendMeta.
+	typeValueMeta      = 0x000b // Non-standard, see Buffer.SendMeta.
+	typeMessage        = 0x0100
+	typeSeverity       = 0x0101
 	typeSignSHA256     = 0x0200
 	typeEncryptAES256  = 0x0210
 )
 
 // SecurityLevel determines whether data is signed, encrypted or used without
 // any protection.
+//
+// Security levels are ordered: Encrypt > Sign > None. When a minimum security
+// level is required, data with a higher level is accepted, too. For example,
+// encrypted data satisfies the "Sign" level.
 type SecurityLevel int
 
 // Predefined security levels. "None" is used for plain text.
@@ -49,3 +67,17 @@ const (
 	Sign
 	Encrypt
 )
+
+// String returns the name of the security level, e.g. "Sign".
+func (sl SecurityLevel) String() string {
+	switch sl {
+	case None:
+		return "None"
+	case Sign:
+		return "Sign"
+	case Encrypt:
+		return "Encrypt"
+	default:
+		return fmt.Sprintf("SecurityLevel(%d)", int(sl))
+	}
+}
@@ -4,17 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net"
+	"os"
+	"runtime"
+	"sync"
 	"testing"
 	"time"
 
 	"collectd.org/api"
+	"collectd.org/format"
 	"collectd.org/network"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/net/nettest"
 )
 
+// This example demonstrates how to listen to encrypted network traffic and
+// dump it to STDOUT using format.Putval.
+func ExampleServer_ListenAndWrite() {
+	srv := &network.Server{
+		Addr:           net.JoinHostPort("::", network.DefaultService),
+		Writer:         format.NewPutval(os.Stdout),
+		PasswordLookup: network.NewAuthFile("/etc/collectd/users"),
+	}
+
+	// blocks
+	log.Fatal(srv.ListenAndWrite(context.Background()))
+}
+
 type testPasswordLookup map[string]string
 
 func (l testPasswordLookup) Password(user string) (string, error) {
@@ -117,3 +135,540 @@ loop:
 		t.Errorf("sent and received value lists differ (+got/-want):\n%s", diff)
 	}
 }
+
+func TestServer_ParseOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ch := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		srv := &network.Server{
+			Conn: conn.(*net.UDPConn),
+			ParseError: func(_ []byte, err error) {
+				ch <- err
+			},
+		}
+
+		err := srv.ListenAndWrite(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Server.ListenAndWrite() = %v, want %v", err, context.Canceled)
+		}
+		close(done)
+	}()
+
+	buf := network.NewBuffer(0)
+	if err := buf.Write(ctx, &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestServer_ParseOnly",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	valid, err := buf.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// A valid packet must be accepted silently, even though no Writer is
+	// set. The invalid packet must be reported via ParseError.
+	for _, packet := range [][]byte{valid, {0}} {
+		if _, err := client.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case err := <-ch:
+		if err == nil {
+			t.Error("ParseError called with nil error")
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for ParseError to be called")
+	}
+
+	select {
+	case err := <-ch:
+		t.Errorf("ParseError called unexpectedly: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	<-done
+}
+
+func TestServer_EmptyPacket(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	errCh := make(chan error, 1)
+	vlCh := make(chan *api.ValueList, 1)
+	done := make(chan struct{})
+	go func() {
+		srv := &network.Server{
+			Conn: conn.(*net.UDPConn),
+			Writer: api.WriterFunc(func(_ context.Context, vl *api.ValueList) error {
+				vlCh <- vl
+				return nil
+			}),
+			ParseError: func(_ []byte, err error) {
+				errCh <- err
+			},
+		}
+
+		err := srv.ListenAndWrite(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Server.ListenAndWrite() = %v, want %v", err, context.Canceled)
+		}
+		close(done)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.Write(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-errCh:
+		t.Errorf("ParseError called unexpectedly: %v", err)
+	case vl := <-vlCh:
+		t.Errorf("received unexpected value list %v", vl)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The server must continue to process packets.
+	vl := api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestServer_EmptyPacket",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+	if err := network.Send(ctx, conn.LocalAddr().String(), network.ClientOptions{}, []api.ValueList{vl}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-vlCh:
+		if diff := cmp.Diff(vl, *got); diff != "" {
+			t.Errorf("received value list differs (+got/-want):\n%s", diff)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for value list")
+	}
+
+	cancel()
+	<-done
+}
+
+func TestSend(t *testing.T) {
+	ctx := context.Background()
+
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var want []api.ValueList
+	for i := 0; i < 3; i++ {
+		want = append(want, api.ValueList{
+			Identifier: api.Identifier{
+				Host:         "example.com",
+				Plugin:       "TestSend",
+				Type:         "gauge",
+				TypeInstance: fmt.Sprintf("%d", i),
+			},
+			Time:     time.Unix(1588164686, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(i)},
+		})
+	}
+
+	if err := network.Send(ctx, conn.LocalAddr().String(), network.ClientOptions{}, want); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, network.DefaultBufferSize)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vls, err := network.Parse(buf[:n], network.ParseOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []api.ValueList
+	for _, vl := range vls {
+		got = append(got, *vl)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("sent and received value lists differ (+got/-want):\n%s", diff)
+	}
+}
+
+func TestServer_SecurityLevel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ch := make(chan *api.ValueList)
+	done := make(chan struct{})
+	go func() {
+		srv := &network.Server{
+			Conn: conn.(*net.UDPConn),
+			Writer: api.WriterFunc(func(_ context.Context, vl *api.ValueList) error {
+				ch <- vl
+				return nil
+			}),
+			PasswordLookup: testPasswordLookup{"admin": "admin"},
+			SecurityLevel:  network.Sign,
+		}
+
+		err := srv.ListenAndWrite(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Server.ListenAndWrite() = %v, want %v", err, context.Canceled)
+		}
+		close(done)
+	}()
+
+	for _, sl := range []network.SecurityLevel{network.None, network.Sign} {
+		vl := api.ValueList{
+			Identifier: api.Identifier{
+				Host:         "example.com",
+				Plugin:       "TestServer_SecurityLevel",
+				Type:         "gauge",
+				TypeInstance: sl.String(),
+			},
+			Time:     time.Unix(1588164686, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(42)},
+		}
+		opts := network.ClientOptions{
+			SecurityLevel: sl,
+			Username:      "admin",
+			Password:      "admin",
+		}
+		if err := network.Send(ctx, conn.LocalAddr().String(), opts, []api.ValueList{vl}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only the signed value list must be received.
+	select {
+	case vl := <-ch:
+		if got, want := vl.TypeInstance, network.Sign.String(); got != want {
+			t.Errorf("received value list with TypeInstance %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Error("timeout waiting for value list")
+	}
+
+	select {
+	case vl := <-ch:
+		t.Errorf("received unexpected value list %v", vl)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	<-done
+}
+
+func TestServer_Drain(t *testing.T) {
+	cases := []struct {
+		title        string
+		drainTimeout time.Duration
+		// wantDelivered is true if the slow write is expected to
+		// finish before its context is cancelled.
+		wantDelivered bool
+	}{
+		{"cancel", 0, false},
+		{"wait", -1, true},
+		{"wait with timeout", time.Second, true},
+		{"timeout", 50 * time.Millisecond, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			conn, err := nettest.NewLocalPacketListener("udp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			var (
+				started   = make(chan struct{})
+				delivered = make(chan *api.ValueList, 1)
+				srvDone   = make(chan error)
+			)
+			go func() {
+				srv := &network.Server{
+					Conn: conn.(*net.UDPConn),
+					Writer: api.WriterFunc(func(ctx context.Context, vl *api.ValueList) error {
+						close(started)
+						// Simulate a slow writer.
+						select {
+						case <-time.After(200 * time.Millisecond):
+							delivered <- vl
+							return nil
+						case <-ctx.Done():
+							return ctx.Err()
+						}
+					}),
+					DrainTimeout: tc.drainTimeout,
+				}
+
+				srvDone <- srv.ListenAndWrite(ctx)
+			}()
+
+			vl := api.ValueList{
+				Identifier: api.Identifier{
+					Host:   "example.com",
+					Plugin: "TestServer_Drain",
+					Type:   "gauge",
+				},
+				Time:     time.Unix(1588164686, 0),
+				Interval: 10 * time.Second,
+				Values:   []api.Value{api.Gauge(42)},
+			}
+			if err := network.Send(ctx, conn.LocalAddr().String(), network.ClientOptions{}, []api.ValueList{vl}); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-started:
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for write to start")
+			}
+
+			// Shut down the server while the write is in progress.
+			cancel()
+			if err := <-srvDone; !errors.Is(err, context.Canceled) {
+				t.Errorf("Server.ListenAndWrite() = %v, want %v", err, context.Canceled)
+			}
+
+			// ListenAndWrite must not return before the write has
+			// finished, so the value list is available immediately.
+			select {
+			case got := <-delivered:
+				if !tc.wantDelivered {
+					t.Errorf("value list delivered despite DrainTimeout")
+				}
+				if diff := cmp.Diff(vl, *got); diff != "" {
+					t.Errorf("received value list differs (+got/-want):\n%s", diff)
+				}
+			default:
+				if tc.wantDelivered {
+					t.Error("value list was not delivered before ListenAndWrite returned")
+				}
+			}
+		})
+	}
+}
+
+// TestServer_DrainBlocked verifies that a writer that blocks until its
+// context is cancelled does not prevent ListenAndWrite from returning.
+func TestServer_DrainBlocked(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var (
+		started = make(chan struct{})
+		srvDone = make(chan error)
+	)
+	go func() {
+		srv := &network.Server{
+			Conn: conn.(*net.UDPConn),
+			Writer: api.WriterFunc(func(ctx context.Context, vl *api.ValueList) error {
+				close(started)
+				<-ctx.Done()
+				return ctx.Err()
+			}),
+		}
+
+		srvDone <- srv.ListenAndWrite(ctx)
+	}()
+
+	vl := api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestServer_DrainBlocked",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+	if err := network.Send(ctx, conn.LocalAddr().String(), network.ClientOptions{}, []api.ValueList{vl}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for write to start")
+	}
+
+	cancel()
+	select {
+	case err := <-srvDone:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Server.ListenAndWrite() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Server.ListenAndWrite() did not return after its context was cancelled")
+	}
+}
+
+func TestServer_Workers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := nettest.NewLocalPacketListener("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	const (
+		workers   = 2
+		queueSize = 2
+		packets   = 50
+	)
+
+	var (
+		started  = make(chan struct{}, packets)
+		release  = make(chan struct{})
+		mu       sync.Mutex
+		received int
+	)
+	srv := &network.Server{
+		Conn: conn.(*net.UDPConn),
+		Writer: api.WriterFunc(func(_ context.Context, _ *api.ValueList) error {
+			started <- struct{}{}
+			// Block all workers until the flood is over.
+			<-release
+			mu.Lock()
+			received++
+			mu.Unlock()
+			return nil
+		}),
+		Workers:   workers,
+		QueueSize: queueSize,
+	}
+
+	baseGoroutines := runtime.NumGoroutine()
+
+	srvDone := make(chan error)
+	go func() {
+		srvDone <- srv.ListenAndWrite(ctx)
+	}()
+
+	vl := api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestServer_Workers",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+	send := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := network.Send(ctx, conn.LocalAddr().String(), network.ClientOptions{}, []api.ValueList{vl}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Wait for all workers to be blocked in the writer before flooding the
+	// server.
+	send(workers)
+	for i := 0; i < workers; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for workers to start")
+		}
+	}
+	send(packets - workers)
+
+	// All packets exceeding the workers' and the queue's capacity are
+	// dropped.
+	wantDropped := uint64(packets - workers - queueSize)
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.Dropped() < wantDropped && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := srv.Dropped(); got != wantDropped {
+		t.Errorf("Server.Dropped() = %d, want %d", got, wantDropped)
+	}
+
+	// The server itself uses one goroutine to close the connection on
+	// cancellation, plus one per worker. Allow for some slack from the
+	// runtime and the test itself.
+	if got, max := runtime.NumGoroutine()-baseGoroutines, workers+4; got > max {
+		t.Errorf("goroutine count increased by %d during flood, want at most %d", got, max)
+	}
+
+	close(release)
+	cancel()
+	if err := <-srvDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("Server.ListenAndWrite() = %v, want %v", err, context.Canceled)
+	}
+
+	if got, want := received, workers+queueSize; got != want {
+		t.Errorf("received %d value lists, want %d", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package network // import "collectd.org/network"
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParse_Notification(t *testing.T) {
+	id := api.Identifier{
+		Host:   "example.com",
+		Plugin: "TestParse",
+		Type:   "gauge",
+	}
+	tm := time.Unix(1588164686, 0)
+
+	cases := []struct {
+		title    string
+		severity api.Severity
+		message  string
+		want     []*api.Notification
+	}{
+		{
+			title:    "warning",
+			severity: api.Warning,
+			message:  "value is out of range",
+			want: []*api.Notification{
+				{
+					Identifier: id,
+					Time:       tm,
+					Severity:   api.Warning,
+					Message:    "value is out of range",
+				},
+			},
+		},
+		{
+			title:    "okay",
+			severity: api.Okay,
+			message:  "value is back to normal",
+			want: []*api.Notification{
+				{
+					Identifier: id,
+					Time:       tm,
+					Severity:   api.Okay,
+					Message:    "value is back to normal",
+				},
+			},
+		},
+		{
+			title:    "invalid severity",
+			severity: api.Severity(3),
+			message:  "ignored",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			b := &Buffer{buffer: new(bytes.Buffer), size: DefaultBufferSize}
+			if err := b.writeIdentifier(id); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.writeTime(tm); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.writeInt(typeSeverity, uint64(tc.severity)); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.writeString(typeMessage, tc.message); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []*api.Notification
+			vls, err := Parse(b.buffer.Bytes(), ParseOpts{
+				Notification: func(n *api.Notification) {
+					got = append(got, n)
+				},
+			})
+			if err != nil {
+				t.Fatalf("Parse() = %v", err)
+			}
+			if len(vls) != 0 {
+				t.Errorf("Parse() returned %d value lists, want 0", len(vls))
+			}
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("notifications differ (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"time"
 
 	"collectd.org/api"
 	"collectd.org/cdtime"
+	"collectd.org/meta"
+	"go.uber.org/multierr"
 )
 
 // ErrInvalid is returned when parsing the network data was aborted due to
@@ -25,14 +28,35 @@ type ParseOpts struct {
 	// caller. If set to "Sign", only signed and encrypted data is returned
 	// by Parse(), if set to "Encrypt", only encrypted data is returned.
 	SecurityLevel SecurityLevel
+	// LookupPolicy determines how signed and encrypted data is handled if
+	// the password of the sending user can't be determined. The default,
+	// FailClosed, returns an error.
+	LookupPolicy LookupPolicy
 	// TypesDB for looking up DS names and verify data source types.
 	TypesDB *api.TypesDB
+	// Notification, if not nil, is called for every notification found in
+	// the data. Notifications are subject to SecurityLevel, too. The
+	// callback is called while parsing, i.e. it may be called even if
+	// Parse() returns an error later on.
+	Notification func(n *api.Notification)
 }
 
-// Parse parses the binary network format and returns a slice of ValueLists. If
-// a parse error is encountered, all ValueLists parsed to this point are
-// returned as well as the error. Unknown "parts" are silently ignored.
+// Parse parses the binary network format and returns a slice of ValueLists.
+// Notifications are passed to opts.Notification, if set. Unknown "parts" are
+// silently ignored. Empty input is not an error; Parse returns neither value
+// lists nor an error.
+//
+// Parse returns all value lists it was able to parse together with any errors
+// encountered, combined using "go.uber.org/multierr". Errors in a part's
+// content, e.g. an invalid string or an unsupported value type, are
+// recoverable: the part is skipped and parsing continues with the next part.
+// Structural errors, i.e. an invalid or truncated part length, and failed
+// signature verification are fatal, because the remainder of the data cannot
+// be interpreted; parsing stops at that point.
 func Parse(b []byte, opts ParseOpts) ([]*api.ValueList, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
 	return parse(b, None, opts)
 }
 
@@ -45,24 +69,32 @@ func readUint16(buf *bytes.Buffer) (uint16, error) {
 }
 
 func parse(b []byte, sl SecurityLevel, opts ParseOpts) ([]*api.ValueList, error) {
-	var valueLists []*api.ValueList
+	var (
+		valueLists []*api.ValueList
+		errs       error
+	)
+
+	p := partParser{
+		sl:   sl,
+		opts: opts,
+	}
+	defer p.logDropped()
 
-	var state api.ValueList
 	buf := bytes.NewBuffer(b)
 
 	for buf.Len() > 0 {
 		partType, err := readUint16(buf)
 		if err != nil {
-			return nil, ErrInvalid
+			return valueLists, multierr.Append(errs, ErrInvalid)
 		}
 		partLengthUnsigned, err := readUint16(buf)
 		if err != nil {
-			return nil, ErrInvalid
+			return valueLists, multierr.Append(errs, ErrInvalid)
 		}
 		partLength := int(partLengthUnsigned)
 
 		if partLength < 5 || partLength-4 > buf.Len() {
-			return valueLists, fmt.Errorf("invalid length %d", partLength)
+			return valueLists, multierr.Append(errs, fmt.Errorf("invalid length %d", partLength))
 		}
 
 		// First 4 bytes were already read
@@ -70,77 +102,184 @@ func parse(b []byte, sl SecurityLevel, opts ParseOpts) ([]*api.ValueList, error)
 
 		payload := buf.Next(partLength)
 		if len(payload) != partLength {
-			return valueLists, fmt.Errorf("invalid length: want %d, got %d", partLength, len(payload))
+			return valueLists, multierr.Append(errs, fmt.Errorf("invalid length: want %d, got %d", partLength, len(payload)))
 		}
 
-		switch partType {
-		case typeHost, typePlugin, typePluginInstance, typeType, typeTypeInstance:
-			if err := parseIdentifier(partType, payload, &state); err != nil {
-				return valueLists, err
-			}
+		if partType == typeSignSHA256 {
+			// The signature covers the remainder of the packet,
+			// which is parsed by parseSignSHA256.
+			vls, err := parseSignSHA256(payload, buf.Bytes(), sl, opts)
+			valueLists = append(valueLists, vls...)
+			return valueLists, multierr.Append(errs, err)
+		}
 
-		case typeInterval, typeIntervalHR, typeTime, typeTimeHR:
-			if err := parseTime(partType, payload, &state); err != nil {
-				return valueLists, err
-			}
+		vls, err := p.part(partType, payload)
+		valueLists = append(valueLists, vls...)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("part of type %#x: %w", partType, err))
+		}
+	}
 
-		case typeValues:
-			v, err := parseValues(payload)
-			if err != nil {
-				return valueLists, err
-			}
+	return valueLists, errs
+}
 
-			vl := state
-			vl.Values = v
+// partParser holds the state required for parsing a sequence of parts.
+type partParser struct {
+	// sl is the security level of the data being parsed.
+	sl   SecurityLevel
+	opts ParseOpts
+
+	state    api.ValueList
+	severity api.Severity
+	// md and vmd hold meta data and per-value meta data for the next
+	// values part.
+	md  meta.Data
+	vmd []meta.Data
+	// dropped counts value lists below the required security level.
+	dropped int
+}
 
-			if opts.TypesDB != nil {
-				ds, ok := opts.TypesDB.DataSet(state.Type)
-				if !ok {
-					log.Printf("unable to find %q in TypesDB", state.Type)
-					continue
-				}
-
-				// convert []api.Value to []interface{}
-				ifValues := make([]interface{}, len(vl.Values))
-				for i, v := range vl.Values {
-					ifValues[i] = v
-				}
-
-				// cast all values to the correct data source type.
-				// Returns an error if the number of values is incorrect.
-				v, err := ds.Values(ifValues...)
-				if err != nil {
-					log.Printf("unable to convert metric %q, values %v according to %v in TypesDB: %v", state, ifValues, ds, err)
-					continue
-				}
-				vl.Values = v
-				vl.DSNames = ds.Names()
+// part parses a single part, except for signature parts which need access to
+// the remainder of the packet. It returns the value lists completed by this
+// part, if any.
+func (p *partParser) part(partType uint16, payload []byte) ([]*api.ValueList, error) {
+	switch partType {
+	case typeHost, typePlugin, typePluginInstance, typeType, typeTypeInstance:
+		if err := parseIdentifier(partType, payload, &p.state); err != nil {
+			return nil, err
+		}
+
+	case typeInterval, typeIntervalHR, typeTime, typeTimeHR:
+		if err := parseTime(partType, payload, &p.state); err != nil {
+			return nil, err
+		}
+
+	case typeMeta:
+		key, e, err := parseMeta(payload)
+		if err != nil {
+			return nil, err
+		}
+		if p.md == nil {
+			p.md = make(meta.Data)
+		}
+		p.md[key] = e
+
+	case typeValueMeta:
+		if len(payload) < 2 {
+			return nil, ErrInvalid
+		}
+		i := int(binary.BigEndian.Uint16(payload))
+		key, e, err := parseMeta(payload[2:])
+		if err != nil {
+			return nil, err
+		}
+		for len(p.vmd) <= i {
+			p.vmd = append(p.vmd, nil)
+		}
+		if p.vmd[i] == nil {
+			p.vmd[i] = make(meta.Data)
+		}
+		p.vmd[i][key] = e
+
+	case typeValues:
+		// Meta data only applies to this value list, even if the
+		// values can't be parsed.
+		md, vmd := p.md, p.vmd
+		p.md, p.vmd = nil, nil
+
+		v, err := parseValues(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		vl := p.state
+		vl.Values = v
+		vl.Meta = md
+
+		if vmd != nil {
+			if len(vmd) > len(v) {
+				return nil, fmt.Errorf("got meta data for value #%d, but only %d value(s)", len(vmd)-1, len(v))
 			}
+			for len(vmd) < len(v) {
+				vmd = append(vmd, nil)
+			}
+			vl.ValueMeta = vmd
+		}
 
-			if opts.SecurityLevel <= sl {
-				valueLists = append(valueLists, &vl)
+		if p.opts.TypesDB != nil {
+			ds, ok := p.opts.TypesDB.DataSet(p.state.Type)
+			if !ok {
+				log.Printf("unable to find %q in TypesDB", p.state.Type)
+				return nil, nil
 			}
 
-		case typeSignSHA256:
-			vls, err := parseSignSHA256(payload, buf.Bytes(), opts)
-			if err != nil {
-				return valueLists, err
+			// convert []api.Value to []interface{}
+			ifValues := make([]interface{}, len(vl.Values))
+			for i, v := range vl.Values {
+				ifValues[i] = v
 			}
-			valueLists = append(valueLists, vls...)
 
-		case typeEncryptAES256:
-			vls, err := parseEncryptAES256(payload, opts)
+			// cast all values to the correct data source type.
+			// Returns an error if the number of values is incorrect.
+			v, err := ds.Values(ifValues...)
 			if err != nil {
-				return valueLists, err
+				log.Printf("unable to convert metric %q, values %v according to %v in TypesDB: %v", p.state, ifValues, ds, err)
+				return nil, nil
 			}
-			valueLists = append(valueLists, vls...)
+			vl.Values = v
+			vl.DSNames = ds.Names()
+		}
 
-		default:
-			log.Printf("ignoring field of type %#x", partType)
+		if p.opts.SecurityLevel > p.sl {
+			p.dropped++
+			return nil, nil
+		}
+		return []*api.ValueList{&vl}, nil
+
+	case typeSeverity:
+		v, err := parseInt(payload)
+		if err != nil {
+			return nil, err
+		}
+		p.severity = api.Severity(v)
+
+	case typeMessage:
+		msg, err := parseString(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.severity != api.Failure && p.severity != api.Warning && p.severity != api.Okay {
+			log.Printf("ignoring notification with invalid severity %d", p.severity)
+			return nil, nil
+		}
+
+		if p.opts.Notification != nil && p.opts.SecurityLevel <= p.sl {
+			p.opts.Notification(&api.Notification{
+				Identifier: p.state.Identifier,
+				Time:       p.state.Time,
+				Severity:   p.severity,
+				Message:    msg,
+			})
 		}
+
+	case typeEncryptAES256:
+		return parseEncryptAES256(payload, p.opts)
+
+	default:
+		log.Printf("ignoring field of type %#x", partType)
 	}
 
-	return valueLists, nil
+	return nil, nil
+}
+
+// logDropped logs the number of value lists dropped due to an insufficient
+// security level, if any, and resets the counter.
+func (p *partParser) logDropped() {
+	if p.dropped > 0 {
+		log.Printf("dropped %d value list(s): security level %v, want at least %v", p.dropped, p.sl, p.opts.SecurityLevel)
+	}
+	p.dropped = 0
 }
 
 func parseIdentifier(partType uint16, payload []byte, state *api.ValueList) error {
@@ -165,12 +304,28 @@ func parseIdentifier(partType uint16, payload []byte, state *api.ValueList) erro
 	return nil
 }
 
+// maxSeconds is the largest number of seconds that can be represented by
+// time.Duration. Time and interval parts exceeding it are rejected, since
+// they can only be the result of corrupt data.
+const maxSeconds = uint64(math.MaxInt64 / int64(time.Second))
+
 func parseTime(partType uint16, payload []byte, state *api.ValueList) error {
 	v, err := parseInt(payload)
 	if err != nil {
 		return err
 	}
 
+	seconds := v
+	if partType == typeIntervalHR || partType == typeTimeHR {
+		// The upper 34 bits of cdtime.Time hold the seconds. The
+		// lower 30 bits hold the fraction, which may round up to
+		// another full second.
+		seconds = (v >> 30) + 1
+	}
+	if seconds > maxSeconds {
+		return fmt.Errorf("time value %d out of range: %w", v, ErrInvalid)
+	}
+
 	switch partType {
 	case typeInterval:
 		state.Interval = time.Duration(v) * time.Second
@@ -227,6 +382,13 @@ func parseValues(b []byte) ([]api.Value, error) {
 			}
 			values[i] = api.Counter(v)
 
+		case dsTypeAbsolute:
+			var v uint64
+			if err := binary.Read(buffer, binary.BigEndian, &v); err != nil {
+				return nil, err
+			}
+			values[i] = api.Absolute(v)
+
 		default:
 			return nil, ErrInvalid
 		}
@@ -235,9 +397,29 @@ func parseValues(b []byte) ([]api.Value, error) {
 	return values, nil
 }
 
-func parseSignSHA256(pkg, payload []byte, opts ParseOpts) ([]*api.ValueList, error) {
+func parseMeta(b []byte) (string, meta.Entry, error) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 || i+1 >= len(b) {
+		return "", meta.Entry{}, ErrInvalid
+	}
+
+	e, err := meta.DecodeEntry(b[i+1], b[i+2:])
+	if err != nil {
+		return "", meta.Entry{}, ErrInvalid
+	}
+	return string(b[:i]), e, nil
+}
+
+// parseSignSHA256 verifies the signature in pkg and parses payload. sl is the
+// security level of the enclosing data, which is used when the signature is
+// ignored due to LookupPolicy.
+func parseSignSHA256(pkg, payload []byte, sl SecurityLevel, opts ParseOpts) ([]*api.ValueList, error) {
 	ok, err := verifySHA256(pkg, payload, opts.PasswordLookup)
 	if err != nil {
+		var lerr lookupError
+		if errors.As(err, &lerr) && opts.LookupPolicy == FallThrough {
+			return parse(payload, sl, opts)
+		}
 		return nil, err
 	} else if !ok {
 		return nil, errors.New("SHA256 verification failure")
@@ -249,6 +431,13 @@ func parseSignSHA256(pkg, payload []byte, opts ParseOpts) ([]*api.ValueList, err
 func parseEncryptAES256(payload []byte, opts ParseOpts) ([]*api.ValueList, error) {
 	plaintext, err := decryptAES256(payload, opts.PasswordLookup)
 	if err != nil {
+		var lerr lookupError
+		if errors.As(err, &lerr) {
+			if opts.LookupPolicy == FallThrough {
+				return nil, nil
+			}
+			return nil, err
+		}
 		return nil, errors.New("AES256 decryption failure")
 	}
 
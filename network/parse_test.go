@@ -3,14 +3,22 @@ package network // import "collectd.org/network"
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"collectd.org/api"
+	"collectd.org/cdtime"
+	"collectd.org/meta"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestParse(t *testing.T) {
@@ -87,6 +95,97 @@ func TestParseString(t *testing.T) {
 
 }
 
+func TestParseTime(t *testing.T) {
+	cases := []struct {
+		title    string
+		partType uint16
+		value    uint64
+		want     api.ValueList
+		wantErr  bool
+	}{
+		{
+			title:    "time",
+			partType: typeTime,
+			value:    1588164686,
+			want:     api.ValueList{Time: time.Unix(1588164686, 0)},
+		},
+		{
+			title:    "time maximum",
+			partType: typeTime,
+			value:    maxSeconds,
+			want:     api.ValueList{Time: time.Unix(int64(maxSeconds), 0)},
+		},
+		{
+			title:    "time overflow",
+			partType: typeTime,
+			value:    maxSeconds + 1,
+			wantErr:  true,
+		},
+		{
+			title:    "time negative",
+			partType: typeTime,
+			value:    math.MaxUint64,
+			wantErr:  true,
+		},
+		{
+			title:    "high resolution time",
+			partType: typeTimeHR,
+			value:    uint64(cdtime.New(time.Unix(1588164686, 0))),
+			want:     api.ValueList{Time: time.Unix(1588164686, 0)},
+		},
+		{
+			title:    "high resolution time overflow",
+			partType: typeTimeHR,
+			value:    math.MaxUint64,
+			wantErr:  true,
+		},
+		{
+			title:    "interval",
+			partType: typeInterval,
+			value:    10,
+			want:     api.ValueList{Interval: 10 * time.Second},
+		},
+		{
+			title:    "interval overflow",
+			partType: typeInterval,
+			value:    maxSeconds + 1,
+			wantErr:  true,
+		},
+		{
+			title:    "high resolution interval",
+			partType: typeIntervalHR,
+			value:    uint64(cdtime.NewDuration(10 * time.Second)),
+			want:     api.ValueList{Interval: 10 * time.Second},
+		},
+		{
+			title:    "high resolution interval overflow",
+			partType: typeIntervalHR,
+			value:    maxSeconds << 30,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			payload := make([]byte, 8)
+			binary.BigEndian.PutUint64(payload, tc.value)
+
+			var got api.ValueList
+			err := parseTime(tc.partType, payload, &got)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseTime() = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if !got.Time.Equal(tc.want.Time) || got.Interval != tc.want.Interval {
+				t.Errorf("parseTime() = (%v, %v), want (%v, %v)", got.Time, got.Interval, tc.want.Time, tc.want.Interval)
+			}
+		})
+	}
+}
+
 func TestRoundtrip(t *testing.T) {
 	for _, file := range []string{"testdata/packet1.bin", "testdata/packet2.bin"} {
 		testRoundTrip(t, file)
@@ -119,6 +218,217 @@ func testRoundTrip(t *testing.T, file string) {
 	}
 }
 
+func TestRoundtrip_Meta(t *testing.T) {
+	ctx := context.Background()
+
+	want := []*api.ValueList{
+		{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "TestRoundtrip",
+				Type:   "gauge",
+			},
+			Time:     time.Unix(1588164686, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(42)},
+			Meta: meta.Data{
+				"string":   meta.String("foo"),
+				"empty":    meta.String(""),
+				"signed":   meta.Int64(-23),
+				"unsigned": meta.UInt64(math.MaxUint64),
+				"double":   meta.Float64(0.5),
+				"true":     meta.Bool(true),
+				"false":    meta.Bool(false),
+			},
+		},
+		// Meta data must not carry over to the next value list.
+		{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "TestRoundtrip",
+				Type:   "gauge",
+			},
+			Time:     time.Unix(1588164696, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(43)},
+		},
+	}
+
+	b := NewBuffer(0)
+	b.SendMeta()
+	for _, vl := range want {
+		if err := b.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Parse(data, ParseOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(meta.Entry{})); diff != "" {
+		t.Errorf("value lists differ (+got/-want):\n%s", diff)
+	}
+}
+
+func TestRoundtrip_ValueMeta(t *testing.T) {
+	ctx := context.Background()
+
+	want := []*api.ValueList{
+		{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "TestRoundtrip",
+				Type:   "if_octets",
+			},
+			Time:     time.Unix(1588164686, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Derive(1), api.Derive(2), api.Derive(3)},
+			Meta: meta.Data{
+				"list": meta.String("foo"),
+			},
+			ValueMeta: []meta.Data{
+				{"unit": meta.String("bytes"), "scale": meta.Float64(8)},
+				nil,
+				{"unit": meta.String("packets")},
+			},
+		},
+		// Per-value meta data must not carry over to the next value list.
+		{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "TestRoundtrip",
+				Type:   "gauge",
+			},
+			Time:     time.Unix(1588164696, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(43)},
+		},
+	}
+
+	b := NewBuffer(0)
+	b.SendMeta()
+	for _, vl := range want {
+		if err := b.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Parse(data, ParseOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(meta.Entry{})); diff != "" {
+		t.Errorf("value lists differ (+got/-want):\n%s", diff)
+	}
+}
+
+func TestBuffer_MetaDisabled(t *testing.T) {
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestBuffer",
+			Type:   "gauge",
+		},
+		Time:      time.Unix(1588164686, 0),
+		Interval:  10 * time.Second,
+		Values:    []api.Value{api.Gauge(42)},
+		Meta:      meta.Data{"key": meta.String("value")},
+		ValueMeta: []meta.Data{{"unit": meta.String("bytes")}},
+	}
+
+	withMeta := NewBuffer(0)
+	withMeta.SendMeta()
+	withoutMeta := NewBuffer(0)
+	for _, b := range []*Buffer{withMeta, withoutMeta} {
+		if err := b.Write(context.Background(), vl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without SendMeta, the non-standard meta data parts are omitted.
+	if got, want := withoutMeta.Used(), withMeta.Used(); got >= want {
+		t.Errorf("Used() = %d, want less than %d", got, want)
+	}
+
+	data, err := withoutMeta.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(data, ParseOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Meta != nil || got[0].ValueMeta != nil {
+		t.Errorf("Parse() = %v, want a single value list without meta data", got)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	// Four values, one of each data source type, in wire order: counter,
+	// gauge, derive, absolute.
+	payload := []byte{
+		0x00, 0x04,
+		dsTypeCounter, dsTypeGauge, dsTypeDerive, dsTypeAbsolute,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a, // 42
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f, // 1.5 (little endian)
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // -1
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x17, // 23
+	}
+
+	got, err := parseValues(payload)
+	if err != nil {
+		t.Fatalf("parseValues() = %v", err)
+	}
+
+	want := []api.Value{
+		api.Counter(42),
+		api.Gauge(1.5),
+		api.Derive(-1),
+		api.Absolute(23),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseValues() differs (+got/-want):\n%s", diff)
+	}
+
+	// cmp.Diff compares interface values including their dynamic type,
+	// but be explicit about the types mattering here.
+	for i := range want {
+		if gotType, wantType := fmt.Sprintf("%T", got[i]), fmt.Sprintf("%T", want[i]); gotType != wantType {
+			t.Errorf("value #%d has type %s, want %s", i, gotType, wantType)
+		}
+	}
+}
+
+func TestParseMeta_Invalid(t *testing.T) {
+	for _, payload := range [][]byte{
+		{},
+		{'k', 'e', 'y'},
+		{'k', 'e', 'y', 0},
+		{'k', 'e', 'y', 0, meta.TypeString},
+		{'k', 'e', 'y', 0, meta.TypeString, 'f', 'o', 'o'},
+		{'k', 'e', 'y', 0, meta.TypeInt64, 0, 0, 0, 0},
+		{'k', 'e', 'y', 0, meta.TypeBool},
+		{'k', 'e', 'y', 0, 42, 0},
+	} {
+		if key, e, err := parseMeta(payload); err == nil {
+			t.Errorf("parseMeta(%v) = (%q, %v, nil), want error", payload, key, e)
+		}
+	}
+}
+
 func TestOneByte(t *testing.T) {
 	_, err := Parse([]byte{0}, ParseOpts{})
 	if err == nil {
@@ -126,6 +436,173 @@ func TestOneByte(t *testing.T) {
 	}
 }
 
+func TestParse_Empty(t *testing.T) {
+	for _, b := range [][]byte{nil, {}} {
+		vls, err := Parse(b, ParseOpts{})
+		if err != nil || vls != nil {
+			t.Errorf("Parse(%#v) = (%v, %v), want (nil, nil)", b, vls, err)
+		}
+	}
+}
+
+func TestParse_RecoverableError(t *testing.T) {
+	ctx := context.Background()
+
+	vl1 := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestParse_RecoverableError",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(1)},
+	}
+	vl2 := vl1.Clone()
+	vl2.TypeInstance = "second"
+	vl2.Values = []api.Value{api.Gauge(2)}
+
+	var packet []byte
+	for _, vl := range []*api.ValueList{vl1, vl2} {
+		b := NewBuffer(0)
+		if err := b.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+		data, err := b.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet = append(packet, data...)
+
+		if vl == vl1 {
+			// A host part with a string that is not null
+			// terminated.
+			packet = append(packet, 0x00, 0x00, 0x00, 0x07, 'f', 'o', 'o')
+		}
+	}
+
+	want := []*api.ValueList{vl1, vl2}
+
+	t.Run("Parse", func(t *testing.T) {
+		got, err := Parse(packet, ParseOpts{})
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse() = %v, want %v", err, ErrInvalid)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Parse() differs (+got/-want):\n%s", diff)
+		}
+	})
+
+	t.Run("Decoder", func(t *testing.T) {
+		d := NewDecoder(bytes.NewReader(packet))
+
+		var (
+			got  []*api.ValueList
+			errs []error
+		)
+		for {
+			vl, err := d.Decode()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			got = append(got, vl)
+		}
+
+		if len(errs) != 1 || !errors.Is(errs[0], ErrInvalid) {
+			t.Errorf("Decode() errors = %v, want [%v]", errs, ErrInvalid)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Decode() differs (+got/-want):\n%s", diff)
+		}
+	})
+}
+
+func TestParse_MetaAfterInvalidValues(t *testing.T) {
+	ctx := context.Background()
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestParse_MetaAfterInvalidValues",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+		Meta:     meta.Data{"key": meta.String("value")},
+	}
+
+	b := NewBuffer(0)
+	b.SendMeta()
+	if err := b.Write(ctx, vl); err != nil {
+		t.Fatal(err)
+	}
+	data, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The values part of a single value is the last 15 bytes. Insert a
+	// values part claiming one value, but lacking its data, in front of it.
+	const valuesPartLen = 15
+	var packet []byte
+	packet = append(packet, data[:len(data)-valuesPartLen]...)
+	packet = append(packet, 0x00, 0x06, 0x00, 0x07, 0x00, 0x01, 0x01)
+	packet = append(packet, data[len(data)-valuesPartLen:]...)
+
+	want := vl.Clone()
+	want.Meta = nil
+
+	got, err := Parse(packet, ParseOpts{})
+	if err == nil {
+		t.Error("Parse() succeeded, want error")
+	}
+	if diff := cmp.Diff([]*api.ValueList{want}, got); diff != "" {
+		t.Errorf("Parse() differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestParse_FatalError(t *testing.T) {
+	ctx := context.Background()
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestParse_FatalError",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(1)},
+	}
+
+	b := NewBuffer(0)
+	if err := b.Write(ctx, vl); err != nil {
+		t.Fatal(err)
+	}
+	data, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A part claiming to be longer than the remaining data, followed by a
+	// valid value list that must not be returned.
+	packet := append(append([]byte{}, data...), 0x00, 0x00, 0x01, 0x00)
+	packet = append(packet, data...)
+
+	got, err := Parse(packet, ParseOpts{})
+	if err == nil {
+		t.Error("Parse() = nil, want error")
+	}
+	if diff := cmp.Diff([]*api.ValueList{vl}, got); diff != "" {
+		t.Errorf("Parse() differs (+got/-want):\n%s", diff)
+	}
+}
+
 func TestParseOpts_TypesDB(t *testing.T) {
 	ctx := context.Background()
 
@@ -160,6 +637,12 @@ func TestParseOpts_TypesDB(t *testing.T) {
 			WantValues:  []api.Value{api.Gauge(42.0)},
 			WantDSNames: []string{"value"},
 		},
+		{ // ABSOLUTE, successful
+			Type:        "absolute",
+			Values:      []api.Value{api.Absolute(42)},
+			WantValues:  []api.Value{api.Absolute(42)},
+			WantDSNames: []string{"value"},
+		},
 		{ // two data sources
 			Type:        "if_octets",
 			Values:      []api.Value{api.Derive(1), api.Derive(2)},
@@ -185,6 +668,7 @@ func TestParseOpts_TypesDB(t *testing.T) {
 	}
 
 	typesDB, err := api.NewTypesDB(strings.NewReader(`
+absolute	value:ABSOLUTE:0:U
 derive		value:DERIVE:0:U
 gauge		value:GAUGE:0:U
 if_octets	rx:DERIVE:0:U, tx:DERIVE:0:U
@@ -2,9 +2,12 @@ package network // import "collectd.org/network"
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"collectd.org/api"
 )
@@ -30,20 +33,55 @@ type Server struct {
 	Conn *net.UDPConn
 	// Address to listen on if Conn is nil. If Addr is empty, too, then the
 	// "any" interface and the DefaultService will be used.
-	Addr           string
-	Writer         api.Writer     // Object used to send incoming ValueLists to.
+	Addr string
+	// Writer is used to send incoming ValueLists to. If Writer is nil,
+	// received packets are parsed and verified, but not dispatched. This is
+	// useful for validating packets in combination with ParseError.
+	Writer         api.Writer
 	BufferSize     uint16         // Maximum packet size to accept.
 	PasswordLookup PasswordLookup // User to password lookup.
 	SecurityLevel  SecurityLevel  // Minimal required security level.
+	LookupPolicy   LookupPolicy   // Handling of data from unknown users.
 	TypesDB        *api.TypesDB   // TypesDB for looking up DS names and verify data source types.
 	// Interface is the name of the interface to use when subscribing to a
-	// multicast group. Has no effect when using unicast.
+	// multicast group. Has no effect when using unicast. For IPv6, the
+	// interface may also be specified as the address' zone, e.g.
+	// "[ff02::1%eth0]:25826". Link-local multicast groups require an
+	// interface.
 	Interface string
+	// ParseError, if not nil, is called for every packet that could not be
+	// parsed, including packets failing signature verification or
+	// decryption. Value lists parsed successfully from such a packet are
+	// still written to Writer. If ParseError is nil, parse errors are
+	// logged.
+	ParseError func(packet []byte, err error)
+	// Notification, if not nil, is called for every notification received.
+	// If Notification is nil, notifications are ignored.
+	Notification func(n *api.Notification)
+	// DrainTimeout is the maximum time ListenAndWrite waits for in-flight
+	// writes to finish after its context has been cancelled. When it
+	// elapses, the context passed to Writer is cancelled and
+	// ListenAndWrite returns once the writes have returned. If
+	// DrainTimeout is zero, the context passed to Writer is cancelled
+	// immediately. If DrainTimeout is negative, ListenAndWrite waits until
+	// all writes have finished, no matter how long that takes.
+	DrainTimeout time.Duration
+	// Workers is the number of goroutines writing received value lists to
+	// Writer. If Workers is zero, a new goroutine is started for every
+	// received packet.
+	Workers int
+	// QueueSize is the number of parsed packets buffered for the workers.
+	// When the queue is full, packets are dropped and counted, see Dropped.
+	// If QueueSize is zero, Workers is used. Has no effect if Workers is
+	// zero.
+	QueueSize int
+
+	dropped atomic.Uint64
 }
 
 // ListenAndWrite listens on the provided UDP connection (or creates one using
 // Addr if Conn is nil), parses the received packets and writes them to the
-// provided api.Writer.
+// provided api.Writer. Empty packets are ignored.
 func (srv *Server) ListenAndWrite(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -61,10 +99,8 @@ func (srv *Server) ListenAndWrite(ctx context.Context) error {
 
 		if laddr.IP != nil && laddr.IP.IsMulticast() {
 			var ifi *net.Interface
-			if srv.Interface != "" {
-				if ifi, err = net.InterfaceByName(srv.Interface); err != nil {
-					return err
-				}
+			if ifi, err = multicastInterface(laddr, srv.Interface); err != nil {
+				return err
 			}
 			srv.Conn, err = net.ListenMulticastUDP("udp", ifi, laddr)
 		} else {
@@ -82,7 +118,9 @@ func (srv *Server) ListenAndWrite(ctx context.Context) error {
 	popts := ParseOpts{
 		PasswordLookup: srv.PasswordLookup,
 		SecurityLevel:  srv.SecurityLevel,
+		LookupPolicy:   srv.LookupPolicy,
 		TypesDB:        srv.TypesDB,
+		Notification:   srv.Notification,
 	}
 
 	go func() {
@@ -93,33 +131,184 @@ func (srv *Server) ListenAndWrite(ctx context.Context) error {
 		}
 	}()
 
-	var wg sync.WaitGroup
+	// Writes are not cancelled together with ctx, so that value lists
+	// received before shutdown are still delivered. See DrainTimeout.
+	dispatchCtx, cancelDispatch := context.WithCancel(detachedContext{ctx})
+	defer cancelDispatch()
+
+	var (
+		wg    sync.WaitGroup
+		queue chan []*api.ValueList
+	)
+	if srv.Workers > 0 && srv.Writer != nil {
+		size := srv.QueueSize
+		if size <= 0 {
+			size = srv.Workers
+		}
+		queue = make(chan []*api.ValueList, size)
+
+		for i := 0; i < srv.Workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for valueLists := range queue {
+					dispatch(dispatchCtx, valueLists, srv.Writer)
+				}
+			}()
+		}
+	}
+
 	for {
 		buf := make([]byte, srv.BufferSize)
 		n, err := srv.Conn.Read(buf)
 		if err != nil {
 			srv.Conn.Close()
-			wg.Wait()
+			if queue != nil {
+				close(queue)
+			}
+			srv.drain(&wg, cancelDispatch)
 			if ctx.Err() != nil {
 				return ctx.Err()
 			}
 			return err
 		}
 
+		// Zero-length datagrams are valid UDP, but carry no data.
+		// Ignore them rather than reporting a parse error.
+		if n == 0 {
+			continue
+		}
+
 		valueLists, err := Parse(buf[:n], popts)
 		if err != nil {
-			log.Printf("error while parsing: %v", err)
+			srv.parseError(buf[:n], err)
+		}
+
+		if srv.Writer == nil || len(valueLists) == 0 {
+			continue
+		}
+
+		if queue != nil {
+			select {
+			case queue <- valueLists:
+			default:
+				srv.dropped.Add(1)
+			}
 			continue
 		}
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			dispatch(ctx, valueLists, srv.Writer)
+			dispatch(dispatchCtx, valueLists, srv.Writer)
 		}()
 	}
 }
 
+// Dropped returns the number of packets dropped because the worker queue was
+// full. See Workers and QueueSize.
+func (srv *Server) Dropped() uint64 {
+	return srv.dropped.Load()
+}
+
+// multicastInterface returns the interface to use for joining the multicast
+// group laddr. The interface is either given by name or, for IPv6, as the
+// address' zone. If neither is set, nil is returned and the system chooses an
+// interface. An error is returned if the interface does not exist, does not
+// support multicast or has no address of laddr's address family.
+func multicastInterface(laddr *net.UDPAddr, name string) (*net.Interface, error) {
+	if laddr.Zone != "" {
+		if name != "" && name != laddr.Zone {
+			return nil, fmt.Errorf("interface %q conflicts with zone of address %v", name, laddr)
+		}
+		name = laddr.Zone
+	}
+
+	if name == "" {
+		if laddr.IP.IsLinkLocalMulticast() && laddr.IP.To4() == nil {
+			return nil, fmt.Errorf("link-local multicast group %v requires an interface", laddr.IP)
+		}
+		return nil, nil
+	}
+
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("multicast interface %q: %w", name, err)
+	}
+	if ifi.Flags&net.FlagMulticast == 0 {
+		return nil, fmt.Errorf("interface %q does not support multicast", name)
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("multicast interface %q: %w", name, err)
+	}
+	wantIPv4 := laddr.IP.To4() != nil
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if ok && (ipnet.IP.To4() != nil) == wantIPv4 {
+			return ifi, nil
+		}
+	}
+
+	family := "IPv6"
+	if wantIPv4 {
+		family = "IPv4"
+	}
+	return nil, fmt.Errorf("interface %q has no %s address, required for multicast group %v", name, family, laddr.IP)
+}
+
+// drain waits for all dispatch goroutines tracked by wg to finish. If
+// DrainTimeout elapses first, cancel is called and drain continues to wait.
+func (srv *Server) drain(wg *sync.WaitGroup, cancel context.CancelFunc) {
+	if srv.DrainTimeout == 0 {
+		cancel()
+		wg.Wait()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	if srv.DrainTimeout < 0 {
+		<-done
+		return
+	}
+
+	timer := time.NewTimer(srv.DrainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		cancel()
+		<-done
+	}
+}
+
+// detachedContext is a context that carries the values of its parent but is
+// never cancelled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+func (srv *Server) parseError(packet []byte, err error) {
+	if srv.ParseError == nil {
+		log.Printf("error while parsing: %v", err)
+		return
+	}
+
+	srv.ParseError(packet, err)
+}
+
 func dispatch(ctx context.Context, valueLists []*api.ValueList, d api.Writer) {
 	for _, vl := range valueLists {
 		if err := d.Write(ctx, vl); err != nil {
@@ -5,27 +5,11 @@ import (
 	"errors"
 	"log"
 	"net"
-	"os"
 	"sync"
 	"testing"
 	"time"
-
-	"collectd.org/format"
 )
 
-// This example demonstrates how to listen to encrypted network traffic and
-// dump it to STDOUT using format.Putval.
-func ExampleServer_ListenAndWrite() {
-	srv := &Server{
-		Addr:           net.JoinHostPort("::", DefaultService),
-		Writer:         format.NewPutval(os.Stdout),
-		PasswordLookup: NewAuthFile("/etc/collectd/users"),
-	}
-
-	// blocks
-	log.Fatal(srv.ListenAndWrite(context.Background()))
-}
-
 // This example demonstrates how to forward received IPv6 multicast traffic to
 // a unicast address, using PSK encryption.
 func ExampleListenAndWrite() {
@@ -69,3 +53,120 @@ func TestServer_Cancellation(t *testing.T) {
 		t.Errorf("srvErr = %v, want %v", srvErr, context.Canceled)
 	}
 }
+
+func TestMulticastInterface(t *testing.T) {
+	// Find an interface that supports IPv6 multicast, if any.
+	var ipv6Iface string
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() == nil {
+				ipv6Iface = ifi.Name
+			}
+		}
+		if ipv6Iface != "" {
+			break
+		}
+	}
+
+	cases := []struct {
+		title     string
+		addr      string
+		iface     string
+		wantIface string
+		wantErr   bool
+		needIface bool
+	}{
+		{
+			title: "default IPv6 address without interface",
+			addr:  net.JoinHostPort(DefaultIPv6Address, DefaultService),
+		},
+		{
+			title:     "default IPv6 address with interface",
+			addr:      net.JoinHostPort(DefaultIPv6Address, DefaultService),
+			iface:     ipv6Iface,
+			wantIface: ipv6Iface,
+			needIface: true,
+		},
+		{
+			title:     "zone",
+			addr:      net.JoinHostPort("ff02::1%"+ipv6Iface, DefaultService),
+			wantIface: ipv6Iface,
+			needIface: true,
+		},
+		{
+			title:     "zone and matching interface",
+			addr:      net.JoinHostPort("ff02::1%"+ipv6Iface, DefaultService),
+			iface:     ipv6Iface,
+			wantIface: ipv6Iface,
+			needIface: true,
+		},
+		{
+			title:   "zone and conflicting interface",
+			addr:    net.JoinHostPort("ff02::1%eth0", DefaultService),
+			iface:   "eth1",
+			wantErr: true,
+		},
+		{
+			title:   "link-local without interface",
+			addr:    net.JoinHostPort("ff02::1", DefaultService),
+			wantErr: true,
+		},
+		{
+			title:   "unknown interface",
+			addr:    net.JoinHostPort(DefaultIPv6Address, DefaultService),
+			iface:   "does-not-exist0",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			if tc.needIface && ipv6Iface == "" {
+				t.Skip("no interface supporting IPv6 multicast found")
+			}
+
+			laddr, err := net.ResolveUDPAddr("udp", tc.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ifi, err := multicastInterface(laddr, tc.iface)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("multicastInterface(%v, %q) = (%v, %v), want error %v", laddr, tc.iface, ifi, err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			var gotIface string
+			if ifi != nil {
+				gotIface = ifi.Name
+			}
+			if gotIface != tc.wantIface {
+				t.Errorf("multicastInterface(%v, %q) = %q, want %q", laddr, tc.iface, gotIface, tc.wantIface)
+			}
+		})
+	}
+}
+
+func TestServer_MulticastInterfaceError(t *testing.T) {
+	srv := &Server{
+		Addr:      net.JoinHostPort(DefaultIPv6Address, DefaultService),
+		Interface: "does-not-exist0",
+	}
+
+	if err := srv.ListenAndWrite(context.Background()); err == nil {
+		t.Error("ListenAndWrite() = nil, want error")
+	}
+}
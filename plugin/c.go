@@ -5,6 +5,7 @@ package plugin // import "collectd.org/plugin"
 // #cgo CPPFLAGS: -DHAVE_CONFIG_H
 // #cgo LDFLAGS: -ldl
 // #include "plugin.h"
+// #include <stdbool.h>
 // #include <stdlib.h>
 // #include <dlfcn.h>
 //
@@ -51,6 +52,23 @@ package plugin // import "collectd.org/plugin"
 //   return vl->values[i].derive;
 // }
 //
+// /* work-around because CGo has trouble accessing unions. */
+// char const *notification_meta_get_string(notification_meta_t const *m) {
+//   return m->nm_value.nm_string;
+// }
+// int64_t notification_meta_get_signed_int(notification_meta_t const *m) {
+//   return m->nm_value.nm_signed_int;
+// }
+// uint64_t notification_meta_get_unsigned_int(notification_meta_t const *m) {
+//   return m->nm_value.nm_unsigned_int;
+// }
+// double notification_meta_get_double(notification_meta_t const *m) {
+//   return m->nm_value.nm_double;
+// }
+// bool notification_meta_get_boolean(notification_meta_t const *m) {
+//   return m->nm_value.nm_boolean;
+// }
+//
 // static int *timeout_ptr;
 // int timeout_wrapper(void) {
 //   if (timeout_ptr == NULL) {
@@ -61,6 +79,20 @@ package plugin // import "collectd.org/plugin"
 //   return *timeout_ptr;
 // }
 //
+// /* hostname_g is declared as "char *hostname_g" since collectd 5.9. */
+// static char **hostname_ptr;
+// char *hostname_wrapper(void) {
+//   if (hostname_ptr == NULL) {
+//     void *hnd = dlopen(NULL, RTLD_LAZY);
+//     hostname_ptr = dlsym(hnd, "hostname_g");
+//     dlclose(hnd);
+//   }
+//   if (hostname_ptr == NULL) {
+//     return NULL;
+//   }
+//   return *hostname_ptr;
+// }
+//
 // typedef int (*plugin_complex_config_cb)(oconfig_item_t *);
 //
 // static int (*register_complex_config_ptr) (const char *, plugin_complex_config_cb);
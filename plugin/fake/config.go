@@ -0,0 +1,150 @@
+package fake
+
+// #cgo CPPFLAGS: -DHAVE_CONFIG_H
+// #include <stdbool.h>
+// #include <stdlib.h>
+// #include <string.h>
+// #include "plugin.h"
+//
+// typedef struct {
+//   char *name;
+//   int (*callback)(oconfig_item_t *);
+// } config_callback_t;
+// static config_callback_t *config_callbacks = NULL;
+// static size_t config_callbacks_num = 0;
+//
+// int plugin_register_complex_config(const char *name,
+//                                    int (*callback)(oconfig_item_t *)) {
+//   config_callback_t *ptr =
+//       realloc(config_callbacks,
+//               (config_callbacks_num + 1) * sizeof(*config_callbacks));
+//   if (ptr == NULL) {
+//     return ENOMEM;
+//   }
+//   config_callbacks = ptr;
+//   config_callbacks[config_callbacks_num] = (config_callback_t){
+//       .name = strdup(name),
+//       .callback = callback,
+//   };
+//   config_callbacks_num++;
+//
+//   return 0;
+// }
+//
+// int plugin_configure(const char *name, oconfig_item_t *ci) {
+//   for (size_t i = 0; i < config_callbacks_num; i++) {
+//     if (strcasecmp(name, config_callbacks[i].name) == 0) {
+//       return config_callbacks[i].callback(ci);
+//     }
+//   }
+//   return ENOENT;
+// }
+//
+// void reset_config(void) {
+//   for (size_t i = 0; i < config_callbacks_num; i++) {
+//     free(config_callbacks[i].name);
+//   }
+//   free(config_callbacks);
+//   config_callbacks = NULL;
+//   config_callbacks_num = 0;
+// }
+//
+// /* helpers for building oconfig_item_t trees, because CGo has trouble
+//  * accessing unions and fields named "type". */
+// static oconfig_item_t *config_item_at(oconfig_item_t *items, int i) {
+//   return items + i;
+// }
+// static void config_set_string(oconfig_value_t *values, int i, char *s) {
+//   values[i].type = OCONFIG_TYPE_STRING;
+//   values[i].value.string = s;
+// }
+// static void config_set_number(oconfig_value_t *values, int i, double n) {
+//   values[i].type = OCONFIG_TYPE_NUMBER;
+//   values[i].value.number = n;
+// }
+// static void config_set_boolean(oconfig_value_t *values, int i, bool b) {
+//   values[i].type = OCONFIG_TYPE_BOOLEAN;
+//   values[i].value.boolean = b;
+// }
+//
+// static void config_item_free(oconfig_item_t *ci) {
+//   free(ci->key);
+//   for (int i = 0; i < ci->values_num; i++) {
+//     if (ci->values[i].type == OCONFIG_TYPE_STRING) {
+//       free(ci->values[i].value.string);
+//     }
+//   }
+//   free(ci->values);
+//   for (int i = 0; i < ci->children_num; i++) {
+//     config_item_free(ci->children + i);
+//   }
+//   free(ci->children);
+// }
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"collectd.org/config"
+)
+
+// Configure passes block to the config callback registered under name, like
+// the daemon does for each "<Plugin name>" block.
+func Configure(name string, block config.Block) error {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	ci := (*C.oconfig_item_t)(C.calloc(1, C.sizeof_oconfig_item_t))
+	defer C.free(unsafe.Pointer(ci))
+	defer C.config_item_free(ci)
+
+	if err := marshalConfigBlock(ci, nil, block); err != nil {
+		return err
+	}
+
+	status, err := C.plugin_configure(cName, ci)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("plugin_configure(%q) = %d", name, status)
+	}
+
+	return nil
+}
+
+// marshalConfigBlock fills the zero-valued ci with the contents of block.
+func marshalConfigBlock(ci, parent *C.oconfig_item_t, block config.Block) error {
+	ci.key = C.CString(block.Key)
+	ci.parent = parent
+
+	if n := len(block.Values); n > 0 {
+		ci.values = (*C.oconfig_value_t)(C.calloc(C.size_t(n), C.sizeof_oconfig_value_t))
+		ci.values_num = C.int(n)
+	}
+	for i, v := range block.Values {
+		switch v := v.Interface().(type) {
+		case string:
+			C.config_set_string(ci.values, C.int(i), C.CString(v))
+		case float64:
+			C.config_set_number(ci.values, C.int(i), C.double(v))
+		case bool:
+			C.config_set_boolean(ci.values, C.int(i), C.bool(v))
+		default:
+			return fmt.Errorf("unexpected config value type %T", v)
+		}
+	}
+
+	if n := len(block.Children); n > 0 {
+		ci.children = (*C.oconfig_item_t)(C.calloc(C.size_t(n), C.sizeof_oconfig_item_t))
+		ci.children_num = C.int(n)
+	}
+	for i, child := range block.Children {
+		if err := marshalConfigBlock(C.config_item_at(ci.children, C.int(i)), ci, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,7 +2,15 @@
 // collectd daemon for testing.
 package fake
 
+// /* The plugin package looks up the daemon's symbols using dlsym(), so the
+//  * fake implementations must be exported from the test binary. */
+// #cgo LDFLAGS: -rdynamic
+//
+// void reset_config(void);
+// void reset_flush(void);
+// void reset_init(void);
 // void reset_log(void);
+// void reset_notification(void);
 // void reset_read(void);
 // void reset_shutdown(void);
 // void reset_write(void);
@@ -22,7 +30,12 @@ import (
 // a reference to the callback even after this function has been called.
 func TearDown() {
 	SetInterval(10 * time.Second)
+	SetHostname("")
+	C.reset_config()
+	C.reset_flush()
+	C.reset_init()
 	C.reset_log()
+	C.reset_notification()
 	C.reset_read()
 	C.reset_shutdown()
 	C.reset_write()
@@ -0,0 +1,92 @@
+package fake
+
+// #cgo CPPFLAGS: -DHAVE_CONFIG_H
+// #cgo LDFLAGS: -ldl
+// #include <stdlib.h>
+// #include <string.h>
+// #include "plugin.h"
+//
+// typedef struct {
+//   const char *name;
+//   plugin_flush_cb callback;
+//   user_data_t user_data;
+// } flush_callback_t;
+// static flush_callback_t *flush_callbacks = NULL;
+// static size_t flush_callbacks_num = 0;
+//
+// int plugin_register_flush(const char *name, plugin_flush_cb callback,
+//                           user_data_t const *user_data) {
+//   flush_callback_t *ptr = realloc(
+//       flush_callbacks, (flush_callbacks_num + 1) * sizeof(*flush_callbacks));
+//   if (ptr == NULL) {
+//     return ENOMEM;
+//   }
+//   flush_callbacks = ptr;
+//   flush_callbacks[flush_callbacks_num] = (flush_callback_t){
+//       .name = name,
+//       .callback = callback,
+//       .user_data = *user_data,
+//   };
+//   flush_callbacks_num++;
+//
+//   return 0;
+// }
+//
+// int plugin_flush(const char *plugin, cdtime_t timeout,
+//                  const char *identifier) {
+//   int ret = 0;
+//   for (size_t i = 0; i < flush_callbacks_num; i++) {
+//     flush_callback_t *cb = flush_callbacks + i;
+//     if ((plugin != NULL) && (strcmp(plugin, cb->name) != 0)) {
+//       continue;
+//     }
+//     int err = cb->callback(timeout, identifier, &cb->user_data);
+//     if (err != 0) {
+//       ret = err;
+//     }
+//   }
+//   return ret;
+// }
+//
+// void reset_flush(void) {
+//   for (size_t i = 0; i < flush_callbacks_num; i++) {
+//     user_data_t *ud = &flush_callbacks[i].user_data;
+//     if (ud->free_func == NULL) {
+//       continue;
+//     }
+//     ud->free_func(ud->data);
+//     ud->data = NULL;
+//   }
+//   free(flush_callbacks);
+//   flush_callbacks = NULL;
+//   flush_callbacks_num = 0;
+// }
+import "C"
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"collectd.org/cdtime"
+)
+
+// Flush calls all registered flush callbacks with the given timeout and
+// identifier. An empty identifier is passed as NULL.
+func Flush(timeout time.Duration, identifier string) error {
+	var cID *C.char
+	if identifier != "" {
+		cID = C.CString(identifier)
+		defer C.free(unsafe.Pointer(cID))
+	}
+
+	status, err := C.plugin_flush(nil, C.cdtime_t(cdtime.NewDuration(timeout)), cID)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("plugin_flush() = %d", status)
+	}
+
+	return nil
+}
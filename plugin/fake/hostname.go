@@ -0,0 +1,24 @@
+package fake
+
+// #cgo CPPFLAGS: -DHAVE_CONFIG_H
+// #include <stdlib.h>
+// #include <string.h>
+// #include "plugin.h"
+//
+// char *hostname_g = NULL;
+// void set_hostname(char const *name) {
+//   free(hostname_g);
+//   hostname_g = strdup(name);
+// }
+import "C"
+
+import "unsafe"
+
+// SetHostname sets the host name returned by the fake hostname_g global
+// variable.
+func SetHostname(name string) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	C.set_hostname(cName)
+}
@@ -0,0 +1,64 @@
+package fake
+
+// #cgo CPPFLAGS: -DHAVE_CONFIG_H
+// #cgo LDFLAGS: -ldl
+// #include <stdlib.h>
+// #include "plugin.h"
+//
+// typedef struct {
+//   const char *name;
+//   plugin_init_cb callback;
+// } init_callback_t;
+// static init_callback_t *init_callbacks = NULL;
+// static size_t init_callbacks_num = 0;
+//
+// int plugin_register_init(const char *name, plugin_init_cb callback) {
+//   init_callback_t *ptr = realloc(
+//       init_callbacks, (init_callbacks_num + 1) * sizeof(*init_callbacks));
+//   if (ptr == NULL) {
+//     return ENOMEM;
+//   }
+//   init_callbacks = ptr;
+//   init_callbacks[init_callbacks_num] = (init_callback_t){
+//       .name = name,
+//       .callback = callback,
+//   };
+//   init_callbacks_num++;
+//
+//   return 0;
+// }
+//
+// int plugin_init_all(void) {
+//   int ret = 0;
+//   for (size_t i = 0; i < init_callbacks_num; i++) {
+//     int err = init_callbacks[i].callback();
+//     if (err != 0) {
+//       ret = err;
+//     }
+//   }
+//   return ret;
+// }
+//
+// void reset_init(void) {
+//   free(init_callbacks);
+//   init_callbacks = NULL;
+//   init_callbacks_num = 0;
+// }
+import "C"
+
+import (
+	"fmt"
+)
+
+// InitAll calls all registered init callbacks.
+func InitAll() error {
+	status, err := C.plugin_init_all()
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("plugin_init_all() = %d", status)
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package fake
+
+// #cgo CPPFLAGS: -DHAVE_CONFIG_H
+// #cgo LDFLAGS: -ldl
+// #include <stdbool.h>
+// #include <stdlib.h>
+// #include <string.h>
+// #include "plugin.h"
+//
+// typedef struct {
+//   const char *name;
+//   plugin_notification_cb callback;
+//   user_data_t user_data;
+// } notification_callback_t;
+// static notification_callback_t *notification_callbacks = NULL;
+// static size_t notification_callbacks_num = 0;
+//
+// int plugin_register_notification(const char *name,
+//                                  plugin_notification_cb callback,
+//                                  user_data_t const *user_data) {
+//   notification_callback_t *ptr =
+//       realloc(notification_callbacks, (notification_callbacks_num + 1) *
+//                                           sizeof(*notification_callbacks));
+//   if (ptr == NULL) {
+//     return ENOMEM;
+//   }
+//   notification_callbacks = ptr;
+//   notification_callbacks[notification_callbacks_num] =
+//       (notification_callback_t){
+//           .name = name,
+//           .callback = callback,
+//           .user_data = *user_data,
+//       };
+//   notification_callbacks_num++;
+//
+//   return 0;
+// }
+//
+// int plugin_dispatch_notification(notification_t const *n) {
+//   int ret = 0;
+//   for (size_t i = 0; i < notification_callbacks_num; i++) {
+//     notification_callback_t *cb = notification_callbacks + i;
+//     int err = cb->callback(n, &cb->user_data);
+//     if (err != 0) {
+//       ret = err;
+//     }
+//   }
+//   return ret;
+// }
+//
+// void reset_notification(void) {
+//   for (size_t i = 0; i < notification_callbacks_num; i++) {
+//     user_data_t *ud = &notification_callbacks[i].user_data;
+//     if (ud->free_func == NULL) {
+//       continue;
+//     }
+//     ud->free_func(ud->data);
+//     ud->data = NULL;
+//   }
+//   free(notification_callbacks);
+//   notification_callbacks = NULL;
+//   notification_callbacks_num = 0;
+// }
+//
+// static notification_meta_t *notification_meta_append(notification_t *n,
+//                                                      char const *name) {
+//   notification_meta_t *m = calloc(1, sizeof(*m));
+//   if (m == NULL) {
+//     return NULL;
+//   }
+//   strncpy(m->name, name, sizeof(m->name) - 1);
+//
+//   notification_meta_t **tail = &n->meta;
+//   while (*tail != NULL) {
+//     tail = &(*tail)->next;
+//   }
+//   *tail = m;
+//   return m;
+// }
+//
+// int plugin_notification_meta_add_string(notification_t *n,
+//                                         char const *name,
+//                                         char const *value) {
+//   notification_meta_t *m = notification_meta_append(n, name);
+//   if (m == NULL) {
+//     return ENOMEM;
+//   }
+//   m->type = NM_TYPE_STRING;
+//   m->nm_value.nm_string = strdup(value);
+//   return 0;
+// }
+//
+// int plugin_notification_meta_add_signed_int(notification_t *n,
+//                                             char const *name,
+//                                             int64_t value) {
+//   notification_meta_t *m = notification_meta_append(n, name);
+//   if (m == NULL) {
+//     return ENOMEM;
+//   }
+//   m->type = NM_TYPE_SIGNED_INT;
+//   m->nm_value.nm_signed_int = value;
+//   return 0;
+// }
+//
+// int plugin_notification_meta_add_unsigned_int(notification_t *n,
+//                                               char const *name,
+//                                               uint64_t value) {
+//   notification_meta_t *m = notification_meta_append(n, name);
+//   if (m == NULL) {
+//     return ENOMEM;
+//   }
+//   m->type = NM_TYPE_UNSIGNED_INT;
+//   m->nm_value.nm_unsigned_int = value;
+//   return 0;
+// }
+//
+// int plugin_notification_meta_add_double(notification_t *n,
+//                                         char const *name, double value) {
+//   notification_meta_t *m = notification_meta_append(n, name);
+//   if (m == NULL) {
+//     return ENOMEM;
+//   }
+//   m->type = NM_TYPE_DOUBLE;
+//   m->nm_value.nm_double = value;
+//   return 0;
+// }
+//
+// int plugin_notification_meta_add_boolean(notification_t *n,
+//                                          char const *name, bool value) {
+//   notification_meta_t *m = notification_meta_append(n, name);
+//   if (m == NULL) {
+//     return ENOMEM;
+//   }
+//   m->type = NM_TYPE_BOOLEAN;
+//   m->nm_value.nm_boolean = value;
+//   return 0;
+// }
+//
+// int plugin_notification_meta_free(notification_meta_t *m) {
+//   while (m != NULL) {
+//     notification_meta_t *next = m->next;
+//     if (m->type == NM_TYPE_STRING) {
+//       free((char *)m->nm_value.nm_string);
+//     }
+//     free(m);
+//     m = next;
+//   }
+//   return 0;
+// }
+//
+// static void notification_set(notification_t *n, int severity, cdtime_t time,
+//                              char const *message, char const *host,
+//                              char const *plugin, char const *plugin_instance,
+//                              char const *type, char const *type_instance) {
+//   n->severity = severity;
+//   n->time = time;
+//   strncpy(n->message, message, sizeof(n->message) - 1);
+//   strncpy(n->host, host, sizeof(n->host) - 1);
+//   strncpy(n->plugin, plugin, sizeof(n->plugin) - 1);
+//   strncpy(n->plugin_instance, plugin_instance,
+//           sizeof(n->plugin_instance) - 1);
+//   strncpy(n->type, type, sizeof(n->type) - 1);
+//   strncpy(n->type_instance, type_instance, sizeof(n->type_instance) - 1);
+// }
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"collectd.org/api"
+	"collectd.org/cdtime"
+)
+
+// DispatchNotification converts n to a notification_t and calls all
+// registered notification callbacks with it.
+func DispatchNotification(n api.Notification) error {
+	cn := (*C.notification_t)(C.calloc(1, C.sizeof_notification_t))
+	defer C.free(unsafe.Pointer(cn))
+	defer func() {
+		C.plugin_notification_meta_free(cn.meta)
+	}()
+
+	var cStrings []*C.char
+	cString := func(s string) *C.char {
+		cs := C.CString(s)
+		cStrings = append(cStrings, cs)
+		return cs
+	}
+	defer func() {
+		for _, cs := range cStrings {
+			C.free(unsafe.Pointer(cs))
+		}
+	}()
+
+	C.notification_set(cn, C.int(n.Severity), C.cdtime_t(cdtime.New(n.Time)),
+		cString(n.Message), cString(n.Host), cString(n.Plugin),
+		cString(n.PluginInstance), cString(n.Type), cString(n.TypeInstance))
+
+	for _, key := range n.Meta.Keys() {
+		cKey := cString(key)
+
+		var status C.int
+		switch v := n.Meta[key].Interface().(type) {
+		case string:
+			status = C.plugin_notification_meta_add_string(cn, cKey, cString(v))
+		case int64:
+			status = C.plugin_notification_meta_add_signed_int(cn, cKey, C.int64_t(v))
+		case uint64:
+			status = C.plugin_notification_meta_add_unsigned_int(cn, cKey, C.uint64_t(v))
+		case float64:
+			status = C.plugin_notification_meta_add_double(cn, cKey, C.double(v))
+		case bool:
+			status = C.plugin_notification_meta_add_boolean(cn, cKey, C.bool(v))
+		default:
+			return fmt.Errorf("unexpected meta data type %T", v)
+		}
+		if status != 0 {
+			return fmt.Errorf("adding meta data %q failed with status %d", key, status)
+		}
+	}
+
+	status, err := C.plugin_dispatch_notification(cn)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return fmt.Errorf("plugin_dispatch_notification() = %d", status)
+	}
+
+	return nil
+}
@@ -41,6 +41,29 @@ package fake
 //   return 0;
 // }
 //
+// int plugin_unregister_read(const char *name) {
+//   size_t j = 0;
+//   for (size_t i = 0; i < read_callbacks_num; i++) {
+//     read_callback_t *cb = read_callbacks + i;
+//     if (strcmp(name, cb->name) != 0) {
+//       read_callbacks[j] = *cb;
+//       j++;
+//       continue;
+//     }
+//     free(cb->name);
+//     free(cb->group);
+//     if (cb->user_data.free_func != NULL) {
+//       cb->user_data.free_func(cb->user_data.data);
+//     }
+//   }
+//
+//   if (j == read_callbacks_num) {
+//     return ENOENT;
+//   }
+//   read_callbacks_num = j;
+//   return 0;
+// }
+//
 // void plugin_set_interval(cdtime_t);
 // static int read_all(void) {
 //   cdtime_t save_interval = plugin_get_interval();
@@ -1,7 +1,8 @@
 package fake
 
 // #cgo CPPFLAGS: -DHAVE_CONFIG_H
-// #cgo LDFLAGS: -ldl
+// #cgo LDFLAGS: -ldl -lpthread
+// #include <pthread.h>
 // #include <stdlib.h>
 // #include <stdio.h>
 // #include "plugin.h"
@@ -13,12 +14,15 @@ package fake
 // } write_callback_t;
 // static write_callback_t *write_callbacks = NULL;
 // static size_t write_callbacks_num = 0;
+// static pthread_mutex_t write_callbacks_lock = PTHREAD_MUTEX_INITIALIZER;
 //
 // int plugin_register_write(const char *name, plugin_write_cb callback,
 //                           user_data_t const *user_data) {
+//   pthread_mutex_lock(&write_callbacks_lock);
 //   write_callback_t *ptr = realloc(
 //       write_callbacks, (write_callbacks_num + 1) * sizeof(*write_callbacks));
 //   if (ptr == NULL) {
+//     pthread_mutex_unlock(&write_callbacks_lock);
 //     return ENOMEM;
 //   }
 //   write_callbacks = ptr;
@@ -28,6 +32,7 @@ package fake
 //       .user_data = *user_data,
 //   };
 //   write_callbacks_num++;
+//   pthread_mutex_unlock(&write_callbacks_lock);
 //
 //   return 0;
 // }
@@ -57,19 +62,33 @@ package fake
 //     return errno;
 //   }
 //
+//   // Copy the callbacks so they are called without holding the lock.
+//   pthread_mutex_lock(&write_callbacks_lock);
+//   size_t callbacks_num = write_callbacks_num;
+//   write_callback_t *callbacks = calloc(callbacks_num + 1, sizeof(*callbacks));
+//   if (callbacks == NULL) {
+//     pthread_mutex_unlock(&write_callbacks_lock);
+//     return ENOMEM;
+//   }
+//   for (size_t i = 0; i < callbacks_num; i++) {
+//     callbacks[i] = write_callbacks[i];
+//   }
+//   pthread_mutex_unlock(&write_callbacks_lock);
+//
 //   int ret = 0;
-//   for (size_t i = 0; i < write_callbacks_num; i++) {
-//     int err =
-//         write_callbacks[i].callback(ds, vl, &write_callbacks[i].user_data);
+//   for (size_t i = 0; i < callbacks_num; i++) {
+//     int err = callbacks[i].callback(ds, vl, &callbacks[i].user_data);
 //     if (err != 0) {
 //       ret = err;
 //     }
 //   }
 //
+//   free(callbacks);
 //   return ret;
 // }
 //
 // void reset_write(void) {
+//   pthread_mutex_lock(&write_callbacks_lock);
 //   for (size_t i = 0; i < write_callbacks_num; i++) {
 //     user_data_t *ud = &write_callbacks[i].user_data;
 //     if (ud->free_func == NULL) {
@@ -81,5 +100,6 @@ package fake
 //   free(write_callbacks);
 //   write_callbacks = NULL;
 //   write_callbacks_num = 0;
+//   pthread_mutex_unlock(&write_callbacks_lock);
 // }
 import "C"
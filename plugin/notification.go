@@ -0,0 +1,232 @@
+// +build go1.5,cgo
+
+package plugin // import "collectd.org/plugin"
+
+// #cgo CPPFLAGS: -DHAVE_CONFIG_H
+// #cgo LDFLAGS: -ldl
+// #include <stdlib.h>
+// #include <stdbool.h>
+// #include "plugin.h"
+//
+// int plugin_register_notification_wrapper(char const *,
+//                                          plugin_notification_cb,
+//                                          user_data_t *);
+// int wrap_notification_callback(notification_t *, user_data_t *);
+//
+// char const *notification_meta_get_string(notification_meta_t const *);
+// int64_t notification_meta_get_signed_int(notification_meta_t const *);
+// uint64_t notification_meta_get_unsigned_int(notification_meta_t const *);
+// double notification_meta_get_double(notification_meta_t const *);
+// bool notification_meta_get_boolean(notification_meta_t const *);
+//
+// int plugin_dispatch_notification_wrapper(notification_t const *);
+// int plugin_notification_meta_add_boolean_wrapper(notification_t *,
+//                                                  char const *, bool);
+// int plugin_notification_meta_add_double_wrapper(notification_t *,
+//                                                 char const *, double);
+// int plugin_notification_meta_add_signed_int_wrapper(notification_t *,
+//                                                     char const *, int64_t);
+// int plugin_notification_meta_add_string_wrapper(notification_t *,
+//                                                 char const *, char const *);
+// int plugin_notification_meta_add_unsigned_int_wrapper(notification_t *,
+//                                                       char const *,
+//                                                       uint64_t);
+// int plugin_notification_meta_free_wrapper(notification_meta_t *);
+//
+// typedef void (*free_func_t)(void *);
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+
+	"collectd.org/api"
+	"collectd.org/cdtime"
+	"collectd.org/meta"
+)
+
+// Notifier implements a notification callback.
+type Notifier interface {
+	Notify(context.Context, api.Notification) error
+}
+
+// notificationFuncs holds references to all notification callbacks, so the
+// garbage collector doesn't get any funny ideas.
+var (
+	notificationFuncs   = make(map[string]Notifier)
+	notificationFuncsMu sync.RWMutex
+)
+
+// RegisterNotification registers a new notification function with the daemon
+// which is called for every notification dispatched by collectd.
+//
+// Like write callbacks, notification callbacks may be called concurrently.
+func RegisterNotification(name string, n Notifier) error {
+	cName := C.CString(name)
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(cName),
+		free_func: C.free_func_t(C.free),
+	}
+
+	status, err := C.plugin_register_notification_wrapper(cName, C.plugin_notification_cb(C.wrap_notification_callback), &ud)
+	if err := wrapCError(status, err, "plugin_register_notification"); err != nil {
+		return err
+	}
+
+	notificationFuncsMu.Lock()
+	defer notificationFuncsMu.Unlock()
+
+	notificationFuncs[name] = n
+	return nil
+}
+
+//export wrap_notification_callback
+func wrap_notification_callback(cn *C.notification_t, ud *C.user_data_t) C.int {
+	name := C.GoString((*C.char)(ud.data))
+
+	notificationFuncsMu.RLock()
+	f, ok := notificationFuncs[name]
+	notificationFuncsMu.RUnlock()
+	if !ok {
+		return -1
+	}
+
+	n := api.Notification{
+		Identifier: api.Identifier{
+			Host:           C.GoString(&cn.host[0]),
+			Plugin:         C.GoString(&cn.plugin[0]),
+			PluginInstance: C.GoString(&cn.plugin_instance[0]),
+			Type:           C.GoString(&cn._type[0]),
+			TypeInstance:   C.GoString(&cn.type_instance[0]),
+		},
+		Time:     cdtime.Time(cn.time).Time(),
+		Severity: api.Severity(cn.severity),
+		Message:  C.GoString(&cn.message[0]),
+	}
+
+	m, err := unmarshalNotificationMeta(cn.meta)
+	if err != nil {
+		Errorf("%s plugin: unmarshalNotificationMeta() failed: %v", name, err)
+	}
+	n.Meta = m
+
+	ctx := withName(context.Background(), name)
+	if err := f.Notify(ctx, n); err != nil {
+		Errorf("%s plugin: Notify() failed: %v", name, err)
+		return -1
+	}
+
+	return 0
+}
+
+// DispatchNotification sends a notification to the daemon, which passes it on
+// to all registered notification callbacks.
+//
+// If n.Plugin is empty, the plugin name is determined from ctx, like Write
+// does. If n.Time is zero, the current time is used.
+func DispatchNotification(ctx context.Context, n api.Notification) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	if n.Plugin == "" {
+		name, ok := Name(ctx)
+		if !ok {
+			return errors.New("unable to determine plugin name from context")
+		}
+		n.Plugin = name
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+
+	cn := &C.notification_t{
+		severity: C.int(n.Severity),
+		time:     C.cdtime_t(cdtime.New(n.Time)),
+	}
+	strcpy(cn.message[:], n.Message)
+	strcpy(cn.host[:], n.Host)
+	strcpy(cn.plugin[:], n.Plugin)
+	strcpy(cn.plugin_instance[:], n.PluginInstance)
+	strcpy(cn._type[:], n.Type)
+	strcpy(cn.type_instance[:], n.TypeInstance)
+	defer func() {
+		if cn.meta != nil {
+			C.plugin_notification_meta_free_wrapper(cn.meta)
+			cn.meta = nil
+		}
+	}()
+
+	for _, key := range n.Meta.Keys() {
+		if err := marshalNotificationMetaEntry(cn, key, n.Meta[key]); err != nil {
+			return err
+		}
+	}
+
+	status, err := C.plugin_dispatch_notification_wrapper(cn)
+	return wrapCError(status, err, "plugin_dispatch_notification")
+}
+
+func marshalNotificationMetaEntry(cn *C.notification_t, key string, value meta.Entry) error {
+	cKey := C.CString(key)
+	defer C.free(unsafe.Pointer(cKey))
+
+	switch value := value.Interface().(type) {
+	case bool:
+		s, err := C.plugin_notification_meta_add_boolean_wrapper(cn, cKey, C.bool(value))
+		return wrapCError(s, err, "plugin_notification_meta_add_boolean")
+	case float64:
+		s, err := C.plugin_notification_meta_add_double_wrapper(cn, cKey, C.double(value))
+		return wrapCError(s, err, "plugin_notification_meta_add_double")
+	case int64:
+		s, err := C.plugin_notification_meta_add_signed_int_wrapper(cn, cKey, C.int64_t(value))
+		return wrapCError(s, err, "plugin_notification_meta_add_signed_int")
+	case uint64:
+		s, err := C.plugin_notification_meta_add_unsigned_int_wrapper(cn, cKey, C.uint64_t(value))
+		return wrapCError(s, err, "plugin_notification_meta_add_unsigned_int")
+	case string:
+		cValue := C.CString(value)
+		defer C.free(unsafe.Pointer(cValue))
+		s, err := C.plugin_notification_meta_add_string_wrapper(cn, cKey, cValue)
+		return wrapCError(s, err, "plugin_notification_meta_add_string")
+	default:
+		return nil
+	}
+}
+
+// unmarshalNotificationMeta converts the linked list of notification meta data
+// to meta.Data. Entries of unknown types are skipped and reported as an error.
+func unmarshalNotificationMeta(m *C.notification_meta_t) (meta.Data, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	var err error
+	ret := make(meta.Data)
+	for ; m != nil; m = m.next {
+		key := C.GoString(&m.name[0])
+
+		switch m._type {
+		case C.NM_TYPE_STRING:
+			ret[key] = meta.String(C.GoString(C.notification_meta_get_string(m)))
+		case C.NM_TYPE_SIGNED_INT:
+			ret[key] = meta.Int64(int64(C.notification_meta_get_signed_int(m)))
+		case C.NM_TYPE_UNSIGNED_INT:
+			ret[key] = meta.UInt64(uint64(C.notification_meta_get_unsigned_int(m)))
+		case C.NM_TYPE_DOUBLE:
+			ret[key] = meta.Float64(float64(C.notification_meta_get_double(m)))
+		case C.NM_TYPE_BOOLEAN:
+			ret[key] = meta.Bool(bool(C.notification_meta_get_boolean(m)))
+		default:
+			err = fmt.Errorf("meta data %q has unknown type %d", key, m._type)
+		}
+	}
+
+	return ret, err
+}
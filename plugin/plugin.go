@@ -81,6 +81,7 @@ package plugin // import "collectd.org/plugin"
 // int plugin_dispatch_values_wrapper(value_list_t const *vl);
 // cdtime_t plugin_get_interval_wrapper(void);
 // int timeout_wrapper(void);
+// char *hostname_wrapper(void);
 //
 // data_source_t *ds_dsrc(data_set_t const *ds, size_t i);
 //
@@ -114,6 +115,9 @@ package plugin // import "collectd.org/plugin"
 // int plugin_register_write_wrapper(char const *, plugin_write_cb, user_data_t *);
 // int wrap_write_callback(data_set_t *, value_list_t *, user_data_t *);
 //
+// int plugin_register_flush_wrapper(char const *, plugin_flush_cb, user_data_t *);
+// int wrap_flush_callback(cdtime_t, char *, user_data_t *);
+//
 // int plugin_register_shutdown_wrapper(char *, plugin_shutdown_cb);
 // int wrap_shutdown_callback(void);
 //
@@ -125,9 +129,11 @@ package plugin // import "collectd.org/plugin"
 //
 // int register_complex_config_wrapper(char const *, plugin_complex_config_cb);
 // int wrap_configure_callback(oconfig_item_t *);
-// int dispatch_configurations(void);
 //
 // int register_init_wrapper (const char *name, plugin_init_cb callback);
+// int wrap_init_callback(void);
+//
+// int plugin_unregister_read_wrapper(char const *);
 //
 // typedef void (*free_func_t)(void *);
 import "C"
@@ -136,6 +142,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -168,8 +175,15 @@ func strcpy(dst []C.char, src string) {
 	copy(dst, cStr)
 }
 
-func newValueListT(vl *api.ValueList) (*C.value_list_t, error) {
-	ret := &C.value_list_t{}
+// marshalValueList stores vl in ret. ret may be reused: its values buffer is
+// retained and previously set meta data is destroyed. Call freeValueListT to
+// release the resources held by ret.
+func marshalValueList(ret *C.value_list_t, vl *api.ValueList) error {
+	ret.values_len = 0
+	if ret.meta != nil {
+		C.meta_data_destroy_wrapper(ret.meta)
+		ret.meta = nil
+	}
 
 	strcpy(ret.host[:], vl.Host)
 	strcpy(ret.plugin[:], vl.Plugin)
@@ -183,28 +197,28 @@ func newValueListT(vl *api.ValueList) (*C.value_list_t, error) {
 		switch v := v.(type) {
 		case api.Counter:
 			if _, err := C.value_list_add_counter(ret, C.counter_t(v)); err != nil {
-				return nil, fmt.Errorf("value_list_add_counter: %w", err)
+				return fmt.Errorf("value_list_add_counter: %w", err)
 			}
 		case api.Derive:
 			if _, err := C.value_list_add_derive(ret, C.derive_t(v)); err != nil {
-				return nil, fmt.Errorf("value_list_add_derive: %w", err)
+				return fmt.Errorf("value_list_add_derive: %w", err)
 			}
 		case api.Gauge:
 			if _, err := C.value_list_add_gauge(ret, C.gauge_t(v)); err != nil {
-				return nil, fmt.Errorf("value_list_add_gauge: %w", err)
+				return fmt.Errorf("value_list_add_gauge: %w", err)
 			}
 		default:
-			return nil, fmt.Errorf("not yet supported: %T", v)
+			return fmt.Errorf("not yet supported: %T", v)
 		}
 	}
 
 	md, err := marshalMeta(vl.Meta)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	ret.meta = md
 
-	return ret, nil
+	return nil
 }
 
 func freeValueListT(vl *C.value_list_t) {
@@ -311,28 +325,28 @@ func unmarshalMetaEntry(goMeta meta.Data, cMeta *C.meta_data_t, key *C.char) err
 	}
 
 	switch typ {
-	case C.MD_TYPE_BOOLEAN:
+	case meta.TypeBool:
 		var v C.bool
 		s, err := C.meta_data_get_boolean_wrapper(cMeta, key, &v)
 		if err := wrapCError(s, err, "meta_data_get_boolean"); err != nil {
 			return err
 		}
 		goMeta[C.GoString(key)] = meta.Bool(bool(v))
-	case C.MD_TYPE_DOUBLE:
+	case meta.TypeFloat64:
 		var v C.double
 		s, err := C.meta_data_get_double_wrapper(cMeta, key, &v)
 		if err := wrapCError(s, err, "meta_data_get_double"); err != nil {
 			return err
 		}
 		goMeta[C.GoString(key)] = meta.Float64(float64(v))
-	case C.MD_TYPE_SIGNED_INT:
+	case meta.TypeInt64:
 		var v C.int64_t
 		s, err := C.meta_data_get_signed_int_wrapper(cMeta, key, &v)
 		if err := wrapCError(s, err, "meta_data_get_signed_int"); err != nil {
 			return err
 		}
 		goMeta[C.GoString(key)] = meta.Int64(int64(v))
-	case C.MD_TYPE_STRING:
+	case meta.TypeString:
 		var v *C.char
 		s, err := C.meta_data_get_string_wrapper(cMeta, key, &v)
 		if err := wrapCError(s, err, "meta_data_get_string"); err != nil {
@@ -340,7 +354,7 @@ func unmarshalMetaEntry(goMeta meta.Data, cMeta *C.meta_data_t, key *C.char) err
 		}
 		defer C.free(unsafe.Pointer(v))
 		goMeta[C.GoString(key)] = meta.String(C.GoString(v))
-	case C.MD_TYPE_UNSIGNED_INT:
+	case meta.TypeUInt64:
 		var v C.uint64_t
 		s, err := C.meta_data_get_unsigned_int_wrapper(cMeta, key, &v)
 		if err := wrapCError(s, err, "meta_data_get_unsigned_int"); err != nil {
@@ -359,37 +373,97 @@ func unmarshalMetaEntry(goMeta meta.Data, cMeta *C.meta_data_t, key *C.char) err
 //
 // The following fields are optional and will be filled in if empty / zero:
 //
-// · vl.Identifier.Host
+// · vl.Identifier.Host, using Hostname()
 //
 // · vl.Identifier.Plugin
 //
-// · vl.Time
+// · vl.Time, using the current time
 //
-// · vl.Interval
+// · vl.Interval, using Interval()
 //
 // Use api.WriterFunc to pass this function as an api.Writer.
 func Write(ctx context.Context, vl *api.ValueList) error {
+	vlt := &C.value_list_t{}
+	defer freeValueListT(vlt)
+
+	return write(ctx, vlt, vl)
+}
+
+// WriteAll is like calling Write for each value list, but reuses the memory
+// required for converting value lists. All value lists are dispatched, even if
+// some of them fail; the first error is returned. If the context is canceled,
+// the remaining value lists are not dispatched.
+func WriteAll(ctx context.Context, vls []*api.ValueList) error {
+	vlt := &C.value_list_t{}
+	defer freeValueListT(vlt)
+
+	var firstErr error
+	for _, vl := range vls {
+		err := write(ctx, vlt, vl)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			return firstErr
+		}
+	}
+
+	return firstErr
+}
+
+// write converts vl, using vlt as scratch space, and calls the
+// plugin_dispatch_values() function of the collectd daemon.
+func write(ctx context.Context, vlt *C.value_list_t, vl *api.ValueList) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
 	}
 
+	if vl.Host == "" || vl.Plugin == "" || vl.Time.IsZero() || vl.Interval == 0 {
+		// Don't modify the argument.
+		vl = vl.Clone()
+	}
+
+	if vl.Host == "" {
+		h, err := Hostname()
+		if err != nil {
+			return err
+		}
+		vl.Host = h
+	}
+
 	if vl.Plugin == "" {
 		n, ok := Name(ctx)
 		if !ok {
 			return errors.New("unable to determine plugin name from context")
 		}
-		// Don't modify the argument.
-		vl = vl.Clone()
 		vl.Plugin = n
 	}
 
-	vlt, err := newValueListT(vl)
-	if err != nil {
+	if vl.Time.IsZero() {
+		vl.Time = time.Now()
+	}
+
+	if vl.Interval == 0 {
+		ival, err := Interval()
+		if err != nil {
+			return err
+		}
+		vl.Interval = ival
+	}
+
+	if err := marshalValueList(vlt, vl); err != nil {
 		return err
 	}
-	defer freeValueListT(vlt)
+
+	// Marshaling large value lists may take a while; don't dispatch if the
+	// context has been canceled in the meantime.
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
 
 	status, err := C.plugin_dispatch_values_wrapper(vlt)
 	return wrapCError(status, err, "plugin_dispatch_values")
@@ -397,7 +471,10 @@ func Write(ctx context.Context, vl *api.ValueList) error {
 
 // readFuncs holds references to all read callbacks, so the garbage collector
 // doesn't get any funny ideas.
-var readFuncs = make(map[string]Reader)
+var (
+	readFuncs   = make(map[string]readFunc)
+	readFuncsMu sync.RWMutex
+)
 
 // RegisterRead registers a new read function with the daemon which is called
 // periodically.
@@ -430,13 +507,27 @@ func RegisterRead(name string, r Reader, opts ...ReadOption) error {
 		return err
 	}
 
-	readFuncs[name] = r
+	readFuncsMu.Lock()
+	defer readFuncsMu.Unlock()
+
+	readFuncs[name] = readFunc{
+		Reader: r,
+		opts:   ro,
+	}
 	return nil
 }
 
+// readFunc is a registered read callback and its options.
+type readFunc struct {
+	Reader
+	opts readOpt
+}
+
 type readOpt struct {
-	group    string
-	interval cdtime.Time
+	group     string
+	interval  cdtime.Time
+	timeout   time.Duration
+	noTimeout bool
 }
 
 // ReadOption is an option for the RegisterRead function.
@@ -461,6 +552,25 @@ func WithGroup(g string) ReadOption {
 	}
 }
 
+// WithTimeout sets the timeout of the context passed to the read callback. If
+// unspecified, or when set to zero, Timeout() is used.
+func WithTimeout(d time.Duration) ReadOption {
+	return func(o *readOpt) {
+		o.timeout = d
+		o.noTimeout = false
+	}
+}
+
+// WithoutTimeout removes the timeout from the context passed to the read
+// callback. This is intended for reads that may legitimately take longer than
+// Timeout(). Such read callbacks must handle timeouts themselves.
+func WithoutTimeout() ReadOption {
+	return func(o *readOpt) {
+		o.timeout = 0
+		o.noTimeout = true
+	}
+}
+
 type key struct{}
 
 var nameKey key
@@ -475,24 +585,67 @@ func Name(ctx context.Context) (string, bool) {
 	return name, ok
 }
 
+type readIntervalKey struct{}
+
+func withReadInterval(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, readIntervalKey{}, d)
+}
+
+// ReadInterval returns the interval of the read callback that ctx was passed
+// to. This is the interval set with WithInterval, or collectd's global
+// interval if the option was not used.
+func ReadInterval(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(readIntervalKey{}).(time.Duration)
+	return d, ok
+}
+
+type dataSetKey struct{}
+
+func withDataSet(ctx context.Context, ds *api.DataSet) context.Context {
+	return context.WithValue(ctx, dataSetKey{}, ds)
+}
+
+// DataSet returns the data set, i.e. the type definition, of the value list
+// passed to a write callback. It includes the data source types and their
+// minimum and maximum values, as known to the daemon. This saves write
+// plugins from having to load "types.db" themselves.
+func DataSet(ctx context.Context) (*api.DataSet, bool) {
+	ds, ok := ctx.Value(dataSetKey{}).(*api.DataSet)
+	return ds, ok
+}
+
 //export wrap_read_callback
 func wrap_read_callback(ud *C.user_data_t) C.int {
 	name := C.GoString((*C.char)(ud.data))
+
+	readFuncsMu.RLock()
 	r, ok := readFuncs[name]
+	readFuncsMu.RUnlock()
 	if !ok {
 		return -1
 	}
 
-	timeout, err := Timeout()
-	if err != nil {
-		Errorf("%s plugin: Timeout() failed: %v", name, err)
-		return -1
+	ctx := withName(context.Background(), name)
+
+	// Within a read callback, the daemon returns the callback's interval.
+	if ival, err := Interval(); err == nil && ival > 0 {
+		ctx = withReadInterval(ctx, ival)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+	if !r.opts.noTimeout {
+		timeout := r.opts.timeout
+		if timeout == 0 {
+			var err error
+			if timeout, err = Timeout(); err != nil {
+				Errorf("%s plugin: Timeout() failed: %v", name, err)
+				return -1
+			}
+		}
 
-	ctx = withName(ctx, name)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	if err := r.Read(ctx); err != nil {
 		Errorf("%s plugin: Read() failed: %v", name, err)
@@ -513,8 +666,27 @@ func Interval() (time.Duration, error) {
 	return cdtime.Time(ival).Duration(), nil
 }
 
+// defaultInterval is collectd's default read interval. It is used by Timeout
+// if the interval has not been set yet.
+const defaultInterval = 10 * time.Second
+
+// Hostname returns the daemon's host name, i.e. the "Hostname" global config
+// option or the system's host name.
+func Hostname() (string, error) {
+	ptr := C.hostname_wrapper()
+	if ptr == nil {
+		return "", errors.New("hostname_g not found or not set")
+	}
+
+	return C.GoString(ptr), nil
+}
+
 // Timeout returns the duration after which this plugin's metrics are
 // considered stale and are pruned from collectd's internal metrics cache.
+//
+// During early initialization, the interval may not be set yet. In that case
+// a warning is logged and Timeout falls back to collectd's default interval of
+// 10 seconds, so that read callbacks are not passed an expired context.
 func Timeout() (time.Duration, error) {
 	to, err := C.timeout_wrapper()
 	if err != nil {
@@ -524,13 +696,20 @@ func Timeout() (time.Duration, error) {
 	if err != nil {
 		return 0, err
 	}
+	if ival <= 0 {
+		Warningf("plugin.Timeout(): invalid interval %v, using %v instead", ival, defaultInterval)
+		ival = defaultInterval
+	}
 
 	return ival * time.Duration(to), nil
 }
 
 // writeFuncs holds references to all write callbacks, so the garbage collector
 // doesn't get any funny ideas.
-var writeFuncs = make(map[string]api.Writer)
+var (
+	writeFuncs   = make(map[string]api.Writer)
+	writeFuncsMu sync.RWMutex
+)
 
 // RegisterWrite registers a new write function with the daemon which is called
 // for every metric collected by collectd.
@@ -550,6 +729,9 @@ func RegisterWrite(name string, w api.Writer) error {
 		return err
 	}
 
+	writeFuncsMu.Lock()
+	defer writeFuncsMu.Unlock()
+
 	writeFuncs[name] = w
 	return nil
 }
@@ -557,7 +739,10 @@ func RegisterWrite(name string, w api.Writer) error {
 //export wrap_write_callback
 func wrap_write_callback(ds *C.data_set_t, cvl *C.value_list_t, ud *C.user_data_t) C.int {
 	name := C.GoString((*C.char)(ud.data))
+
+	writeFuncsMu.RLock()
 	w, ok := writeFuncs[name]
+	writeFuncsMu.RUnlock()
 	if !ok {
 		return -1
 	}
@@ -573,27 +758,36 @@ func wrap_write_callback(ds *C.data_set_t, cvl *C.value_list_t, ud *C.user_data_
 		Time:     cdtime.Time(cvl.time).Time(),
 		Interval: cdtime.Time(cvl.interval).Duration(),
 	}
+	dataSet := &api.DataSet{
+		Name: C.GoString(&ds._type[0]),
+	}
 
 	// TODO: Remove 'size_t' cast on 'ds_num' upon 5.7 release.
 	for i := C.size_t(0); i < C.size_t(ds.ds_num); i++ {
 		dsrc := C.ds_dsrc(ds, i)
 
+		var v api.Value
 		switch dsrc._type {
 		case C.DS_TYPE_COUNTER:
-			v := C.value_list_get_counter(cvl, i)
-			vl.Values = append(vl.Values, api.Counter(v))
+			v = api.Counter(C.value_list_get_counter(cvl, i))
 		case C.DS_TYPE_DERIVE:
-			v := C.value_list_get_derive(cvl, i)
-			vl.Values = append(vl.Values, api.Derive(v))
+			v = api.Derive(C.value_list_get_derive(cvl, i))
 		case C.DS_TYPE_GAUGE:
-			v := C.value_list_get_gauge(cvl, i)
-			vl.Values = append(vl.Values, api.Gauge(v))
+			v = api.Gauge(C.value_list_get_gauge(cvl, i))
 		default:
 			Errorf("%s plugin: data source type %d is not supported", name, dsrc._type)
 			return -1
 		}
-
-		vl.DSNames = append(vl.DSNames, C.GoString(&dsrc.name[0]))
+		vl.Values = append(vl.Values, v)
+
+		dsName := C.GoString(&dsrc.name[0])
+		vl.DSNames = append(vl.DSNames, dsName)
+		dataSet.Sources = append(dataSet.Sources, api.DataSource{
+			Name: dsName,
+			Type: reflect.TypeOf(v),
+			Min:  float64(dsrc.min),
+			Max:  float64(dsrc.max),
+		})
 	}
 
 	m, err := unmarshalMeta(cvl.meta)
@@ -602,7 +796,7 @@ func wrap_write_callback(ds *C.data_set_t, cvl *C.value_list_t, ud *C.user_data_
 	}
 	vl.Meta = m
 
-	ctx := withName(context.Background(), name)
+	ctx := withDataSet(withName(context.Background(), name), dataSet)
 	if err := w.Write(ctx, vl); err != nil {
 		Errorf("%s plugin: Write() failed: %v", name, err)
 		return -1
@@ -611,6 +805,68 @@ func wrap_write_callback(ds *C.data_set_t, cvl *C.value_list_t, ud *C.user_data_
 	return 0
 }
 
+// Flusher implements a flush callback.
+type Flusher interface {
+	// Flush writes buffered data that is older than timeout. A timeout of
+	// zero means that all data should be flushed. If identifier is not
+	// empty, only data of this metric should be flushed.
+	Flush(ctx context.Context, timeout time.Duration, identifier string) error
+}
+
+// flushFuncs holds references to all flush callbacks, so the garbage collector
+// doesn't get any funny ideas.
+var (
+	flushFuncs   = make(map[string]Flusher)
+	flushFuncsMu sync.RWMutex
+)
+
+// RegisterFlush registers a new flush function with the daemon which is called
+// when the daemon is asked to flush buffered data, e.g. using "collectdctl
+// flush". This is intended for write plugins that buffer data.
+func RegisterFlush(name string, f Flusher) error {
+	cName := C.CString(name)
+	ud := C.user_data_t{
+		data:      unsafe.Pointer(cName),
+		free_func: C.free_func_t(C.free),
+	}
+
+	status, err := C.plugin_register_flush_wrapper(cName, C.plugin_flush_cb(C.wrap_flush_callback), &ud)
+	if err := wrapCError(status, err, "plugin_register_flush"); err != nil {
+		return err
+	}
+
+	flushFuncsMu.Lock()
+	defer flushFuncsMu.Unlock()
+
+	flushFuncs[name] = f
+	return nil
+}
+
+//export wrap_flush_callback
+func wrap_flush_callback(timeout C.cdtime_t, identifier *C.char, ud *C.user_data_t) C.int {
+	name := C.GoString((*C.char)(ud.data))
+
+	flushFuncsMu.RLock()
+	f, ok := flushFuncs[name]
+	flushFuncsMu.RUnlock()
+	if !ok {
+		return -1
+	}
+
+	var id string
+	if identifier != nil {
+		id = C.GoString(identifier)
+	}
+
+	ctx := withName(context.Background(), name)
+	if err := f.Flush(ctx, cdtime.Time(timeout).Duration(), id); err != nil {
+		Errorf("%s plugin: Flush() failed: %v", name, err)
+		return -1
+	}
+
+	return 0
+}
+
 // First declare some types, interfaces, general functions
 
 // Shutter is called to shut down the plugin gracefully.
@@ -619,12 +875,23 @@ type Shutter interface {
 }
 
 // shutdownFuncs holds references to all shutdown callbacks
-var shutdownFuncs = make(map[string]Shutter)
+var (
+	shutdownFuncs   = make(map[string]Shutter)
+	shutdownFuncsMu sync.RWMutex
+)
 
 //export wrap_shutdown_callback
 func wrap_shutdown_callback() C.int {
-	ret := C.int(0)
+	// Copy the map so callbacks are called without holding the lock.
+	shutdownFuncsMu.RLock()
+	funcs := make(map[string]Shutter, len(shutdownFuncs))
 	for name, f := range shutdownFuncs {
+		funcs[name] = f
+	}
+	shutdownFuncsMu.RUnlock()
+
+	ret := C.int(0)
+	for name, f := range funcs {
 		ctx := withName(context.Background(), name)
 		if err := f.Shutdown(ctx); err != nil {
 			Errorf("%s plugin: Shutdown() failed: %v", name, err)
@@ -637,6 +904,9 @@ func wrap_shutdown_callback() C.int {
 // RegisterShutdown registers a shutdown function with the daemon which is called
 // when the plugin is required to shutdown gracefully.
 func RegisterShutdown(name string, s Shutter) error {
+	shutdownFuncsMu.Lock()
+	defer shutdownFuncsMu.Unlock()
+
 	// Only register a callback the first time one is implemented, subsequent
 	// callbacks get added to a map and called sequentially from the same
 	// (C) callback.
@@ -672,16 +942,25 @@ func RegisterLog(name string, l Logger) error {
 		return err
 	}
 
+	logFuncsMu.Lock()
+	defer logFuncsMu.Unlock()
+
 	logFuncs[name] = l
 	return nil
 }
 
-var logFuncs = make(map[string]Logger)
+var (
+	logFuncs   = make(map[string]Logger)
+	logFuncsMu sync.RWMutex
+)
 
 //export wrap_log_callback
 func wrap_log_callback(sev C.int, msg *C.char, ud *C.user_data_t) C.int {
 	name := C.GoString((*C.char)(ud.data))
+
+	logFuncsMu.RLock()
 	f, ok := logFuncs[name]
+	logFuncsMu.RUnlock()
 	if !ok {
 		return -1
 	}
@@ -705,9 +984,17 @@ type configFunc struct {
 	cfg config.Block
 }
 
+// complexConfigFunc holds a function registered with RegisterComplexConfig and
+// the blocks received for it so far.
+type complexConfigFunc struct {
+	fn     func(context.Context, []config.Block) error
+	blocks []config.Block
+}
+
 var (
-	configureFuncs     = make(map[string]*configFunc)
-	registerConfigInit sync.Once
+	configureFuncs        = make(map[string]*configFunc)
+	complexConfigureFuncs = make(map[string]*complexConfigFunc)
+	configureFuncsMu      sync.RWMutex
 )
 
 // RegisterConfig registers a configuration-receiving function with the daemon.
@@ -719,29 +1006,57 @@ var (
 // If no configuration is found for "name", c.Configure is still called with a
 // zero-valued config.Block.
 func RegisterConfig(name string, c Configurer) error {
+	if err := registerInitCallback(name); err != nil {
+		return err
+	}
+
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
 
-	var regErr error
-	registerConfigInit.Do(func() {
-		status, err := C.register_init_wrapper(cName, C.plugin_init_cb(C.dispatch_configurations))
-		regErr = wrapCError(status, err, "plugin_register_init")
-	})
-	if regErr != nil {
-		return regErr
-	}
-
 	status, err := C.register_complex_config_wrapper(cName, C.plugin_complex_config_cb(C.wrap_configure_callback))
 	if err := wrapCError(status, err, "register_configure"); err != nil {
 		return err
 	}
 
+	configureFuncsMu.Lock()
+	defer configureFuncsMu.Unlock()
+
 	configureFuncs[name] = &configFunc{
 		Configurer: c,
 	}
 	return nil
 }
 
+// RegisterComplexConfig registers a configuration-receiving function with the
+// daemon. Unlike RegisterConfig, blocks are neither merged nor checked to be
+// "Plugin" blocks: fn receives all blocks the daemon passed for "name",
+// unmodified and in the order they were received. The block's first value is
+// used to determine the plugin name.
+//
+// fn is called exactly once after the entire configuration has been read. If
+// no configuration is found for "name", fn is still called with a nil slice.
+func RegisterComplexConfig(name string, fn func(ctx context.Context, blocks []config.Block) error) error {
+	if err := registerInitCallback(name); err != nil {
+		return err
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+
+	status, err := C.register_complex_config_wrapper(cName, C.plugin_complex_config_cb(C.wrap_configure_callback))
+	if err := wrapCError(status, err, "register_configure"); err != nil {
+		return err
+	}
+
+	configureFuncsMu.Lock()
+	defer configureFuncsMu.Unlock()
+
+	complexConfigureFuncs[name] = &complexConfigFunc{
+		fn: fn,
+	}
+	return nil
+}
+
 //export wrap_configure_callback
 func wrap_configure_callback(ci *C.oconfig_item_t) C.int {
 	block, err := unmarshalConfigBlock(ci)
@@ -750,6 +1065,19 @@ func wrap_configure_callback(ci *C.oconfig_item_t) C.int {
 		return -1
 	}
 
+	if len(block.Values) != 0 && block.Values[0].IsString() {
+		configureFuncsMu.Lock()
+		f, ok := complexConfigureFuncs[block.Values[0].String()]
+		if ok {
+			f.blocks = append(f.blocks, block)
+		}
+		configureFuncsMu.Unlock()
+
+		if ok {
+			return 0
+		}
+	}
+
 	key := strings.ToLower(block.Key)
 	if key != "plugin" {
 		Errorf("got config block %q, want %q", block.Key, "Plugin")
@@ -763,28 +1091,141 @@ func wrap_configure_callback(ci *C.oconfig_item_t) C.int {
 	}
 	plugin := block.Values[0].String()
 
+	configureFuncsMu.Lock()
 	f, ok := configureFuncs[plugin]
+	var mergeErr error
+	if ok {
+		mergeErr = f.cfg.Merge(block)
+	}
+	configureFuncsMu.Unlock()
+
 	if !ok {
 		Errorf("callback for plugin %q not found", plugin)
 		return -1
 	}
-
-	if err := f.cfg.Merge(block); err != nil {
-		Errorf("merging config blocks failed: %v", err)
+	if mergeErr != nil {
+		Errorf("merging config blocks failed: %v", mergeErr)
 		return -1
 	}
 
 	return 0
 }
 
-//export dispatch_configurations
-func dispatch_configurations() C.int {
+// dispatchConfigurations calls all Configurers with their merged
+// configuration.
+func dispatchConfigurations() {
+	// Copy the map so callbacks are called without holding the lock.
+	configureFuncsMu.RLock()
+	funcs := make(map[string]configFunc, len(configureFuncs))
 	for name, f := range configureFuncs {
+		funcs[name] = *f
+	}
+	complexFuncs := make(map[string]complexConfigFunc, len(complexConfigureFuncs))
+	for name, f := range complexConfigureFuncs {
+		complexFuncs[name] = *f
+	}
+	configureFuncsMu.RUnlock()
+
+	for name, f := range funcs {
 		ctx := withName(context.Background(), name)
 		if err := f.Configure(ctx, f.cfg); err != nil {
 			Errorf("%s plugin: Configure() failed: %v", name, err)
 		}
 	}
+
+	for name, f := range complexFuncs {
+		ctx := withName(context.Background(), name)
+		if err := f.fn(ctx, f.blocks); err != nil {
+			Errorf("%s plugin: complex config callback failed: %v", name, err)
+		}
+	}
+}
+
+// Initializer implements an init callback.
+type Initializer interface {
+	Init(context.Context) error
+}
+
+type initFunc struct {
+	name string
+	Initializer
+}
+
+var (
+	initFuncs    []initFunc
+	initFuncsMu  sync.Mutex
+	registerInit sync.Once
+)
+
+// RegisterInit registers an init function with the daemon. i.Init is called
+// once, after the configuration has been read and passed to the Configurers
+// registered with RegisterConfig, and before read callbacks are called.
+//
+// If Init returns an error, the plugin is disabled: like collectd does for C
+// plugins, the read callback registered under the same name is unregistered.
+func RegisterInit(name string, i Initializer) error {
+	if err := registerInitCallback(name); err != nil {
+		return err
+	}
+
+	initFuncsMu.Lock()
+	defer initFuncsMu.Unlock()
+
+	initFuncs = append(initFuncs, initFunc{
+		name:        name,
+		Initializer: i,
+	})
+	return nil
+}
+
+// registerInitCallback registers wrap_init_callback with the daemon. The init
+// callback doesn't receive user data, so a single callback is registered for
+// all configuration and init functions. It is registered under the name of the
+// first plugin calling this function.
+func registerInitCallback(name string) error {
+	var regErr error
+	registerInit.Do(func() {
+		cName := C.CString(name)
+		defer C.free(unsafe.Pointer(cName))
+
+		status, err := C.register_init_wrapper(cName, C.plugin_init_cb(C.wrap_init_callback))
+		regErr = wrapCError(status, err, "plugin_register_init")
+	})
+	return regErr
+}
+
+//export wrap_init_callback
+func wrap_init_callback() C.int {
+	dispatchConfigurations()
+
+	// Copy the slice so callbacks are called without holding the lock.
+	initFuncsMu.Lock()
+	funcs := make([]initFunc, len(initFuncs))
+	copy(funcs, initFuncs)
+	initFuncsMu.Unlock()
+
+	for _, f := range funcs {
+		ctx := withName(context.Background(), f.name)
+		err := f.Init(ctx)
+		if err == nil {
+			continue
+		}
+
+		Errorf("%s plugin: Init() failed, disabling plugin: %v", f.name, err)
+
+		// Returning an error would make the daemon unregister the read
+		// callback of the plugin that registered wrap_init_callback,
+		// which may not be the failing one. Unregister the read
+		// callback explicitly instead.
+		cName := C.CString(f.name)
+		C.plugin_unregister_read_wrapper(cName)
+		C.free(unsafe.Pointer(cName))
+
+		readFuncsMu.Lock()
+		delete(readFuncs, f.name)
+		readFuncsMu.Unlock()
+	}
+
 	return 0
 }
 
@@ -5,10 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"reflect"
 	"testing"
 	"time"
 
 	"collectd.org/api"
+	"collectd.org/config"
 	"collectd.org/meta"
 	"collectd.org/plugin"
 	"collectd.org/plugin/fake"
@@ -30,6 +32,34 @@ func TestInterval(t *testing.T) {
 	}
 }
 
+func TestTimeout(t *testing.T) {
+	defer fake.TearDown()
+
+	cases := []struct {
+		title    string
+		interval time.Duration
+		want     time.Duration
+	}{
+		{"default", 10 * time.Second, 20 * time.Second},
+		{"custom interval", 42 * time.Second, 84 * time.Second},
+		{"zero interval", 0, 20 * time.Second},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			fake.SetInterval(tc.interval)
+
+			got, err := plugin.Timeout()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Timeout() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
 type testLogger struct {
 	Name string
 	plugin.Severity
@@ -356,6 +386,110 @@ func TestReadWrite(t *testing.T) {
 	}
 }
 
+type deadlineReader struct {
+	deadline time.Time
+	ok       bool
+}
+
+func (r *deadlineReader) Read(ctx context.Context) error {
+	r.deadline, r.ok = ctx.Deadline()
+	return nil
+}
+
+func TestRegisterRead_timeout(t *testing.T) {
+	cases := []struct {
+		title        string
+		opts         []plugin.ReadOption
+		wantDeadline bool
+		wantTimeout  time.Duration
+	}{
+		{
+			title:        "default",
+			wantDeadline: true,
+			wantTimeout:  20 * time.Second,
+		},
+		{
+			title:        "custom",
+			opts:         []plugin.ReadOption{plugin.WithTimeout(time.Minute)},
+			wantDeadline: true,
+			wantTimeout:  time.Minute,
+		},
+		{
+			title: "no timeout",
+			opts:  []plugin.ReadOption{plugin.WithoutTimeout()},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			defer fake.TearDown()
+
+			r := &deadlineReader{}
+			if err := plugin.RegisterRead("TestRegisterRead_timeout", r, tc.opts...); err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			if err := fake.ReadAll(); err != nil {
+				t.Fatal(err)
+			}
+
+			if r.ok != tc.wantDeadline {
+				t.Fatalf("ctx.Deadline() = (%v, %v), want a deadline: %v", r.deadline, r.ok, tc.wantDeadline)
+			}
+			if !tc.wantDeadline {
+				return
+			}
+
+			// Allow for the time passed between start and calling Read.
+			if got := r.deadline.Sub(start); got < tc.wantTimeout || got > tc.wantTimeout+time.Second {
+				t.Errorf("timeout = %v, want %v", got, tc.wantTimeout)
+			}
+		})
+	}
+}
+
+type intervalReader struct {
+	got []time.Duration
+}
+
+func (r *intervalReader) Read(ctx context.Context) error {
+	d, ok := plugin.ReadInterval(ctx)
+	if !ok {
+		return errors.New("plugin.ReadInterval() failed")
+	}
+	r.got = append(r.got, d)
+	return nil
+}
+
+func TestReadInterval(t *testing.T) {
+	defer fake.TearDown()
+
+	fast := &intervalReader{}
+	if err := plugin.RegisterRead("TestReadInterval_fast", fast, plugin.WithInterval(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	def := &intervalReader{}
+	if err := plugin.RegisterRead("TestReadInterval_default", def); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fake.ReadAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]time.Duration{time.Second}, fast.got); diff != "" {
+		t.Errorf("ReadInterval() differs (+got/-want):\n%s", diff)
+	}
+	if diff := cmp.Diff([]time.Duration{10 * time.Second}, def.got); diff != "" {
+		t.Errorf("ReadInterval() differs (+got/-want):\n%s", diff)
+	}
+
+	if _, ok := plugin.ReadInterval(context.Background()); ok {
+		t.Error("ReadInterval(context.Background()) succeeded, want failure")
+	}
+}
+
 type testReader struct {
 	vl       *api.ValueList
 	wantName string
@@ -402,6 +536,396 @@ func (w *testWriter) Write(ctx context.Context, vl *api.ValueList) error {
 	return nil
 }
 
+func TestHostname(t *testing.T) {
+	defer fake.TearDown()
+	fake.SetHostname("TestHostname.example.com")
+
+	got, err := plugin.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "TestHostname.example.com"; got != want {
+		t.Errorf("Hostname() = %q, want %q", got, want)
+	}
+
+	w := &testWriter{wantName: "TestHostname"}
+	if err := plugin.RegisterWrite("TestHostname", w); err != nil {
+		t.Fatal(err)
+	}
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Plugin: "TestHostname",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1587500000, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+	if err := plugin.Write(context.Background(), vl); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(w.valueLists) != 1 {
+		t.Fatalf("got %d value lists, want 1", len(w.valueLists))
+	}
+	if got, want := w.valueLists[0].Host, "TestHostname.example.com"; got != want {
+		t.Errorf("vl.Host = %q, want %q", got, want)
+	}
+	if vl.Host != "" {
+		t.Errorf("plugin.Write() modified its argument: vl.Host = %q", vl.Host)
+	}
+}
+
+func TestWrite_defaults(t *testing.T) {
+	defer fake.TearDown()
+	fake.SetInterval(42 * time.Second)
+
+	w := &testWriter{wantName: "TestWrite_defaults"}
+	if err := plugin.RegisterWrite("TestWrite_defaults", w); err != nil {
+		t.Fatal(err)
+	}
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestWrite_defaults",
+			Type:   "gauge",
+		},
+		Values: []api.Value{api.Gauge(42)},
+	}
+
+	before := time.Now()
+	if err := plugin.Write(context.Background(), vl); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now()
+
+	if len(w.valueLists) != 1 {
+		t.Fatalf("got %d value lists, want 1", len(w.valueLists))
+	}
+	got := w.valueLists[0]
+
+	// cdtime has a resolution of about one nanosecond, so allow for
+	// rounding.
+	if got.Time.Before(before.Add(-time.Microsecond)) || got.Time.After(after.Add(time.Microsecond)) {
+		t.Errorf("vl.Time = %v, want between %v and %v", got.Time, before, after)
+	}
+	if want := 42 * time.Second; got.Interval != want {
+		t.Errorf("vl.Interval = %v, want %v", got.Interval, want)
+	}
+
+	if !vl.Time.IsZero() || vl.Interval != 0 {
+		t.Errorf("plugin.Write() modified its argument: Time = %v, Interval = %v", vl.Time, vl.Interval)
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	defer fake.TearDown()
+
+	w := &testWriter{wantName: "TestWriteAll"}
+	if err := plugin.RegisterWrite("TestWriteAll", w); err != nil {
+		t.Fatal(err)
+	}
+
+	newVL := func(typ string, v api.Value, m meta.Data) *api.ValueList {
+		return &api.ValueList{
+			Identifier: api.Identifier{
+				Host:   "example.com",
+				Plugin: "TestWriteAll",
+				Type:   typ,
+			},
+			Time:     time.Unix(1587500000, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{v},
+			DSNames:  []string{"value"},
+			Meta:     m,
+		}
+	}
+
+	vls := []*api.ValueList{
+		newVL("gauge", api.Gauge(42), meta.Data{"key": meta.String("value")}),
+		// The fake plugin_dispatch_values() fails for unknown types.
+		newVL("invalid", api.Gauge(23), nil),
+		newVL("derive", api.Derive(-1), nil),
+		newVL("counter", api.Counter(1), meta.Data{"answer": meta.Int64(42)}),
+	}
+
+	if err := plugin.WriteAll(context.Background(), vls); err == nil {
+		t.Error("plugin.WriteAll() succeeded, want error")
+	}
+
+	want := []*api.ValueList{vls[0], vls[2], vls[3]}
+	opts := []cmp.Option{
+		// cmp complains about meta.Entry having private fields.
+		cmp.Transformer("meta.Entry", func(e meta.Entry) interface{} {
+			return e.Interface()
+		}),
+	}
+	if diff := cmp.Diff(want, w.valueLists, opts...); diff != "" {
+		t.Errorf("written value lists differ (+got/-want):\n%s", diff)
+	}
+}
+
+func BenchmarkWriteAll(b *testing.B) {
+	defer fake.TearDown()
+
+	w := api.WriterFunc(func(context.Context, *api.ValueList) error {
+		return nil
+	})
+	if err := plugin.RegisterWrite("BenchmarkWriteAll", w); err != nil {
+		b.Fatal(err)
+	}
+
+	vls := make([]*api.ValueList, 100)
+	for i := range vls {
+		vls[i] = &api.ValueList{
+			Identifier: api.Identifier{
+				Host:         "example.com",
+				Plugin:       "BenchmarkWriteAll",
+				Type:         "gauge",
+				TypeInstance: fmt.Sprintf("%d", i),
+			},
+			Time:     time.Unix(1587500000, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(i)},
+		}
+	}
+
+	ctx := context.Background()
+	b.Run("Write", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, vl := range vls {
+				if err := plugin.Write(ctx, vl); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("WriteAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := plugin.WriteAll(ctx, vls); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// cancelAfterFirstCheck is a context that is canceled as soon as it has been
+// checked for cancellation once.
+type cancelAfterFirstCheck struct {
+	context.Context
+	cancel context.CancelFunc
+	checks int
+}
+
+func (ctx *cancelAfterFirstCheck) Done() <-chan struct{} {
+	ctx.checks++
+	if ctx.checks > 1 {
+		ctx.cancel()
+	}
+	return ctx.Context.Done()
+}
+
+func TestWrite_canceled(t *testing.T) {
+	defer fake.TearDown()
+
+	w := &testWriter{wantName: "TestWrite_canceled"}
+	if err := plugin.RegisterWrite("TestWrite_canceled", w); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestWrite_canceled",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1587500000, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+
+	// The context is canceled after the initial check, i.e. while the
+	// value list is being prepared.
+	err := plugin.Write(&cancelAfterFirstCheck{Context: ctx, cancel: cancel}, vl)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("plugin.Write() = %v, want %v", err, context.Canceled)
+	}
+
+	if len(w.valueLists) != 0 {
+		t.Errorf("got %d value lists, want 0", len(w.valueLists))
+	}
+}
+
+func TestDataSet(t *testing.T) {
+	defer fake.TearDown()
+
+	var (
+		got *api.DataSet
+		ok  bool
+	)
+	w := api.WriterFunc(func(ctx context.Context, _ *api.ValueList) error {
+		got, ok = plugin.DataSet(ctx)
+		return nil
+	})
+	if err := plugin.RegisterWrite("TestDataSet", w); err != nil {
+		t.Fatal(err)
+	}
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestDataSet",
+			Type:   "derive",
+		},
+		Time:     time.Unix(1587500000, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Derive(42)},
+	}
+	if err := plugin.Write(context.Background(), vl); err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok {
+		t.Fatal("plugin.DataSet() returned false, want true")
+	}
+
+	// The "fake" package dispatches all values with a single data source
+	// "value" with min 0 and max NaN.
+	want := &api.DataSet{
+		Name: "derive",
+		Sources: []api.DataSource{
+			{
+				Name: "value",
+				Type: reflect.TypeOf(api.Derive(0)),
+				Min:  0,
+				Max:  math.NaN(),
+			},
+		},
+	}
+	opts := []cmp.Option{
+		cmp.Comparer(func(a, b reflect.Type) bool { return a == b }),
+		cmpopts.EquateNaNs(),
+	}
+	if diff := cmp.Diff(want, got, opts...); diff != "" {
+		t.Errorf("plugin.DataSet() differs (-want/+got):\n%s", diff)
+	}
+}
+
+type testFlusher struct {
+	name       string
+	timeout    time.Duration
+	identifier string
+	calls      int
+	err        error
+}
+
+func (f *testFlusher) Flush(ctx context.Context, timeout time.Duration, identifier string) error {
+	f.name, _ = plugin.Name(ctx)
+	f.timeout = timeout
+	f.identifier = identifier
+	f.calls++
+	return f.err
+}
+
+func TestRegisterFlush(t *testing.T) {
+	cases := []struct {
+		title      string
+		timeout    time.Duration
+		identifier string
+		flushErr   error
+		wantErr    bool
+	}{
+		{
+			title:   "success",
+			timeout: 30 * time.Second,
+		},
+		{
+			title:      "identifier",
+			identifier: "example.com/cpu-0/cpu-idle",
+		},
+		{
+			title:    "error",
+			flushErr: errors.New("test error"),
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			defer fake.TearDown()
+
+			f := &testFlusher{err: tc.flushErr}
+			if err := plugin.RegisterFlush("TestRegisterFlush", f); err != nil {
+				t.Fatal(err)
+			}
+
+			err := fake.Flush(tc.timeout, tc.identifier)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("fake.Flush() = %v, want error %v", err, tc.wantErr)
+			}
+
+			if f.calls != 1 {
+				t.Fatalf("Flush() called %d times, want 1", f.calls)
+			}
+			if got, want := f.name, "TestRegisterFlush"; got != want {
+				t.Errorf("Name(ctx) = %q, want %q", got, want)
+			}
+			if f.timeout != tc.timeout {
+				t.Errorf("timeout = %v, want %v", f.timeout, tc.timeout)
+			}
+			if f.identifier != tc.identifier {
+				t.Errorf("identifier = %q, want %q", f.identifier, tc.identifier)
+			}
+		})
+	}
+}
+
+// TestRegisterWrite_concurrent registers write callbacks while values are
+// being dispatched. Run with "-race" to detect unsynchronized map accesses.
+func TestRegisterWrite_concurrent(t *testing.T) {
+	defer fake.TearDown()
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestRegisterWrite",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1587500000, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if err := plugin.Write(ctx, vl.Clone()); err != nil {
+				t.Errorf("plugin.Write() = %v", err)
+			}
+		}
+	}()
+
+	w := api.WriterFunc(func(context.Context, *api.ValueList) error {
+		return nil
+	})
+	for i := 0; i < 100; i++ {
+		if err := plugin.RegisterWrite(fmt.Sprintf("concurrent_%d", i), w); err != nil {
+			t.Errorf("RegisterWrite() = %v", err)
+		}
+	}
+
+	<-done
+}
+
 func TestShutdown(t *testing.T) {
 	// NOTE: fake.TearDown() will remove all callbacks from the C code's state. plugin.shutdownFuncs will still hold
 	// a reference to the registered shutdown calls, preventing it from registering another C callback in later
@@ -436,6 +960,200 @@ func TestShutdown(t *testing.T) {
 	}
 }
 
+type testInitializer struct {
+	wantName string
+	err      error
+	calls    int
+}
+
+func (i *testInitializer) Init(ctx context.Context) error {
+	i.calls++
+	if name, ok := plugin.Name(ctx); !ok || name != i.wantName {
+		return fmt.Errorf("plugin.Name() = (%q, %v), want (%q, %v)", name, ok, i.wantName, true)
+	}
+	return i.err
+}
+
+func TestRegisterInit(t *testing.T) {
+	// NOTE: like shutdown callbacks, the C init callback is only registered
+	// once. Don't use init callbacks in any other test. Since configuration
+	// is dispatched by the init callback, RegisterComplexConfig is tested
+	// here, too.
+	defer fake.TearDown()
+
+	cfg := config.Block{
+		Key:    "Plugin",
+		Values: config.Values("TestRegisterInit"),
+		Children: []config.Block{
+			{
+				Key:    "Server",
+				Values: config.Values("example.com", 25826),
+				Children: []config.Block{
+					{Key: "Username", Values: config.Values("user")},
+					{Key: "Enabled", Values: config.Values(true)},
+				},
+			},
+			{Key: "Timeout", Values: config.Values(1.5)},
+		},
+	}
+	var gotConfig []config.Block
+	err := plugin.RegisterComplexConfig("TestRegisterInit", func(ctx context.Context, blocks []config.Block) error {
+		if name, ok := plugin.Name(ctx); !ok || name != "TestRegisterInit" {
+			t.Errorf("plugin.Name() = (%q, %v), want (%q, %v)", name, ok, "TestRegisterInit", true)
+		}
+		gotConfig = blocks
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.Configure("TestRegisterInit", cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	ok := &testInitializer{wantName: "TestRegisterInit"}
+	failing := &testInitializer{
+		wantName: "TestRegisterInit_failing",
+		err:      errors.New("test error"),
+	}
+
+	for _, r := range []string{"TestRegisterInit", "TestRegisterInit_failing"} {
+		if err := plugin.RegisterRead(r, &testReader{wantName: r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := plugin.RegisterInit("TestRegisterInit", ok); err != nil {
+		t.Fatal(err)
+	}
+	if err := plugin.RegisterInit("TestRegisterInit_failing", failing); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fake.InitAll(); err != nil {
+		t.Errorf("fake.InitAll() = %v", err)
+	}
+
+	if diff := cmp.Diff([]config.Block{cfg}, gotConfig, cmp.AllowUnexported(config.Value{})); diff != "" {
+		t.Errorf("RegisterComplexConfig() callback received blocks differ (+got/-want):\n%s", diff)
+	}
+
+	for _, i := range []*testInitializer{ok, failing} {
+		if i.calls != 1 {
+			t.Errorf("%s: Init() called %d times, want 1", i.wantName, i.calls)
+		}
+	}
+
+	// The read callback of the failing plugin must have been unregistered.
+	var got []string
+	for _, cb := range fake.ReadCallbacks() {
+		got = append(got, cb.Name)
+	}
+	if diff := cmp.Diff([]string{"TestRegisterInit"}, got); diff != "" {
+		t.Errorf("fake.ReadCallbacks() differs (+got/-want):\n%s", diff)
+	}
+}
+
+type testNotifier struct {
+	name string
+	got  []api.Notification
+}
+
+func (n *testNotifier) Notify(ctx context.Context, notif api.Notification) error {
+	n.name, _ = plugin.Name(ctx)
+	n.got = append(n.got, notif)
+	return nil
+}
+
+func TestRegisterNotification(t *testing.T) {
+	defer fake.TearDown()
+
+	n := &testNotifier{}
+	if err := plugin.RegisterNotification("TestRegisterNotification", n); err != nil {
+		t.Fatal(err)
+	}
+
+	want := api.Notification{
+		Identifier: api.Identifier{
+			Host:           "example.com",
+			Plugin:         "TestRegisterNotification",
+			PluginInstance: "test",
+			Type:           "gauge",
+			TypeInstance:   "value",
+		},
+		Time:     time.Unix(1587671455, 0),
+		Severity: api.Warning,
+		Message:  "Value out of range",
+		Meta: meta.Data{
+			"bool":   meta.Bool(true),
+			"float":  meta.Float64(1.5),
+			"int":    meta.Int64(-42),
+			"string": meta.String("foo"),
+			"uint":   meta.UInt64(42),
+		},
+	}
+
+	if err := fake.DispatchNotification(want); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := n.name, "TestRegisterNotification"; got != want {
+		t.Errorf("Name(ctx) = %q, want %q", got, want)
+	}
+	opts := []cmp.Option{
+		cmp.AllowUnexported(meta.Entry{}),
+	}
+	if diff := cmp.Diff([]api.Notification{want}, n.got, opts...); diff != "" {
+		t.Errorf("received notifications differ (+got/-want):\n%s", diff)
+	}
+}
+
+func TestDispatchNotification(t *testing.T) {
+	defer fake.TearDown()
+
+	n := &testNotifier{}
+	if err := plugin.RegisterNotification("TestDispatchNotification", n); err != nil {
+		t.Fatal(err)
+	}
+
+	want := api.Notification{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestDispatchNotification",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1587671455, 0),
+		Severity: api.Failure,
+		Message:  "Value out of range",
+		Meta: meta.Data{
+			"bool":   meta.Bool(true),
+			"float":  meta.Float64(1.5),
+			"int":    meta.Int64(-42),
+			"string": meta.String("foo"),
+			"uint":   meta.UInt64(42),
+		},
+	}
+
+	ctx := context.Background()
+	if err := plugin.DispatchNotification(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := []cmp.Option{
+		cmp.AllowUnexported(meta.Entry{}),
+	}
+	if diff := cmp.Diff([]api.Notification{want}, n.got, opts...); diff != "" {
+		t.Errorf("received notifications differ (+got/-want):\n%s", diff)
+	}
+
+	// Without a plugin name in the notification or the context,
+	// DispatchNotification must fail.
+	noPlugin := want
+	noPlugin.Plugin = ""
+	if err := plugin.DispatchNotification(ctx, noPlugin); err == nil {
+		t.Error("DispatchNotification() succeeded, want error")
+	}
+}
+
 type testShutter struct {
 	wantName  string
 	callCount int
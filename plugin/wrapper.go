@@ -40,11 +40,31 @@ package plugin // import "collectd.org/plugin"
 // static int (*plugin_register_complex_read_ptr)(meta_data_t *, char const *,
 //                                                plugin_read_cb, cdtime_t,
 //                                                user_data_t *);
+// static int (*plugin_register_flush_ptr)(char const *, plugin_flush_cb,
+//                                         user_data_t *);
+// static int (*plugin_dispatch_notification_ptr)(notification_t const *);
+// static int (*plugin_notification_meta_add_boolean_ptr)(notification_t *,
+//                                                       char const *, bool);
+// static int (*plugin_notification_meta_add_double_ptr)(notification_t *,
+//                                                      char const *, double);
+// static int (*plugin_notification_meta_add_signed_int_ptr)(notification_t *,
+//                                                          char const *,
+//                                                          int64_t);
+// static int (*plugin_notification_meta_add_string_ptr)(notification_t *,
+//                                                      char const *,
+//                                                      char const *);
+// static int (*plugin_notification_meta_add_unsigned_int_ptr)(
+//     notification_t *, char const *, uint64_t);
+// static int (*plugin_notification_meta_free_ptr)(notification_meta_t *);
+// static int (*plugin_register_notification_ptr)(char const *,
+//                                                plugin_notification_cb,
+//                                                user_data_t *);
 // static int (*plugin_register_log_ptr)(char const *, plugin_log_cb,
 //                                       user_data_t *);
 // static int (*plugin_register_shutdown_ptr)(char const *, plugin_shutdown_cb);
 // static int (*plugin_register_write_ptr)(char const *, plugin_write_cb,
 //                                         user_data_t *);
+// static int (*plugin_unregister_read_ptr)(char const *);
 //
 // int meta_data_add_boolean_wrapper(meta_data_t *md, char const *key,
 //                                   bool value) {
@@ -144,12 +164,70 @@ package plugin // import "collectd.org/plugin"
 //                                              ud);
 // }
 //
+// int plugin_register_flush_wrapper(char const *name, plugin_flush_cb callback,
+//                                   user_data_t *ud) {
+//   LOAD(plugin_register_flush);
+//   return (*plugin_register_flush_ptr)(name, callback, ud);
+// }
+//
 // int plugin_register_log_wrapper(char const *name, plugin_log_cb callback,
 //                                 user_data_t *ud) {
 //   LOAD(plugin_register_log);
 //   return (*plugin_register_log_ptr)(name, callback, ud);
 // }
 //
+// int plugin_dispatch_notification_wrapper(notification_t const *n) {
+//   LOAD(plugin_dispatch_notification);
+//   return (*plugin_dispatch_notification_ptr)(n);
+// }
+//
+// int plugin_notification_meta_add_boolean_wrapper(notification_t *n,
+//                                                  char const *name,
+//                                                  bool value) {
+//   LOAD(plugin_notification_meta_add_boolean);
+//   return (*plugin_notification_meta_add_boolean_ptr)(n, name, value);
+// }
+//
+// int plugin_notification_meta_add_double_wrapper(notification_t *n,
+//                                                 char const *name,
+//                                                 double value) {
+//   LOAD(plugin_notification_meta_add_double);
+//   return (*plugin_notification_meta_add_double_ptr)(n, name, value);
+// }
+//
+// int plugin_notification_meta_add_signed_int_wrapper(notification_t *n,
+//                                                     char const *name,
+//                                                     int64_t value) {
+//   LOAD(plugin_notification_meta_add_signed_int);
+//   return (*plugin_notification_meta_add_signed_int_ptr)(n, name, value);
+// }
+//
+// int plugin_notification_meta_add_string_wrapper(notification_t *n,
+//                                                 char const *name,
+//                                                 char const *value) {
+//   LOAD(plugin_notification_meta_add_string);
+//   return (*plugin_notification_meta_add_string_ptr)(n, name, value);
+// }
+//
+// int plugin_notification_meta_add_unsigned_int_wrapper(notification_t *n,
+//                                                       char const *name,
+//                                                       uint64_t value) {
+//   LOAD(plugin_notification_meta_add_unsigned_int);
+//   return (*plugin_notification_meta_add_unsigned_int_ptr)(n, name, value);
+// }
+//
+// int plugin_notification_meta_free_wrapper(notification_meta_t *m) {
+//   LOAD(plugin_notification_meta_free);
+//   return (*plugin_notification_meta_free_ptr)(m);
+// }
+//
+// int plugin_register_notification_wrapper(char const *name,
+//                                          plugin_notification_cb callback,
+//                                          user_data_t *ud) {
+//   LOAD(plugin_register_notification);
+//   return (*plugin_register_notification_ptr)(name, callback, ud);
+// }
+//
 // int plugin_register_shutdown_wrapper(char const *name,
 //                                      plugin_shutdown_cb callback) {
 //   LOAD(plugin_register_shutdown);
@@ -161,4 +239,9 @@ package plugin // import "collectd.org/plugin"
 //   LOAD(plugin_register_write);
 //   return (*plugin_register_write_ptr)(name, callback, ud);
 // }
+//
+// int plugin_unregister_read_wrapper(char const *name) {
+//   LOAD(plugin_unregister_read);
+//   return (*plugin_unregister_read_ptr)(name);
+// }
 import "C"
@@ -0,0 +1,117 @@
+package rpc // import "collectd.org/rpc"
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+
+	"collectd.org/api"
+)
+
+// cacheServer implements Interface by keeping the most recent value list of
+// each metric in memory.
+type cacheServer struct {
+	mu  sync.RWMutex
+	vls map[api.Identifier]*api.ValueList
+}
+
+// NewCacheServer returns an Interface that keeps the most recent value list of
+// each metric in memory, similar to collectd's value cache. Write adds value
+// lists to the cache, Query returns matching value lists from it. Together
+// with RegisterServer or ListenAndServe, this provides a working gRPC endpoint
+// without further code.
+//
+// The fields of the identifier passed to Query are shell patterns, as
+// understood by "path".Match, e.g. "cpu-*". Empty fields match everything.
+//
+// The returned server also implements Lister, so that List does not need to
+// copy the cached value lists.
+func NewCacheServer() Interface {
+	return &cacheServer{
+		vls: make(map[api.Identifier]*api.ValueList),
+	}
+}
+
+// Write stores a copy of vl in the cache. Value lists that are older than the
+// cached value list of the same metric are ignored.
+func (s *cacheServer) Write(_ context.Context, vl *api.ValueList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, ok := s.vls[vl.Identifier]; ok && vl.Time.Before(cached.Time) {
+		return nil
+	}
+
+	s.vls[vl.Identifier] = vl.Clone()
+	return nil
+}
+
+// Query returns all cached value lists matching id, sorted by identifier.
+func (s *cacheServer) Query(ctx context.Context, id *api.Identifier) (<-chan *api.ValueList, error) {
+	if err := checkPatterns(*id); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var res []*api.ValueList
+	for cachedID, vl := range s.vls {
+		if cachedID.Match(*id) {
+			res = append(res, vl.Clone())
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Identifier.String() < res[j].Identifier.String()
+	})
+
+	ch := make(chan *api.ValueList)
+	go func() {
+		defer close(ch)
+		for _, vl := range res {
+			select {
+			case ch <- vl:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// List returns the identifiers of all cached value lists matching id, sorted
+// by identifier.
+func (s *cacheServer) List(ctx context.Context, id api.Identifier) ([]api.Identifier, error) {
+	if err := checkPatterns(id); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	var ids []api.Identifier
+	for cachedID := range s.vls {
+		if cachedID.Match(id) {
+			ids = append(ids, cachedID)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	return ids, ctx.Err()
+}
+
+// checkPatterns returns an error if any field of id is not a valid pattern.
+func checkPatterns(id api.Identifier) error {
+	patterns := []string{id.Host, id.Plugin, id.PluginInstance, id.Type, id.TypeInstance}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package rpc // import "collectd.org/rpc"
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCacheServer(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Unix(1588164686, 0)
+
+	newVL := func(host, plugin, pluginInstance string, tm time.Time, v api.Gauge) *api.ValueList {
+		return &api.ValueList{
+			Identifier: api.Identifier{
+				Host:           host,
+				Plugin:         plugin,
+				PluginInstance: pluginInstance,
+				Type:           "gauge",
+			},
+			Time:     tm,
+			Interval: 10 * time.Second,
+			Values:   []api.Value{v},
+		}
+	}
+
+	s := NewCacheServer()
+	for _, vl := range []*api.ValueList{
+		newVL("a.example.com", "cpu", "0", t0, 1),
+		newVL("a.example.com", "cpu", "1", t0, 2),
+		newVL("b.example.com", "cpu", "0", t0, 3),
+		newVL("b.example.com", "memory", "", t0, 4),
+		// newer value list replaces the cached one
+		newVL("a.example.com", "cpu", "0", t0.Add(10*time.Second), 5),
+		// older value list is ignored
+		newVL("b.example.com", "cpu", "0", t0.Add(-10*time.Second), 6),
+	} {
+		if err := s.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		title   string
+		id      api.Identifier
+		want    []api.Gauge
+		wantErr bool
+	}{
+		{
+			title: "all",
+			id:    api.Identifier{},
+			want:  []api.Gauge{5, 2, 3, 4},
+		},
+		{
+			title: "host wildcard",
+			id:    api.Identifier{Host: "*", Plugin: "cpu"},
+			want:  []api.Gauge{5, 2, 3},
+		},
+		{
+			title: "pattern",
+			id:    api.Identifier{Host: "a.*", PluginInstance: "[1-9]"},
+			want:  []api.Gauge{2},
+		},
+		{
+			title: "exact",
+			id:    api.Identifier{Host: "b.example.com", Plugin: "memory", Type: "gauge"},
+			want:  []api.Gauge{4},
+		},
+		{
+			title: "no match",
+			id:    api.Identifier{Plugin: "disk"},
+		},
+		{
+			title:   "invalid pattern",
+			id:      api.Identifier{Host: "["},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ch, err := s.Query(ctx, &tc.id)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Query() = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			var (
+				got    []api.Gauge
+				wantID []api.Identifier
+			)
+			for vl := range ch {
+				got = append(got, vl.Values[0].(api.Gauge))
+				wantID = append(wantID, vl.Identifier)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Query() differs (+got/-want):\n%s", diff)
+			}
+
+			// List uses the cache's Lister implementation and must
+			// agree with Query.
+			if _, ok := s.(Lister); !ok {
+				t.Fatalf("%T does not implement Lister", s)
+			}
+			gotID, err := List(ctx, s, tc.id)
+			if err != nil {
+				t.Fatalf("List() = %v", err)
+			}
+			if diff := cmp.Diff(wantID, gotID); diff != "" {
+				t.Errorf("List() differs (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
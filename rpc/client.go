@@ -8,18 +8,39 @@ import (
 	"collectd.org/api"
 	pb "collectd.org/rpc/proto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// Type client implements rpc.Interface using a gRPC stub.
+// Client extends Interface with client-only functionality.
+type Client interface {
+	Interface
+	// WriteStream opens a stream for writing many value lists
+	// efficiently. The returned StreamWriter must be closed.
+	WriteStream(ctx context.Context) (StreamWriter, error)
+	// Close closes the underlying client connection.
+	Close() error
+}
+
+// StreamWriter writes value lists to a single gRPC stream.
+type StreamWriter interface {
+	api.Writer
+	// Close closes the stream and returns the server's error, if any.
+	Close() error
+}
+
+// Type client implements rpc.Client using a gRPC stub.
 type client struct {
 	pb.CollectdClient
+	conn *grpc.ClientConn
 }
 
 // NewClient returns a wrapper around the gRPC client connection that maps
 // between the Go interface and the gRPC interface.
-func NewClient(conn *grpc.ClientConn) Interface {
+func NewClient(conn *grpc.ClientConn) Client {
 	return &client{
 		CollectdClient: pb.NewCollectdClient(conn),
+		conn:           conn,
 	}
 }
 
@@ -67,26 +88,99 @@ func (c *client) Query(ctx context.Context, id *api.Identifier) (<-chan *api.Val
 	return ch, nil
 }
 
-// Write maps its arguments to a PutValuesRequest and calls PutValues.
+// List maps its arguments to a ListValuesRequest object and calls ListValues,
+// so that only identifiers are transferred. If the server does not implement
+// ListValues, e.g. collectd's gRPC plugin, List falls back to using Query.
+func (c *client) List(ctx context.Context, id api.Identifier) ([]api.Identifier, error) {
+	stream, err := c.ListValues(ctx, &pb.ListValuesRequest{
+		Identifier: MarshalIdentifier(&id),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []api.Identifier
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if status.Code(err) == codes.Unimplemented {
+			return listByQuery(ctx, c, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, *UnmarshalIdentifier(res.GetIdentifier()))
+	}
+
+	return ids, nil
+}
+
+// Close closes the client connection.
+func (c *client) Close() error {
+	return c.conn.Close()
+}
+
+// Write sends vl to the server using a stream with a single value list. Use
+// WriteStream when writing many value lists.
 func (c *client) Write(ctx context.Context, vl *api.ValueList) error {
-	pbVL, err := MarshalValueList(vl)
+	s, err := c.WriteStream(ctx)
 	if err != nil {
 		return err
 	}
 
+	if err := s.Write(ctx, vl); err != nil {
+		s.Close()
+		return err
+	}
+
+	return s.Close()
+}
+
+// WriteStream calls PutValues and returns a StreamWriter sending value lists
+// on the resulting stream.
+func (c *client) WriteStream(ctx context.Context) (StreamWriter, error) {
 	stream, err := c.PutValues(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req := &pb.PutValuesRequest{
-		ValueList: pbVL,
+	return &streamWriter{stream: stream}, nil
+}
+
+type streamWriter struct {
+	stream pb.Collectd_PutValuesClient
+}
+
+// Write maps its arguments to a PutValuesRequest and sends it on the stream.
+// The stream's context, i.e. the context passed to WriteStream, determines
+// cancellation.
+func (s *streamWriter) Write(ctx context.Context, vl *api.ValueList) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	if err := stream.Send(req); err != nil {
-		stream.CloseSend()
+
+	pbVL, err := MarshalValueList(vl)
+	if err != nil {
 		return err
 	}
 
-	_, err = stream.CloseAndRecv()
+	err = s.stream.Send(&pb.PutValuesRequest{
+		ValueList: pbVL,
+	})
+	if err == io.EOF {
+		// The server closed the stream; the actual error is returned
+		// by CloseAndRecv.
+		_, err = s.stream.CloseAndRecv()
+	}
+	return err
+}
+
+// Close closes the sending side of the stream and waits for the server's
+// response.
+func (s *streamWriter) Close() error {
+	_, err := s.stream.CloseAndRecv()
 	return err
 }
@@ -0,0 +1,67 @@
+package rpc // import "collectd.org/rpc"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+// recordingWriter records the TypeInstance of all value lists written to it.
+type recordingWriter struct {
+	mu  sync.Mutex
+	got []string
+}
+
+func (w *recordingWriter) Write(_ context.Context, vl *api.ValueList) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.got = append(w.got, vl.TypeInstance)
+	return nil
+}
+
+func TestClient_WriteStream(t *testing.T) {
+	ctx := context.Background()
+
+	w := &recordingWriter{}
+	c := NewClient(newTestConn(t, &testInterface{Writer: w}))
+
+	s, err := c.WriteStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []string
+	for i := 0; i < 100; i++ {
+		vl := &api.ValueList{
+			Identifier: api.Identifier{
+				Host:         "example.com",
+				Plugin:       "TestClient_WriteStream",
+				Type:         "gauge",
+				TypeInstance: fmt.Sprintf("%03d", i),
+			},
+			Time:     time.Unix(1588164686, 0),
+			Interval: 10 * time.Second,
+			Values:   []api.Value{api.Gauge(i)},
+		}
+		if err := s.Write(ctx, vl); err != nil {
+			t.Fatalf("StreamWriter.Write() = %v", err)
+		}
+		want = append(want, vl.TypeInstance)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("StreamWriter.Close() = %v", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if diff := cmp.Diff(want, w.got); diff != "" {
+		t.Errorf("received value lists differ (+got/-want):\n%s", diff)
+	}
+}
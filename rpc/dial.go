@@ -0,0 +1,101 @@
+package rpc // import "collectd.org/rpc"
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// DialOptions holds configuration options for Dial.
+type DialOptions struct {
+	// TLSConfig is used to secure the connection. For mutual TLS, set
+	// Certificates, too. If nil, the connection is not encrypted.
+	TLSConfig *tls.Config
+	// Token, if not empty, is sent as a bearer token with every RPC. A
+	// token requires TLSConfig to be set.
+	Token string
+	// Keepalive configures client-side keepalive pings. If zero, no pings
+	// are sent.
+	Keepalive keepalive.ClientParameters
+}
+
+// Dial connects to the gRPC server at target and returns a client for it.
+// Dial blocks until the connection is established or ctx is done. TLS
+// handshake failures, e.g. an untrusted certificate, are not retried: Dial
+// returns the handshake error right away. Call Close on the returned client to
+// close the connection.
+func Dial(ctx context.Context, target string, opts DialOptions) (Client, error) {
+	dialOpts := []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.FailOnNonTempDialError(true),
+		grpc.WithReturnConnectionError(),
+	}
+
+	if opts.TLSConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(failFastCreds{credentials.NewTLS(opts.TLSConfig)}))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if opts.Token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenAuth(opts.Token)))
+	}
+
+	if opts.Keepalive != (keepalive.ClientParameters{}) {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(opts.Keepalive))
+	}
+
+	conn, err := grpc.DialContext(ctx, target, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(conn), nil
+}
+
+// failFastCreds wraps transport credentials so that handshake errors are
+// reported as permanent. gRPC treats errors without a Temporary method as
+// temporary and would retry the handshake until the context expires.
+type failFastCreds struct {
+	credentials.TransportCredentials
+}
+
+func (c failFastCreds) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, info, err := c.TransportCredentials.ClientHandshake(ctx, authority, conn)
+	if err != nil {
+		return nil, nil, handshakeError{err}
+	}
+	return conn, info, nil
+}
+
+func (c failFastCreds) Clone() credentials.TransportCredentials {
+	return failFastCreds{c.TransportCredentials.Clone()}
+}
+
+// handshakeError marks a TLS handshake error as not temporary.
+type handshakeError struct {
+	err error
+}
+
+func (e handshakeError) Error() string   { return e.err.Error() }
+func (e handshakeError) Unwrap() error   { return e.err }
+func (e handshakeError) Temporary() bool { return false }
+
+// tokenAuth implements credentials.PerRPCCredentials by sending a bearer token
+// in the "authorization" header.
+type tokenAuth string
+
+func (t tokenAuth) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{
+		"authorization": "Bearer " + string(t),
+	}, nil
+}
+
+func (t tokenAuth) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,152 @@
+package rpc // import "collectd.org/rpc"
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// newTestCert returns a self-signed certificate for "localhost" and a pool
+// trusting it.
+func newTestCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}, pool
+}
+
+// authWriter records the "authorization" header of each Write call.
+type authWriter struct {
+	mu   sync.Mutex
+	auth []string
+}
+
+func (w *authWriter) Write(ctx context.Context, _ *api.ValueList) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.auth = append(w.auth, md.Get("authorization")...)
+	return nil
+}
+
+func TestDial(t *testing.T) {
+	cert, pool := newTestCert(t)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	w := &authWriter{}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	RegisterServer(srv, &testInterface{Writer: w})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cases := []struct {
+		title      string
+		serverName string
+		rootCAs    *x509.CertPool
+		wantErr    bool
+	}{
+		{"success", "localhost", pool, false},
+		{"name mismatch", "wrong.example.com", pool, true},
+		{"unknown authority", "localhost", x509.NewCertPool(), true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			start := time.Now()
+			c, err := Dial(ctx, lis.Addr().String(), DialOptions{
+				TLSConfig: &tls.Config{
+					ServerName: tc.serverName,
+					RootCAs:    tc.rootCAs,
+				},
+				Token: "s3cr3t",
+			})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("Dial() = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				// Handshake failures must not be retried until ctx expires.
+				if d := time.Since(start); d > time.Second {
+					t.Errorf("Dial() took %v to fail, want less than 1s", d)
+				}
+				return
+			}
+			defer c.Close()
+
+			vl := &api.ValueList{
+				Identifier: api.Identifier{
+					Host:   "example.com",
+					Plugin: "TestDial",
+					Type:   "gauge",
+				},
+				Time:     time.Unix(1588164686, 0),
+				Interval: 10 * time.Second,
+				Values:   []api.Value{api.Gauge(42)},
+			}
+			if err := c.Write(ctx, vl); err != nil {
+				t.Fatalf("Write() = %v", err)
+			}
+
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			if got, want := fmt.Sprint(w.auth), "[Bearer s3cr3t]"; got != want {
+				t.Errorf("authorization = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -23,6 +23,10 @@ func MarshalValue(v api.Value) (*pb.Value, error) {
 		return &pb.Value{
 			Value: &pb.Value_Gauge{Gauge: float64(v)},
 		}, nil
+	case api.Absolute:
+		return &pb.Value{
+			Value: &pb.Value_Absolute{Absolute: uint64(v)},
+		}, nil
 	default:
 		return nil, grpc.Errorf(codes.InvalidArgument, "%T values are not supported", v)
 	}
@@ -37,6 +41,8 @@ func UnmarshalValue(in *pb.Value) (api.Value, error) {
 		return api.Derive(v.Derive), nil
 	case *pb.Value_Gauge:
 		return api.Gauge(v.Gauge), nil
+	case *pb.Value_Absolute:
+		return api.Absolute(v.Absolute), nil
 	default:
 		return nil, grpc.Errorf(codes.InvalidArgument, "%T values are not supported", v)
 	}
@@ -64,7 +70,8 @@ func UnmarshalIdentifier(in *pb.Identifier) *api.Identifier {
 	}
 }
 
-// MarshalValueList converts an api.ValueList to a pb.ValueList.
+// MarshalValueList converts an api.ValueList to a pb.ValueList. The protocol
+// has no field for per-value meta data, so vl.ValueMeta is not transmitted.
 func MarshalValueList(vl *api.ValueList) (*pb.ValueList, error) {
 	t, err := ptypes.TimestampProto(vl.Time)
 	if err != nil {
@@ -86,6 +93,7 @@ func MarshalValueList(vl *api.ValueList) (*pb.ValueList, error) {
 		Time:       t,
 		Interval:   ptypes.DurationProto(vl.Interval),
 		Identifier: MarshalIdentifier(&vl.Identifier),
+		DsNames:    vl.DSNames,
 	}, nil
 }
 
@@ -111,11 +119,18 @@ func UnmarshalValueList(in *pb.ValueList) (*api.ValueList, error) {
 		values = append(values, v)
 	}
 
+	// Leave DSNames nil if no names were sent, so that
+	// api.ValueList.DSName() provides default names.
+	var dsNames []string
+	if len(in.GetDsNames()) != 0 {
+		dsNames = in.GetDsNames()
+	}
+
 	return &api.ValueList{
 		Identifier: *UnmarshalIdentifier(in.GetIdentifier()),
 		Time:       t,
 		Interval:   interval,
 		Values:     values,
-		DSNames:    in.DsNames,
+		DSNames:    dsNames,
 	}, nil
 }
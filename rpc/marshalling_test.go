@@ -0,0 +1,85 @@
+package rpc // import "collectd.org/rpc"
+
+import (
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestValueList_Roundtrip(t *testing.T) {
+	cases := []struct {
+		title   string
+		values  []api.Value
+		dsNames []string
+	}{
+		{
+			title:   "explicit names",
+			values:  []api.Value{api.Derive(1), api.Derive(2)},
+			dsNames: []string{"rx", "tx"},
+		},
+		{
+			title:  "implicit name",
+			values: []api.Value{api.Gauge(42)},
+		},
+		{
+			title:   "all types",
+			values:  []api.Value{api.Counter(1), api.Derive(-2), api.Gauge(3.5), api.Absolute(4)},
+			dsNames: []string{"counter", "derive", "gauge", "absolute"},
+		},
+		{
+			title:  "implicit names",
+			values: []api.Value{api.Derive(1), api.Derive(2)},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			want := &api.ValueList{
+				Identifier: api.Identifier{
+					Host:   "example.com",
+					Plugin: "TestValueList_Roundtrip",
+					Type:   "if_octets",
+				},
+				Time:     time.Unix(1588164686, 0).UTC(),
+				Interval: 10 * time.Second,
+				Values:   tc.values,
+				DSNames:  tc.dsNames,
+			}
+
+			pbVL, err := MarshalValueList(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := proto.Marshal(pbVL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pbVL.Reset()
+			if err := proto.Unmarshal(data, pbVL); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := UnmarshalValueList(pbVL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("value lists differ (+got/-want):\n%s", diff)
+			}
+			if (got.DSNames == nil) != (want.DSNames == nil) {
+				t.Errorf("got.DSNames = %#v, want %#v", got.DSNames, want.DSNames)
+			}
+			for i := range want.Values {
+				if got, want := got.DSName(i), want.DSName(i); got != want {
+					t.Errorf("DSName(%d) = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -13,6 +13,8 @@ It has these top-level messages:
 	PutValuesResponse
 	QueryValuesRequest
 	QueryValuesResponse
+	ListValuesRequest
+	ListValuesResponse
 */
 package proto
 
@@ -101,11 +103,50 @@ func (m *QueryValuesResponse) GetValueList() *collectd_types.ValueList {
 	return nil
 }
 
+// The arguments to ListValues.
+type ListValuesRequest struct {
+	// Query by the fields of the identifier. Only return identifiers
+	// matching the specified shell wildcard patterns (see fnmatch(3)). Use
+	// '*' to match any value.
+	Identifier *collectd_types.Identifier `protobuf:"bytes,1,opt,name=identifier" json:"identifier,omitempty"`
+}
+
+func (m *ListValuesRequest) Reset()                    { *m = ListValuesRequest{} }
+func (m *ListValuesRequest) String() string            { return proto1.CompactTextString(m) }
+func (*ListValuesRequest) ProtoMessage()               {}
+func (*ListValuesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ListValuesRequest) GetIdentifier() *collectd_types.Identifier {
+	if m != nil {
+		return m.Identifier
+	}
+	return nil
+}
+
+// The response from ListValues.
+type ListValuesResponse struct {
+	Identifier *collectd_types.Identifier `protobuf:"bytes,1,opt,name=identifier" json:"identifier,omitempty"`
+}
+
+func (m *ListValuesResponse) Reset()                    { *m = ListValuesResponse{} }
+func (m *ListValuesResponse) String() string            { return proto1.CompactTextString(m) }
+func (*ListValuesResponse) ProtoMessage()               {}
+func (*ListValuesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *ListValuesResponse) GetIdentifier() *collectd_types.Identifier {
+	if m != nil {
+		return m.Identifier
+	}
+	return nil
+}
+
 func init() {
 	proto1.RegisterType((*PutValuesRequest)(nil), "collectd.PutValuesRequest")
 	proto1.RegisterType((*PutValuesResponse)(nil), "collectd.PutValuesResponse")
 	proto1.RegisterType((*QueryValuesRequest)(nil), "collectd.QueryValuesRequest")
 	proto1.RegisterType((*QueryValuesResponse)(nil), "collectd.QueryValuesResponse")
+	proto1.RegisterType((*ListValuesRequest)(nil), "collectd.ListValuesRequest")
+	proto1.RegisterType((*ListValuesResponse)(nil), "collectd.ListValuesResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -126,6 +167,9 @@ type CollectdClient interface {
 	// QueryValues returns a stream of matching value lists from collectd's
 	// internal cache.
 	QueryValues(ctx context.Context, in *QueryValuesRequest, opts ...grpc.CallOption) (Collectd_QueryValuesClient, error)
+	// ListValues returns a stream of the identifiers of matching value
+	// lists, without their values.
+	ListValues(ctx context.Context, in *ListValuesRequest, opts ...grpc.CallOption) (Collectd_ListValuesClient, error)
 }
 
 type collectdClient struct {
@@ -202,6 +246,38 @@ func (x *collectdQueryValuesClient) Recv() (*QueryValuesResponse, error) {
 	return m, nil
 }
 
+func (c *collectdClient) ListValues(ctx context.Context, in *ListValuesRequest, opts ...grpc.CallOption) (Collectd_ListValuesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Collectd_serviceDesc.Streams[2], c.cc, "/collectd.Collectd/ListValues", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &collectdListValuesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Collectd_ListValuesClient interface {
+	Recv() (*ListValuesResponse, error)
+	grpc.ClientStream
+}
+
+type collectdListValuesClient struct {
+	grpc.ClientStream
+}
+
+func (x *collectdListValuesClient) Recv() (*ListValuesResponse, error) {
+	m := new(ListValuesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for Collectd service
 
 type CollectdServer interface {
@@ -212,6 +288,9 @@ type CollectdServer interface {
 	// QueryValues returns a stream of matching value lists from collectd's
 	// internal cache.
 	QueryValues(*QueryValuesRequest, Collectd_QueryValuesServer) error
+	// ListValues returns a stream of the identifiers of matching value
+	// lists, without their values.
+	ListValues(*ListValuesRequest, Collectd_ListValuesServer) error
 }
 
 func RegisterCollectdServer(s *grpc.Server, srv CollectdServer) {
@@ -265,6 +344,27 @@ func (x *collectdQueryValuesServer) Send(m *QueryValuesResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Collectd_ListValues_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListValuesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CollectdServer).ListValues(m, &collectdListValuesServer{stream})
+}
+
+type Collectd_ListValuesServer interface {
+	Send(*ListValuesResponse) error
+	grpc.ServerStream
+}
+
+type collectdListValuesServer struct {
+	grpc.ServerStream
+}
+
+func (x *collectdListValuesServer) Send(m *ListValuesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _Collectd_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "collectd.Collectd",
 	HandlerType: (*CollectdServer)(nil),
@@ -280,6 +380,11 @@ var _Collectd_serviceDesc = grpc.ServiceDesc{
 			Handler:       _Collectd_QueryValues_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ListValues",
+			Handler:       _Collectd_ListValues_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "collectd.proto",
 }
@@ -287,7 +392,7 @@ var _Collectd_serviceDesc = grpc.ServiceDesc{
 func init() { proto1.RegisterFile("collectd.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 238 bytes of a gzipped FileDescriptorProto
+	// 271 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4b, 0xce, 0xcf, 0xc9,
 	0x49, 0x4d, 0x2e, 0x49, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x80, 0xf1, 0xa5, 0xb8,
 	0x4b, 0x2a, 0x0b, 0x52, 0x8b, 0x21, 0xc2, 0x4a, 0x3e, 0x5c, 0x02, 0x01, 0xa5, 0x25, 0x61, 0x89,
@@ -297,10 +402,12 @@ var fileDescriptor0 = []byte{
 	0x30, 0x97, 0x20, 0x92, 0x69, 0xc5, 0x05, 0xf9, 0x79, 0xc5, 0xa9, 0x4a, 0x01, 0x5c, 0x42, 0x81,
 	0xa5, 0xa9, 0x45, 0x95, 0xa8, 0x96, 0x58, 0x71, 0x71, 0x65, 0xa6, 0xa4, 0xe6, 0x95, 0x64, 0xa6,
 	0x65, 0xa6, 0x16, 0x41, 0x2d, 0x91, 0x42, 0xb7, 0xc4, 0x13, 0xae, 0x22, 0x08, 0x49, 0xb5, 0x92,
-	0x3f, 0x97, 0x30, 0x8a, 0x89, 0x10, 0x8b, 0xc8, 0x77, 0xb7, 0xd1, 0x02, 0x46, 0x2e, 0x0e, 0x67,
-	0xa8, 0x3a, 0x21, 0x37, 0x2e, 0x4e, 0xb8, 0x27, 0x84, 0x90, 0x9c, 0x84, 0x1e, 0x4e, 0x52, 0xd2,
-	0x58, 0xe5, 0x20, 0x8e, 0xd1, 0x60, 0x14, 0xf2, 0xe1, 0xe2, 0x46, 0x72, 0xa5, 0x90, 0x0c, 0x42,
-	0x35, 0x66, 0x70, 0x48, 0xc9, 0xe2, 0x90, 0x85, 0x98, 0x66, 0xc0, 0xe8, 0x24, 0x11, 0x25, 0x06,
-	0x57, 0x91, 0x5f, 0x94, 0xae, 0x5f, 0x54, 0x90, 0xac, 0x0f, 0x8e, 0xc2, 0x24, 0x36, 0x30, 0x65,
-	0x0c, 0x08, 0x00, 0x00, 0xff, 0xff, 0xde, 0xc9, 0x28, 0x16, 0xf2, 0x01, 0x00, 0x00,
+	0x3f, 0x97, 0x30, 0x8a, 0x89, 0x10, 0x8b, 0x28, 0x70, 0xb7, 0x3f, 0x97, 0x20, 0x88, 0xa6, 0x9e,
+	0x0b, 0x03, 0xb8, 0x84, 0x90, 0x0d, 0x84, 0x3a, 0x90, 0x02, 0x13, 0x8d, 0x5e, 0x33, 0x72, 0x71,
+	0x38, 0x43, 0x55, 0x0a, 0xb9, 0x71, 0x71, 0xc2, 0xc3, 0x59, 0x08, 0xc9, 0x04, 0xf4, 0xa8, 0x94,
+	0x92, 0xc6, 0x2a, 0x07, 0x71, 0x8e, 0x06, 0xa3, 0x90, 0x0f, 0x17, 0x37, 0x52, 0x40, 0x0a, 0xc9,
+	0x20, 0x54, 0x63, 0xc6, 0x98, 0x94, 0x2c, 0x0e, 0x59, 0x88, 0x69, 0x06, 0x8c, 0x42, 0x9e, 0x5c,
+	0x5c, 0x08, 0x4f, 0x0b, 0x21, 0x59, 0x8d, 0x11, 0xb6, 0x52, 0x32, 0xd8, 0x25, 0x61, 0x46, 0x39,
+	0x49, 0x44, 0x89, 0xc1, 0x15, 0xe4, 0x17, 0xa5, 0xeb, 0x17, 0x15, 0x24, 0xeb, 0x83, 0x13, 0x6c,
+	0x12, 0x1b, 0x98, 0x32, 0x06, 0x0c, 0x00, 0x2b, 0xdb, 0xe0, 0xc6, 0xe0, 0x02, 0x00, 0x00,
 }
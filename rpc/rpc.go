@@ -33,6 +33,12 @@ Synopsis:
 	  // consume ValueList
   }
 
+  // List the identifiers of matching ValueLists.
+  ids, err := rpc.List(context.Background(), c, api.Identifier{
+	  Host: "*",
+	  Plugin: "golang",
+  })
+
 Server code
 
 Synopsis:
@@ -59,11 +65,17 @@ Synopsis:
 	  rpc.RegisterServer(srv, &myServer{})
 	  srv.Serve(sock)
   }
+
+To serve the most recent value lists written by clients from memory, use the
+built-in cache:
+
+  err := rpc.ListenAndServe(ctx, ":12345", rpc.NewCacheServer())
 */
 package rpc // import "collectd.org/rpc"
 
 import (
 	"context"
+	"sort"
 
 	"collectd.org/api"
 )
@@ -79,3 +91,51 @@ type Interface interface {
 	api.Writer
 	Query(context.Context, *api.Identifier) (<-chan *api.ValueList, error)
 }
+
+// Lister is implemented by types that can enumerate identifiers without
+// retrieving the value lists, such as the server returned by NewCacheServer
+// and the client returned by NewClient, which uses the ListValues RPC.
+type Lister interface {
+	List(context.Context, api.Identifier) ([]api.Identifier, error)
+}
+
+// List returns the sorted, unique identifiers of all value lists matching id,
+// similar to the LISTVAL command of collectd's unixsock plugin.
+//
+// If c implements Lister, its List method is used. Otherwise, List is
+// implemented using Query: all matching value lists are retrieved and their
+// values discarded, so List costs as much as Query.
+func List(ctx context.Context, c Interface, id api.Identifier) ([]api.Identifier, error) {
+	if l, ok := c.(Lister); ok {
+		return l.List(ctx, id)
+	}
+
+	return listByQuery(ctx, c, id)
+}
+
+// listByQuery implements List using c.Query.
+func listByQuery(ctx context.Context, c Interface, id api.Identifier) ([]api.Identifier, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ch, err := c.Query(ctx, &id)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[api.Identifier]bool)
+	var ids []api.Identifier
+	for vl := range ch {
+		if seen[vl.Identifier] {
+			continue
+		}
+		seen[vl.Identifier] = true
+		ids = append(ids, vl.Identifier)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	return ids, ctx.Err()
+}
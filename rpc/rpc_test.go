@@ -0,0 +1,51 @@
+package rpc // import "collectd.org/rpc"
+
+import (
+	"context"
+	"testing"
+
+	"collectd.org/api"
+	"github.com/google/go-cmp/cmp"
+)
+
+type testInterface struct {
+	api.Writer
+	valueLists []*api.ValueList
+}
+
+func (ti *testInterface) Query(ctx context.Context, _ *api.Identifier) (<-chan *api.ValueList, error) {
+	ch := make(chan *api.ValueList)
+	go func() {
+		defer close(ch)
+		for _, vl := range ti.valueLists {
+			select {
+			case ch <- vl:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestList(t *testing.T) {
+	a := api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"}
+	b := api.Identifier{Host: "example.com", Plugin: "memory", Type: "memory"}
+
+	ti := &testInterface{
+		valueLists: []*api.ValueList{
+			{Identifier: b, Values: []api.Value{api.Gauge(1)}},
+			{Identifier: a, Values: []api.Value{api.Derive(2)}},
+			{Identifier: b, Values: []api.Value{api.Gauge(3)}},
+		},
+	}
+
+	got, err := List(context.Background(), ti, api.Identifier{Host: "*"})
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+
+	if diff := cmp.Diff([]api.Identifier{a, b}, got); diff != "" {
+		t.Errorf("List() differs (+got/-want):\n%s", diff)
+	}
+}
@@ -3,12 +3,46 @@ package rpc // import "collectd.org/rpc"
 import (
 	"context"
 	"io"
+	"net"
 
 	pb "collectd.org/rpc/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 )
 
+// ListenAndServe listens on the TCP network address addr and serves the
+// collectd gRPC service using iface. It returns when ctx is cancelled, in which
+// case the context's error is returned, or when the server fails.
+func ListenAndServe(ctx context.Context, addr string, iface Interface, opts ...grpc.ServerOption) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return serve(ctx, lis, iface, opts...)
+}
+
+func serve(ctx context.Context, lis net.Listener, iface Interface, opts ...grpc.ServerOption) error {
+	s := grpc.NewServer(opts...)
+	RegisterServer(s, iface)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.Serve(lis)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		// Stop closes the listener and all open streams, which may be
+		// long-lived.
+		s.Stop()
+		<-errCh
+		return ctx.Err()
+	}
+}
+
 // RegisterServer registers the implementation srv with the gRPC instance s.
 func RegisterServer(s *grpc.Server, srv Interface) {
 	pb.RegisterCollectdServer(s, &server{
@@ -76,3 +110,26 @@ func (s *server) QueryValues(req *pb.QueryValuesRequest, stream pb.Collectd_Quer
 
 	return nil
 }
+
+// ListValues calls List() and streams the identifiers back to the client. If
+// the Interface implementation does not implement Lister, the identifiers are
+// determined using Query(), but only the identifiers are sent to the client.
+func (s *server) ListValues(req *pb.ListValuesRequest, stream pb.Collectd_ListValuesServer) error {
+	id := UnmarshalIdentifier(req.GetIdentifier())
+
+	ids, err := List(stream.Context(), s.Interface, *id)
+	if err != nil {
+		return grpc.Errorf(codes.Internal, "List(%v): %v", id, err)
+	}
+
+	for i := range ids {
+		res := &pb.ListValuesResponse{
+			Identifier: MarshalIdentifier(&ids[i]),
+		}
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
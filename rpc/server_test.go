@@ -0,0 +1,275 @@
+package rpc // import "collectd.org/rpc"
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"collectd.org/api"
+	pb "collectd.org/rpc/proto"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// blockingWriter blocks until the context passed to Write is done and reports
+// the context's error.
+type blockingWriter chan error
+
+func (w blockingWriter) Write(ctx context.Context, _ *api.ValueList) error {
+	<-ctx.Done()
+	w <- ctx.Err()
+	return ctx.Err()
+}
+
+// newTestConn starts a gRPC server using srv and returns a client connection
+// to it. Both are shut down when the test finishes.
+func newTestConn(t *testing.T, srv Interface) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 16)
+	t.Cleanup(func() { lis.Close() })
+
+	s := grpc.NewServer()
+	RegisterServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// countingLister wraps a server implementing Lister and counts calls to Query
+// and List.
+type countingLister struct {
+	Interface
+	queries, lists int
+}
+
+func (c *countingLister) Query(ctx context.Context, id *api.Identifier) (<-chan *api.ValueList, error) {
+	c.queries++
+	return c.Interface.Query(ctx, id)
+}
+
+func (c *countingLister) List(ctx context.Context, id api.Identifier) ([]api.Identifier, error) {
+	c.lists++
+	return c.Interface.(Lister).List(ctx, id)
+}
+
+func TestListValues(t *testing.T) {
+	ctx := context.Background()
+	a := api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"}
+	b := api.Identifier{Host: "example.com", Plugin: "memory", Type: "memory"}
+
+	cache := NewCacheServer()
+	for _, id := range []api.Identifier{b, a} {
+		vl := &api.ValueList{
+			Identifier: id,
+			Time:       time.Unix(1588164686, 0),
+			Interval:   10 * time.Second,
+			Values:     []api.Value{api.Gauge(42)},
+		}
+		if err := cache.Write(ctx, vl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("lister", func(t *testing.T) {
+		srv := &countingLister{Interface: cache}
+		c := NewClient(newTestConn(t, srv))
+
+		got, err := List(ctx, c, api.Identifier{Host: "*"})
+		if err != nil {
+			t.Fatalf("List() = %v", err)
+		}
+		if diff := cmp.Diff([]api.Identifier{a, b}, got); diff != "" {
+			t.Errorf("List() differs (+got/-want):\n%s", diff)
+		}
+		if srv.queries != 0 || srv.lists != 1 {
+			t.Errorf("server got %d Query and %d List calls, want 0 and 1", srv.queries, srv.lists)
+		}
+	})
+
+	t.Run("query fallback", func(t *testing.T) {
+		ti := &testInterface{
+			valueLists: []*api.ValueList{
+				{Identifier: b, Values: []api.Value{api.Gauge(1)}},
+				{Identifier: a, Values: []api.Value{api.Derive(2)}},
+			},
+		}
+		c := NewClient(newTestConn(t, ti))
+
+		got, err := List(ctx, c, api.Identifier{Host: "*"})
+		if err != nil {
+			t.Fatalf("List() = %v", err)
+		}
+		if diff := cmp.Diff([]api.Identifier{a, b}, got); diff != "" {
+			t.Errorf("List() differs (+got/-want):\n%s", diff)
+		}
+	})
+}
+
+// TestList_Unimplemented checks that the client falls back to QueryValues
+// when talking to a server without the ListValues method, such as collectd's
+// gRPC plugin.
+func TestList_Unimplemented(t *testing.T) {
+	a := api.Identifier{Host: "example.com", Plugin: "cpu", Type: "cpu"}
+
+	lis := bufconn.Listen(1 << 16)
+	t.Cleanup(func() { lis.Close() })
+
+	s := grpc.NewServer()
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "collectd.Collectd",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName: "QueryValues",
+				Handler: func(_ interface{}, stream grpc.ServerStream) error {
+					if err := stream.RecvMsg(new(pb.QueryValuesRequest)); err != nil {
+						return err
+					}
+					pbVL, err := MarshalValueList(&api.ValueList{
+						Identifier: a,
+						Time:       time.Unix(1588164686, 0),
+						Interval:   10 * time.Second,
+						Values:     []api.Value{api.Gauge(42)},
+					})
+					if err != nil {
+						return err
+					}
+					return stream.SendMsg(&pb.QueryValuesResponse{ValueList: pbVL})
+				},
+				ServerStreams: true,
+			},
+		},
+	}, struct{}{})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	got, err := List(context.Background(), NewClient(conn), api.Identifier{})
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if diff := cmp.Diff([]api.Identifier{a}, got); diff != "" {
+		t.Errorf("List() differs (+got/-want):\n%s", diff)
+	}
+}
+
+func TestWrite_Cancel(t *testing.T) {
+	ch := make(blockingWriter, 1)
+	c := NewClient(newTestConn(t, &testInterface{Writer: ch}))
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestWrite_Cancel",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+
+	// The client must honor an already cancelled context.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Write(ctx, vl); status.Code(err) != codes.Canceled {
+		t.Errorf("Write(cancelled context) = %v, want code %v", err, codes.Canceled)
+	}
+
+	// The server's Write must be cancelled when the client gives up.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := c.Write(ctx, vl); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Write() = %v, want code %v", err, codes.DeadlineExceeded)
+	}
+
+	select {
+	case err := <-ch:
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("server context error = %v, want cancellation", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("server-side Write was not cancelled")
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, lis, NewCacheServer())
+	}()
+
+	dialCtx, dialCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer dialCancel()
+	c, err := Dial(dialCtx, lis.Addr().String(), DialOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	vl := &api.ValueList{
+		Identifier: api.Identifier{
+			Host:   "example.com",
+			Plugin: "TestListenAndServe",
+			Type:   "gauge",
+		},
+		Time:     time.Unix(1588164686, 0),
+		Interval: 10 * time.Second,
+		Values:   []api.Value{api.Gauge(42)},
+	}
+	if err := c.Write(ctx, vl); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+
+	ids, err := List(ctx, c, api.Identifier{Plugin: "TestListenAndServe"})
+	if err != nil {
+		t.Fatalf("List() = %v", err)
+	}
+	if len(ids) != 1 || ids[0] != vl.Identifier {
+		t.Errorf("List() = %v, want [%v]", ids, vl.Identifier)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ListenAndServe() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Error("ListenAndServe() did not return after cancellation")
+	}
+}